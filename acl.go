@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// portSpec is a protocol with an optional destination port range. Protocol
+// "all" matches any traffic; icmp and all carry no ports.
+type portSpec struct {
+	Protocol string
+	FromPort int
+	ToPort   int
+}
+
+// HasPorts reports whether the spec restricts destination ports.
+func (p portSpec) HasPorts() bool {
+	return p.Protocol == "tcp" || p.Protocol == "udp"
+}
+
+// AllPorts reports whether the spec covers the whole port range.
+func (p portSpec) AllPorts() bool {
+	return p.FromPort == 0 && p.ToPort == 65535
+}
+
+// String formats the spec in the same proto/port form it is parsed from.
+func (p portSpec) String() string {
+	switch {
+	case !p.HasPorts() || p.AllPorts():
+		return p.Protocol
+	case p.FromPort == p.ToPort:
+		return fmt.Sprintf("%s/%d", p.Protocol, p.FromPort)
+	default:
+		return fmt.Sprintf("%s/%d-%d", p.Protocol, p.FromPort, p.ToPort)
+	}
+}
+
+// aclEntry pairs a block with the services it is allowed or denied for.
+type aclEntry struct {
+	Line     int
+	CIDR     *net.IPNet
+	Services []portSpec
+}
+
+// parsePortSpec parses specs like "tcp/443", "udp/1000-2000", "icmp" or
+// "all".
+func parsePortSpec(input string) (portSpec, error) {
+	proto, ports, hasPorts := strings.Cut(strings.ToLower(input), "/")
+	switch proto {
+	case "all", "icmp":
+		if hasPorts {
+			return portSpec{}, fmt.Errorf("protocol %s does not take ports: %s", proto, input)
+		}
+		return portSpec{Protocol: proto}, nil
+	case "tcp", "udp":
+	default:
+		return portSpec{}, fmt.Errorf("unknown protocol: %s", input)
+	}
+	if !hasPorts {
+		return portSpec{Protocol: proto, FromPort: 0, ToPort: 65535}, nil
+	}
+
+	from, to, isRange := strings.Cut(ports, "-")
+	if !isRange {
+		to = from
+	}
+	fromPort, errFrom := strconv.Atoi(from)
+	toPort, errTo := strconv.Atoi(to)
+	if errFrom != nil || errTo != nil || fromPort < 0 || toPort > 65535 || fromPort > toPort {
+		return portSpec{}, fmt.Errorf("invalid port range: %s", input)
+	}
+	return portSpec{Protocol: proto, FromPort: fromPort, ToPort: toPort}, nil
+}
+
+// parseACL reads "CIDR [spec...]" lines. An entry without specs applies to
+// all traffic. Blank lines and lines starting with '#' are ignored.
+func parseACL(r io.Reader) ([]aclEntry, error) {
+	var entries []aclEntry
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(c rune) bool {
+			return c == ',' || c == ' ' || c == '\t'
+		})
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("line %d: expected \"CIDR [spec...]\", got %q", lineNum, line)
+		}
+		ipnet, err := parseCIDR(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		entry := aclEntry{Line: lineNum, CIDR: ipnet}
+		for _, field := range fields[1:] {
+			spec, err := parsePortSpec(field)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			entry.Services = append(entry.Services, spec)
+		}
+		if len(entry.Services) == 0 {
+			entry.Services = []portSpec{{Protocol: "all"}}
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading ACL: %v", err)
+	}
+	return entries, nil
+}
+
+// aclFormats maps output format names to their renderers. Each renderer
+// receives the entries and whether they describe allow or deny rules.
+var aclFormats = map[string]func(w io.Writer, entries []aclEntry, allow bool) error{
+	"aws":      renderAWS,
+	"azure":    renderAzureNSG,
+	"cisco":    renderCisco,
+	"gcp":      renderGCPFirewall,
+	"iptables": renderIPTables,
+}
+
+// renderIPTables writes iptables/ip6tables commands appending INPUT rules.
+func renderIPTables(w io.Writer, entries []aclEntry, allow bool) error {
+	target := "DROP"
+	if allow {
+		target = "ACCEPT"
+	}
+	for _, entry := range entries {
+		command, icmp := "iptables", "icmp"
+		if entry.CIDR.IP.To4() == nil {
+			command, icmp = "ip6tables", "ipv6-icmp"
+		}
+		for _, spec := range entry.Services {
+			match := ""
+			switch {
+			case spec.Protocol == "icmp":
+				match = " -p " + icmp
+			case spec.HasPorts() && spec.AllPorts():
+				match = " -p " + spec.Protocol
+			case spec.HasPorts() && spec.FromPort == spec.ToPort:
+				match = fmt.Sprintf(" -p %s --dport %d", spec.Protocol, spec.FromPort)
+			case spec.HasPorts():
+				match = fmt.Sprintf(" -p %s --dport %d:%d", spec.Protocol, spec.FromPort, spec.ToPort)
+			}
+			if _, err := fmt.Fprintf(w, "%s -A INPUT -s %s%s -j %s\n", command, entry.CIDR, match, target); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renderCisco writes Cisco IOS extended access-list entries, with IPv4 and
+// IPv6 entries in separate lists.
+func renderCisco(w io.Writer, entries []aclEntry, allow bool) error {
+	action := "deny"
+	if allow {
+		action = "permit"
+	}
+	for _, family := range []string{"ip", "ipv6"} {
+		header := false
+		for _, entry := range entries {
+			isV4 := entry.CIDR.IP.To4() != nil
+			if isV4 != (family == "ip") {
+				continue
+			}
+			if !header {
+				if family == "ip" {
+					fmt.Fprintln(w, "ip access-list extended cidr-converter")
+				} else {
+					fmt.Fprintln(w, "ipv6 access-list cidr-converter-v6")
+				}
+				header = true
+			}
+			source := entry.CIDR.String()
+			if isV4 {
+				wildcard := make(net.IP, len(entry.CIDR.Mask))
+				for i, b := range entry.CIDR.Mask {
+					wildcard[i] = ^b
+				}
+				source = entry.CIDR.IP.String() + " " + wildcard.String()
+			}
+			for _, spec := range entry.Services {
+				proto, ports := spec.Protocol, ""
+				switch {
+				case proto == "all":
+					proto = family
+				case spec.HasPorts() && spec.FromPort == spec.ToPort:
+					ports = fmt.Sprintf(" eq %d", spec.FromPort)
+				case spec.HasPorts() && !spec.AllPorts():
+					ports = fmt.Sprintf(" range %d %d", spec.FromPort, spec.ToPort)
+				}
+				if _, err := fmt.Fprintf(w, " %s %s %s any%s\n", action, proto, source, ports); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// awsIPRange and awsIPv6Range are the address entries of an AWS security
+// group permission.
+type awsIPRange struct {
+	CidrIP string `json:"CidrIp"`
+}
+
+type awsIPv6Range struct {
+	CidrIPv6 string `json:"CidrIpv6"`
+}
+
+// awsPermission mirrors the IpPermissions structure accepted by
+// "aws ec2 authorize-security-group-ingress --ip-permissions".
+type awsPermission struct {
+	IPProtocol string         `json:"IpProtocol"`
+	FromPort   *int           `json:"FromPort,omitempty"`
+	ToPort     *int           `json:"ToPort,omitempty"`
+	IPRanges   []awsIPRange   `json:"IpRanges,omitempty"`
+	IPv6Ranges []awsIPv6Range `json:"Ipv6Ranges,omitempty"`
+}
+
+// serviceGroup collects the blocks of all entries sharing a service.
+type serviceGroup struct {
+	Spec portSpec
+	IPv4 []*net.IPNet
+	IPv6 []*net.IPNet
+}
+
+// groupByService returns one group per distinct service, sorted by the
+// service's string form.
+func groupByService(entries []aclEntry) []*serviceGroup {
+	byService := map[string]*serviceGroup{}
+	var keys []string
+	for _, entry := range entries {
+		for _, spec := range entry.Services {
+			key := spec.String()
+			group, ok := byService[key]
+			if !ok {
+				group = &serviceGroup{Spec: spec}
+				byService[key] = group
+				keys = append(keys, key)
+			}
+			if entry.CIDR.IP.To4() != nil {
+				group.IPv4 = append(group.IPv4, entry.CIDR)
+			} else {
+				group.IPv6 = append(group.IPv6, entry.CIDR)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	groups := []*serviceGroup{}
+	for _, key := range keys {
+		groups = append(groups, byService[key])
+	}
+	return groups
+}
+
+// awsPermissions converts the entries into one permission per service.
+// ICMP is IPv4 only in AWS, so the IPv6 blocks of an ICMP service get a
+// permission of their own for ICMPv6, protocol 58.
+func awsPermissions(entries []aclEntry) []awsPermission {
+	permissions := []awsPermission{}
+	for _, group := range groupByService(entries) {
+		perm := awsPermission{IPProtocol: group.Spec.Protocol}
+		switch group.Spec.Protocol {
+		case "all":
+			perm.IPProtocol = "-1"
+		case "icmp":
+			from, to := -1, -1
+			perm.FromPort, perm.ToPort = &from, &to
+		default:
+			from, to := group.Spec.FromPort, group.Spec.ToPort
+			perm.FromPort, perm.ToPort = &from, &to
+		}
+		for _, cidr := range group.IPv4 {
+			perm.IPRanges = append(perm.IPRanges, awsIPRange{CidrIP: cidr.String()})
+		}
+		icmpv6 := perm
+		icmpv6.IPProtocol, icmpv6.IPRanges = "58", nil
+		target := &perm
+		if group.Spec.Protocol == "icmp" {
+			target = &icmpv6
+		}
+		for _, cidr := range group.IPv6 {
+			target.IPv6Ranges = append(target.IPv6Ranges, awsIPv6Range{CidrIPv6: cidr.String()})
+		}
+		if len(perm.IPRanges) > 0 || len(perm.IPv6Ranges) > 0 {
+			permissions = append(permissions, perm)
+		}
+		if len(icmpv6.IPv6Ranges) > 0 {
+			permissions = append(permissions, icmpv6)
+		}
+	}
+	return permissions
+}
+
+// renderAWS writes the entries as security group IpPermissions JSON.
+// Security groups only hold allow rules, so deny lists are rejected.
+func renderAWS(w io.Writer, entries []aclEntry, allow bool) error {
+	if !allow {
+		return fmt.Errorf("AWS security groups do not support deny rules")
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(awsPermissions(entries)); err != nil {
+		return fmt.Errorf("error encoding JSON: %v", err)
+	}
+	return nil
+}
+
+// runACL implements the "acl" command.
+func runACL(args []string) error {
+	fs := flag.NewFlagSet("acl", flag.ContinueOnError)
+	format := fs.String("output-format", "iptables", "output format: aws, azure, cisco, gcp or iptables")
+	deny := fs.Bool("deny", false, "emit deny rules instead of allow rules")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: acl [--output-format=aws|azure|cisco|gcp|iptables] [--deny] <file>")
+	}
+	render, ok := aclFormats[*format]
+	if !ok {
+		return fmt.Errorf("unknown output format: %s", *format)
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	parse := parseACL
+	if isZoneFile(fs.Arg(0)) {
+		parse = zoneACLEntries
+	}
+	entries, err := parse(file)
+	if err != nil {
+		return err
+	}
+	return render(os.Stdout, entries, !*deny)
+}
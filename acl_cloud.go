@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// familyGroup holds the blocks of one address family sharing a service.
+type familyGroup struct {
+	Spec  portSpec
+	IPv6  bool
+	CIDRs []*net.IPNet
+}
+
+// familyGroups splits each service group by address family, since Azure and
+// GCP rules cannot mix IPv4 and IPv6 sources.
+func familyGroups(entries []aclEntry) []familyGroup {
+	var result []familyGroup
+	for _, group := range groupByService(entries) {
+		if len(group.IPv4) > 0 {
+			result = append(result, familyGroup{Spec: group.Spec, CIDRs: group.IPv4})
+		}
+		if len(group.IPv6) > 0 {
+			result = append(result, familyGroup{Spec: group.Spec, IPv6: true, CIDRs: group.IPv6})
+		}
+	}
+	return result
+}
+
+// portRange formats the spec's ports as "443" or "8000-8080". It returns an
+// empty string when the spec does not restrict ports.
+func portRange(spec portSpec) string {
+	if !spec.HasPorts() || spec.AllPorts() {
+		return ""
+	}
+	if spec.FromPort == spec.ToPort {
+		return strconv.Itoa(spec.FromPort)
+	}
+	return strconv.Itoa(spec.FromPort) + "-" + strconv.Itoa(spec.ToPort)
+}
+
+// azureSecurityRule mirrors a securityRules element of a
+// Microsoft.Network/networkSecurityGroups ARM resource.
+type azureSecurityRule struct {
+	Name       string                `json:"name"`
+	Properties azureSecurityRuleProp `json:"properties"`
+}
+
+type azureSecurityRuleProp struct {
+	Priority                 int      `json:"priority"`
+	Direction                string   `json:"direction"`
+	Access                   string   `json:"access"`
+	Protocol                 string   `json:"protocol"`
+	SourceAddressPrefixes    []string `json:"sourceAddressPrefixes"`
+	SourcePortRange          string   `json:"sourcePortRange"`
+	DestinationAddressPrefix string   `json:"destinationAddressPrefix"`
+	DestinationPortRange     string   `json:"destinationPortRange"`
+}
+
+// renderAzureNSG writes the entries as an ARM securityRules array with one
+// inbound rule per service and address family.
+func renderAzureNSG(w io.Writer, entries []aclEntry, allow bool) error {
+	access := "Deny"
+	if allow {
+		access = "Allow"
+	}
+	rules := []azureSecurityRule{}
+	for i, group := range familyGroups(entries) {
+		protocol, ports := "*", "*"
+		switch group.Spec.Protocol {
+		case "tcp":
+			protocol = "Tcp"
+		case "udp":
+			protocol = "Udp"
+		case "icmp":
+			protocol = "Icmp"
+		}
+		if r := portRange(group.Spec); r != "" {
+			ports = r
+		}
+		var sources []string
+		for _, cidr := range group.CIDRs {
+			sources = append(sources, cidr.String())
+		}
+		rules = append(rules, azureSecurityRule{
+			Name: fmt.Sprintf("cidr-converter-%d", i+1),
+			Properties: azureSecurityRuleProp{
+				Priority:                 100 + i,
+				Direction:                "Inbound",
+				Access:                   access,
+				Protocol:                 protocol,
+				SourceAddressPrefixes:    sources,
+				SourcePortRange:          "*",
+				DestinationAddressPrefix: "*",
+				DestinationPortRange:     ports,
+			},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(rules); err != nil {
+		return fmt.Errorf("error encoding JSON: %v", err)
+	}
+	return nil
+}
+
+// renderGCPFirewall writes the entries as a YAML list of Compute Engine
+// firewall resources with one ingress rule per service and address family.
+func renderGCPFirewall(w io.Writer, entries []aclEntry, allow bool) error {
+	action := "denied"
+	if allow {
+		action = "allowed"
+	}
+	for i, group := range familyGroups(entries) {
+		protocol := group.Spec.Protocol
+		if protocol == "icmp" && group.IPv6 {
+			protocol = "58"
+		}
+		fmt.Fprintf(w, "- name: cidr-converter-%d\n", i+1)
+		fmt.Fprintln(w, "  direction: INGRESS")
+		fmt.Fprintf(w, "  priority: %d\n", 1000+i)
+		fmt.Fprintln(w, "  sourceRanges:")
+		for _, cidr := range group.CIDRs {
+			fmt.Fprintf(w, "  - %s\n", cidr)
+		}
+		fmt.Fprintf(w, "  %s:\n", action)
+		fmt.Fprintf(w, "  - IPProtocol: %s\n", protocol)
+		if ports := portRange(group.Spec); ports != "" {
+			fmt.Fprintln(w, "    ports:")
+			if _, err := fmt.Fprintf(w, "    - '%s'\n", ports); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
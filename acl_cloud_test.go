@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCloudRenderers(t *testing.T) {
+	input := "10.0.0.0/8 tcp/443\n2001:db8::/32 tcp/443\n192.168.0.0/16 udp/1000-2000\n"
+
+	tests := []struct {
+		format string
+		allow  bool
+		want   string
+	}{
+		{
+			format: "azure",
+			allow:  true,
+			want: `[
+  {
+    "name": "cidr-converter-1",
+    "properties": {
+      "priority": 100,
+      "direction": "Inbound",
+      "access": "Allow",
+      "protocol": "Tcp",
+      "sourceAddressPrefixes": [
+        "10.0.0.0/8"
+      ],
+      "sourcePortRange": "*",
+      "destinationAddressPrefix": "*",
+      "destinationPortRange": "443"
+    }
+  },
+  {
+    "name": "cidr-converter-2",
+    "properties": {
+      "priority": 101,
+      "direction": "Inbound",
+      "access": "Allow",
+      "protocol": "Tcp",
+      "sourceAddressPrefixes": [
+        "2001:db8::/32"
+      ],
+      "sourcePortRange": "*",
+      "destinationAddressPrefix": "*",
+      "destinationPortRange": "443"
+    }
+  },
+  {
+    "name": "cidr-converter-3",
+    "properties": {
+      "priority": 102,
+      "direction": "Inbound",
+      "access": "Allow",
+      "protocol": "Udp",
+      "sourceAddressPrefixes": [
+        "192.168.0.0/16"
+      ],
+      "sourcePortRange": "*",
+      "destinationAddressPrefix": "*",
+      "destinationPortRange": "1000-2000"
+    }
+  }
+]
+`,
+		},
+		{
+			format: "gcp",
+			allow:  false,
+			want: `- name: cidr-converter-1
+  direction: INGRESS
+  priority: 1000
+  sourceRanges:
+  - 10.0.0.0/8
+  denied:
+  - IPProtocol: tcp
+    ports:
+    - '443'
+- name: cidr-converter-2
+  direction: INGRESS
+  priority: 1001
+  sourceRanges:
+  - 2001:db8::/32
+  denied:
+  - IPProtocol: tcp
+    ports:
+    - '443'
+- name: cidr-converter-3
+  direction: INGRESS
+  priority: 1002
+  sourceRanges:
+  - 192.168.0.0/16
+  denied:
+  - IPProtocol: udp
+    ports:
+    - '1000-2000'
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			entries, err := parseACL(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("parseACL() error = %v", err)
+			}
+			var out strings.Builder
+			if err := aclFormats[tt.format](&out, entries, tt.allow); err != nil {
+				t.Fatalf("render error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("%s output =\n%s\nwant\n%s", tt.format, out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePortSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    portSpec
+		wantErr bool
+	}{
+		{name: "Single port", input: "tcp/443", want: portSpec{Protocol: "tcp", FromPort: 443, ToPort: 443}},
+		{name: "Port range", input: "UDP/1000-2000", want: portSpec{Protocol: "udp", FromPort: 1000, ToPort: 2000}},
+		{name: "All ports", input: "tcp", want: portSpec{Protocol: "tcp", FromPort: 0, ToPort: 65535}},
+		{name: "ICMP", input: "icmp", want: portSpec{Protocol: "icmp"}},
+		{name: "ICMP with port", input: "icmp/8", wantErr: true},
+		{name: "Unknown protocol", input: "sctp/80", wantErr: true},
+		{name: "Reversed range", input: "tcp/2000-1000", wantErr: true},
+		{name: "Port out of range", input: "tcp/70000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePortSpec(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parsePortSpec() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parsePortSpec() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACLRenderers(t *testing.T) {
+	input := "10.0.0.0/8 tcp/443 udp/53\n2001:db8::/32 tcp/8000-8080\n192.168.0.0/16\n"
+
+	tests := []struct {
+		format string
+		allow  bool
+		want   string
+	}{
+		{
+			format: "iptables",
+			allow:  true,
+			want: "iptables -A INPUT -s 10.0.0.0/8 -p tcp --dport 443 -j ACCEPT\n" +
+				"iptables -A INPUT -s 10.0.0.0/8 -p udp --dport 53 -j ACCEPT\n" +
+				"ip6tables -A INPUT -s 2001:db8::/32 -p tcp --dport 8000:8080 -j ACCEPT\n" +
+				"iptables -A INPUT -s 192.168.0.0/16 -j ACCEPT\n",
+		},
+		{
+			format: "cisco",
+			allow:  false,
+			want: "ip access-list extended cidr-converter\n" +
+				" deny tcp 10.0.0.0 0.255.255.255 any eq 443\n" +
+				" deny udp 10.0.0.0 0.255.255.255 any eq 53\n" +
+				" deny ip 192.168.0.0 0.0.255.255 any\n" +
+				"ipv6 access-list cidr-converter-v6\n" +
+				" deny tcp 2001:db8::/32 any range 8000 8080\n",
+		},
+		{
+			format: "aws",
+			allow:  true,
+			want: `[
+  {
+    "IpProtocol": "-1",
+    "IpRanges": [
+      {
+        "CidrIp": "192.168.0.0/16"
+      }
+    ]
+  },
+  {
+    "IpProtocol": "tcp",
+    "FromPort": 443,
+    "ToPort": 443,
+    "IpRanges": [
+      {
+        "CidrIp": "10.0.0.0/8"
+      }
+    ]
+  },
+  {
+    "IpProtocol": "tcp",
+    "FromPort": 8000,
+    "ToPort": 8080,
+    "Ipv6Ranges": [
+      {
+        "CidrIpv6": "2001:db8::/32"
+      }
+    ]
+  },
+  {
+    "IpProtocol": "udp",
+    "FromPort": 53,
+    "ToPort": 53,
+    "IpRanges": [
+      {
+        "CidrIp": "10.0.0.0/8"
+      }
+    ]
+  }
+]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			entries, err := parseACL(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("parseACL() error = %v", err)
+			}
+			var out strings.Builder
+			if err := aclFormats[tt.format](&out, entries, tt.allow); err != nil {
+				t.Fatalf("render error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("%s output =\n%s\nwant\n%s", tt.format, out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseACLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "Only separators", input: "10.0.0.0/8\n,,\n"},
+		{name: "Invalid CIDR", input: "bogus tcp/22\n"},
+		{name: "Invalid spec", input: "10.0.0.0/8 tcp/99999\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseACL(strings.NewReader(tt.input)); err == nil {
+				t.Errorf("parseACL() expected an error")
+			}
+		})
+	}
+	if _, err := parseACL(strings.NewReader(",,\n")); err == nil || !strings.HasPrefix(err.Error(), "line 1:") {
+		t.Errorf("parseACL() error = %v, want a line 1 error", err)
+	}
+}
+
+func TestRenderAWSRejectsDeny(t *testing.T) {
+	entries, _ := parseACL(strings.NewReader("10.0.0.0/8 tcp/22\n"))
+	var out strings.Builder
+	if err := renderAWS(&out, entries, false); err == nil {
+		t.Errorf("renderAWS() accepted deny rules")
+	}
+}
+
+func TestAWSPermissionsICMPv6(t *testing.T) {
+	entries, err := parseACL(strings.NewReader("10.0.0.0/8 icmp\n2001:db8::/32 icmp\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, perm := range awsPermissions(entries) {
+		var ranges []string
+		for _, r := range perm.IPRanges {
+			ranges = append(ranges, r.CidrIP)
+		}
+		for _, r := range perm.IPv6Ranges {
+			ranges = append(ranges, r.CidrIPv6)
+		}
+		got = append(got, perm.IPProtocol+" "+strings.Join(ranges, ","))
+	}
+	if want := "icmp 10.0.0.0/8;58 2001:db8::/32"; strings.Join(got, ";") != want {
+		t.Errorf("awsPermissions() = %q, want %q", strings.Join(got, ";"), want)
+	}
+}
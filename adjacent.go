@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// nextSubnet returns the block of the same size immediately following
+// cidr. It fails when cidr is the last block of its address family.
+func nextSubnet(cidr *net.IPNet) (*net.IPNet, error) {
+	return shiftSubnet(cidr, 1)
+}
+
+// prevSubnet returns the block of the same size immediately preceding
+// cidr. It fails when cidr is the first block of its address family.
+func prevSubnet(cidr *net.IPNet) (*net.IPNet, error) {
+	return shiftSubnet(cidr, -1)
+}
+
+// shiftSubnet moves cidr by the given number of same-sized blocks.
+func shiftSubnet(cidr *net.IPNet, steps int64) (*net.IPNet, error) {
+	base := cidr.IP.Mask(cidr.Mask)
+	offset := new(big.Int).Mul(cidrSize(cidr), big.NewInt(steps))
+	ip := intToIP(new(big.Int).Add(ipToInt(base), offset), len(base))
+	if ip == nil {
+		return nil, fmt.Errorf("no adjacent block for %s: address space overflow", cidr)
+	}
+	return &net.IPNet{IP: ip, Mask: cidr.Mask}, nil
+}
+
+// runAdjacent implements the "adjacent" command.
+func runAdjacent(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: adjacent <cidr>")
+	}
+	cidr, err := parseCIDR(args[0])
+	if err != nil {
+		return err
+	}
+
+	if prev, err := prevSubnet(cidr); err != nil {
+		fmt.Println("Previous: none (start of address space)")
+	} else {
+		fmt.Printf("Previous: %s\n", prev)
+	}
+	if next, err := nextSubnet(cidr); err != nil {
+		fmt.Println("Next: none (end of address space)")
+	} else {
+		fmt.Printf("Next: %s\n", next)
+	}
+	return nil
+}
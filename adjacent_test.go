@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestNextSubnet(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Next /24", input: "192.168.0.0/24", want: "192.168.1.0/24"},
+		{name: "Carry into higher octet", input: "10.0.255.0/24", want: "10.1.0.0/24"},
+		{name: "IPv6", input: "2001:db8::/64", want: "2001:db8:0:1::/64"},
+		{name: "Overflow", input: "255.255.255.0/24", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cidr, _ := net.ParseCIDR(tt.input)
+			got, err := nextSubnet(cidr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("nextSubnet() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("nextSubnet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrevSubnet(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Previous /24", input: "192.168.1.0/24", want: "192.168.0.0/24"},
+		{name: "Borrow from higher octet", input: "10.1.0.0/16", want: "10.0.0.0/16"},
+		{name: "Underflow", input: "0.0.0.0/8", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cidr, _ := net.ParseCIDR(tt.input)
+			got, err := prevSubnet(cidr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("prevSubnet() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("prevSubnet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
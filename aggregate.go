@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// aggregateOptions constrains how aggregateWith combines blocks.
+type aggregateOptions struct {
+	// MaxPrefixLen is the shortest prefix length aggregation may produce,
+	// capped at the length of a host block of each family. Input blocks
+	// that are already broader are kept as they are. Zero means no limit.
+	MaxPrefixLen int
+	// Boundaries are blocks aggregation must not cross: no result block
+	// holds addresses both inside and outside one of them, even when the
+	// blocks on either side are adjacent. Input blocks straddling a
+	// boundary are split along it.
+	Boundaries []*net.IPNet
+	// Slack allows merging blocks that are not adjacent into a common
+	// parent when the addresses this adds are within the limit.
+	Slack slackLimit
+	// CountRatio, when positive, keeps busy and quiet blocks apart: two
+	// blocks are not merged when the counts of the Counts entries they
+	// cover differ by more than this factor.
+	CountRatio float64
+	Counts     []inputEntry
+}
+
+// enabled reports whether any constraint is set.
+func (o aggregateOptions) enabled() bool {
+	return o.MaxPrefixLen > 0 || len(o.Boundaries) > 0 || o.Slack.enabled()
+}
+
+// slackLimit is the over-coverage allowed for a merged block: either a
+// percentage of the block's size or an absolute number of addresses.
+type slackLimit struct {
+	Percent float64
+	Count   *big.Int
+}
+
+// parseSlack parses a slack limit such as "10%" or "256".
+func parseSlack(value string) (slackLimit, error) {
+	if strings.HasSuffix(value, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || percent < 0 || percent >= 100 {
+			return slackLimit{}, fmt.Errorf("invalid slack percentage: %s", value)
+		}
+		return slackLimit{Percent: percent}, nil
+	}
+	count, ok := new(big.Int).SetString(value, 10)
+	if !ok || count.Sign() < 0 {
+		return slackLimit{}, fmt.Errorf("invalid slack: %s, expected an address count or a percentage", value)
+	}
+	return slackLimit{Count: count}, nil
+}
+
+// enabled reports whether any slack is allowed.
+func (l slackLimit) enabled() bool {
+	return l.Percent > 0 || (l.Count != nil && l.Count.Sign() > 0)
+}
+
+// allows reports whether wasted addresses are acceptable in a block of the
+// given size.
+func (l slackLimit) allows(wasted, size *big.Int) bool {
+	if l.Count != nil {
+		return wasted.Cmp(l.Count) <= 0
+	}
+	// wasted/size <= Percent/100, scaled to avoid floating point sizes.
+	limit := new(big.Float).Mul(new(big.Float).SetInt(size), big.NewFloat(l.Percent/100))
+	return new(big.Float).SetInt(wasted).Cmp(limit) <= 0
+}
+
+// tooBroad reports whether aggregation into parent would produce a block
+// broader than MaxPrefixLen, clamped to the bits of parent's family: a limit
+// beyond 32 leaves IPv4 blocks unmerged.
+func (o aggregateOptions) tooBroad(parent *net.IPNet) bool {
+	ones, bits := parent.Mask.Size()
+	limit := o.MaxPrefixLen
+	if limit > bits {
+		limit = bits
+	}
+	return ones < limit
+}
+
+// unbalanced reports whether blocks with the given counts are kept apart by
+// CountRatio: the busiest was counted more than CountRatio times as often as
+// the quietest. A block without any count stays apart from a counted one.
+func (o aggregateOptions) unbalanced(counts ...uint64) bool {
+	if o.CountRatio <= 0 || len(counts) == 0 {
+		return false
+	}
+	busiest, quietest := counts[0], counts[0]
+	for _, count := range counts[1:] {
+		if count > busiest {
+			busiest = count
+		}
+		if count < quietest {
+			quietest = count
+		}
+	}
+	return float64(busiest) > o.CountRatio*float64(quietest)
+}
+
+// crossesBoundary reports whether cidr holds addresses both inside and
+// outside one of the boundaries.
+func (o aggregateOptions) crossesBoundary(cidr *net.IPNet) bool {
+	for _, boundary := range o.Boundaries {
+		if cidrContains(boundary, cidr) {
+			continue
+		}
+		if cidrsOverlap(boundary, cidr) {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregateWith returns the minimal list of blocks covering the same
+// addresses as cidrs, sorted by address, that satisfies opts.
+func aggregateWith(cidrs []*net.IPNet, opts aggregateOptions) []*net.IPNet {
+	var split []*net.IPNet
+	for _, cidr := range cidrs {
+		split = append(split, splitAtBoundaries(cidr, opts.Boundaries)...)
+	}
+	sortCIDRs(split)
+
+	// counts follows every block through the merges, each parent counted
+	// as the sum of the blocks it replaces.
+	counts := map[*net.IPNet]uint64{}
+	if opts.CountRatio > 0 {
+		for i, count := range attributeCounts(split, opts.Counts) {
+			counts[split[i]] = count
+		}
+	}
+	result := collapseWithin(split, counts, opts)
+	if opts.Slack.enabled() {
+		result = mergeWithSlack(result, counts, opts)
+	}
+	return result
+}
+
+// collapseWithin drops contained blocks and merges sibling blocks of split,
+// which must be sorted, stopping where opts forbids it.
+func collapseWithin(split []*net.IPNet, counts map[*net.IPNet]uint64, opts aggregateOptions) []*net.IPNet {
+	result := []*net.IPNet{}
+	for _, cidr := range split {
+		if len(result) > 0 && cidrContains(result[len(result)-1], cidr) {
+			continue
+		}
+		result = append(result, cidr)
+		for len(result) >= 2 {
+			a, b := result[len(result)-2], result[len(result)-1]
+			parent := siblingParent(a, b)
+			if parent == nil {
+				break
+			}
+			if opts.tooBroad(parent) || opts.crossesBoundary(parent) || opts.unbalanced(counts[a], counts[b]) {
+				break
+			}
+			counts[parent] = counts[a] + counts[b]
+			result = append(result[:len(result)-2], parent)
+		}
+	}
+	return result
+}
+
+// commonParent returns the smallest block containing both a and b, or nil
+// when they are of different address families.
+func commonParent(a, b *net.IPNet) *net.IPNet {
+	onesA, bitsA := a.Mask.Size()
+	onesB, bitsB := b.Mask.Size()
+	if bitsA != bitsB {
+		return nil
+	}
+	ipA, ipB := familyIP(a.IP), familyIP(b.IP)
+	ones := onesA
+	if onesB < ones {
+		ones = onesB
+	}
+	for i := 0; i < ones; i++ {
+		mask := byte(0x80 >> uint(i%8))
+		if ipA[i/8]&mask != ipB[i/8]&mask {
+			ones = i
+			break
+		}
+	}
+	mask := net.CIDRMask(ones, bitsA)
+	return &net.IPNet{IP: ipA.Mask(mask), Mask: mask}
+}
+
+// mergeWithSlack repeatedly replaces neighbouring blocks of cidrs, sorted
+// and collapsed, with their common parent when the addresses the parent
+// adds beyond cidrs are within opts.Slack. The waste is always measured
+// against the original cidrs, so it does not accumulate across merges:
+// two merged blocks are only combined further while their parent as a
+// whole stays within the limit.
+func mergeWithSlack(cidrs []*net.IPNet, counts map[*net.IPNet]uint64, opts aggregateOptions) []*net.IPNet {
+	result := cidrs
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i+1 < len(result); i++ {
+			parent := commonParent(result[i], result[i+1])
+			if parent == nil || opts.tooBroad(parent) || opts.crossesBoundary(parent) {
+				continue
+			}
+			size := cidrSize(parent)
+			wasted := new(big.Int).Sub(size, usedAddresses(parent, cidrs))
+			if !opts.Slack.allows(wasted, size) {
+				continue
+			}
+			// The parent may cover blocks before result[i] as well, when
+			// their own merge with result[i] wasted too much.
+			next := []*net.IPNet{parent}
+			var absorbed []uint64
+			for _, cidr := range result {
+				if cidrContains(parent, cidr) {
+					absorbed = append(absorbed, counts[cidr])
+				} else {
+					next = append(next, cidr)
+				}
+			}
+			if opts.unbalanced(absorbed...) {
+				continue
+			}
+			for _, count := range absorbed {
+				counts[parent] += count
+			}
+			sortCIDRs(next)
+			result = next
+			merged = true
+			break
+		}
+	}
+	return result
+}
+
+// splitAtBoundaries splits cidr into the parts inside and outside the
+// boundaries it straddles.
+func splitAtBoundaries(cidr *net.IPNet, boundaries []*net.IPNet) []*net.IPNet {
+	set := []*net.IPNet{cidr}
+	inside := intersectCIDRs(set, boundaries)
+	if len(inside) == 0 || (len(inside) == 1 && inside[0].String() == cidr.String()) {
+		return set
+	}
+	return append(inside, subtractCIDRs(set, boundaries)...)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestAggregateWith(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  aggregateOptions
+		want  string
+	}{
+		{
+			name:  "No limit",
+			input: "10.0.0.0/17\n10.0.128.0/17\n10.1.0.0/16\n",
+			want:  "10.0.0.0/15",
+		},
+		{
+			name:  "Stops at the limit",
+			input: "10.0.0.0/17\n10.0.128.0/17\n10.1.0.0/16\n",
+			opts:  aggregateOptions{MaxPrefixLen: 16},
+			want:  "10.0.0.0/16,10.1.0.0/16",
+		},
+		{
+			name:  "Keeps broader input blocks",
+			input: "10.0.0.0/14\n",
+			opts:  aggregateOptions{MaxPrefixLen: 16},
+			want:  "10.0.0.0/14",
+		},
+		{
+			name:  "Drops blocks inside broader ones",
+			input: "10.0.0.0/15\n10.1.2.0/24\n",
+			opts:  aggregateOptions{MaxPrefixLen: 16},
+			want:  "10.0.0.0/15",
+		},
+		{
+			name:  "Limit beyond IPv4 host length with mixed families",
+			input: "10.0.0.1/32\n10.0.0.0/32\n2001:db8::/33\n2001:db8:8000::/33\n2001:db8:1::/48\n",
+			opts:  aggregateOptions{MaxPrefixLen: 48},
+			want:  "10.0.0.0/32,10.0.0.1/32,2001:db8::/33,2001:db8:8000::/33",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cidrs, err := parseCIDRList(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("parseCIDRList() error = %v", err)
+			}
+			if got := joinCIDRs(aggregateWith(cidrs, tt.opts)); got != tt.want {
+				t.Errorf("aggregateWith() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateWithBoundaries(t *testing.T) {
+	boundaries, _ := parseCIDRList(strings.NewReader("10.0.0.0/24\n10.0.1.0/25\n"))
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "Merges inside a boundary",
+			input: "10.0.0.0/25\n10.0.0.128/25\n",
+			want:  "10.0.0.0/24",
+		},
+		{
+			name:  "Does not merge across boundaries",
+			input: "10.0.0.0/24\n10.0.1.0/24\n",
+			want:  "10.0.0.0/24,10.0.1.0/25,10.0.1.128/25",
+		},
+		{
+			name:  "Splits input blocks straddling a boundary",
+			input: "10.0.0.0/23\n",
+			want:  "10.0.0.0/24,10.0.1.0/25,10.0.1.128/25",
+		},
+		{
+			name:  "Merges outside the boundaries",
+			input: "10.0.2.0/24\n10.0.3.0/24\n",
+			want:  "10.0.2.0/23",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cidrs, err := parseCIDRList(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("parseCIDRList() error = %v", err)
+			}
+			if got := joinCIDRs(aggregateWith(cidrs, aggregateOptions{Boundaries: boundaries})); got != tt.want {
+				t.Errorf("aggregateWith() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateWithSlack(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		slack string
+		opts  aggregateOptions
+		want  string
+	}{
+		{
+			name:  "Gap within the count",
+			input: "10.0.0.0/25\n10.0.1.0/24\n",
+			slack: "128",
+			want:  "10.0.0.0/23",
+		},
+		{
+			name:  "Gap over the count",
+			input: "10.0.0.0/25\n10.0.1.0/24\n",
+			slack: "127",
+			want:  "10.0.0.0/25,10.0.1.0/24",
+		},
+		{
+			name:  "Gap within the percentage",
+			input: "10.0.0.0/25\n10.0.1.0/24\n",
+			slack: "25%",
+			want:  "10.0.0.0/23",
+		},
+		{
+			name:  "Gap over the percentage",
+			input: "10.0.0.0/25\n10.0.1.0/24\n",
+			slack: "20%",
+			want:  "10.0.0.0/25,10.0.1.0/24",
+		},
+		{
+			name:  "Waste does not accumulate",
+			input: "10.0.0.0/26\n10.0.0.128/25\n10.0.1.0/25\n10.0.1.192/26\n",
+			slack: "64",
+			want:  "10.0.0.0/24,10.0.1.0/24",
+		},
+		{
+			name:  "Parent covers an earlier block",
+			input: "10.0.0.64/26\n10.0.0.128/26\n10.0.1.0/24\n",
+			slack: "30%",
+			want:  "10.0.0.0/23",
+		},
+		{
+			name:  "Respects the prefix limit",
+			input: "10.0.0.0/25\n10.0.1.0/24\n",
+			slack: "128",
+			opts:  aggregateOptions{MaxPrefixLen: 24},
+			want:  "10.0.0.0/25,10.0.1.0/24",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cidrs, _ := parseCIDRList(strings.NewReader(tt.input))
+			limit, err := parseSlack(tt.slack)
+			if err != nil {
+				t.Fatalf("parseSlack() error = %v", err)
+			}
+			opts := tt.opts
+			opts.Slack = limit
+			got := aggregateWith(cidrs, opts)
+			if joinCIDRs(got) != tt.want {
+				t.Errorf("aggregateWith() = %q, want %q", joinCIDRs(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateWithCountRatio(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		slack string
+		ratio float64
+		want  string
+	}{
+		{
+			name:  "Balanced siblings",
+			input: "10.0.0.0/25 100\n10.0.0.128/25 150\n",
+			ratio: 2,
+			want:  "10.0.0.0/24",
+		},
+		{
+			name:  "Hot and cold siblings",
+			input: "10.0.0.0/25 100\n10.0.0.128/25 1000\n",
+			ratio: 2,
+			want:  "10.0.0.0/25,10.0.0.128/25",
+		},
+		{
+			name:  "Disabled",
+			input: "10.0.0.0/25 100\n10.0.0.128/25 1000\n",
+			want:  "10.0.0.0/24",
+		},
+		{
+			name:  "Merged counts are summed",
+			input: "10.0.0.0/25 100\n10.0.0.128/25 100\n10.0.1.0/24 300\n",
+			ratio: 1.4,
+			want:  "10.0.0.0/24,10.0.1.0/24",
+		},
+		{
+			name:  "Summed counts within the ratio",
+			input: "10.0.0.0/25 100\n10.0.0.128/25 100\n10.0.1.0/24 300\n",
+			ratio: 1.5,
+			want:  "10.0.0.0/23",
+		},
+		{
+			name:  "Uncounted sibling",
+			input: "10.0.0.0/25 0\n10.0.0.128/25 10\n",
+			ratio: 100,
+			want:  "10.0.0.0/25,10.0.0.128/25",
+		},
+		{
+			name:  "Slack parent covering a busy earlier block",
+			input: "10.0.0.64/26 1000\n10.0.0.128/26 10\n10.0.1.0/24 10\n",
+			slack: "30%",
+			ratio: 2,
+			want:  "10.0.0.64/26,10.0.0.128/26,10.0.1.0/24",
+		},
+		{
+			name:  "Applies to slack",
+			input: "10.0.0.0/25 10\n10.0.1.0/24 1000\n",
+			slack: "128",
+			ratio: 10,
+			want:  "10.0.0.0/25,10.0.1.0/24",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := scanCountedList(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("scanCountedList() error = %v", err)
+			}
+			var cidrs []*net.IPNet
+			for _, entry := range entries {
+				cidrs = append(cidrs, entry.CIDR)
+			}
+			opts := aggregateOptions{CountRatio: tt.ratio, Counts: entries}
+			if tt.slack != "" {
+				if opts.Slack, err = parseSlack(tt.slack); err != nil {
+					t.Fatalf("parseSlack() error = %v", err)
+				}
+			}
+			if got := joinCIDRs(aggregateWith(cidrs, opts)); got != tt.want {
+				t.Errorf("aggregateWith() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSlack(t *testing.T) {
+	for _, value := range []string{"10%", "0.5%", "256", "0"} {
+		if _, err := parseSlack(value); err != nil {
+			t.Errorf("parseSlack(%q) error = %v", value, err)
+		}
+	}
+	for _, value := range []string{"", "-1", "100%", "ten", "x%"} {
+		if _, err := parseSlack(value); err == nil {
+			t.Errorf("parseSlack(%q) expected an error", value)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// rule is a single entry of an ordered firewall rule list.
+type rule struct {
+	Line   int
+	CIDR   *net.IPNet
+	Action string
+}
+
+// Kinds of problems reported by analyzeRules.
+const (
+	findingShadowed     = "shadowed"
+	findingUnreachable  = "unreachable"
+	findingAggregatable = "aggregatable"
+)
+
+// ruleFinding describes a problem detected with a rule during analysis.
+type ruleFinding struct {
+	Rule   rule
+	Kind   string
+	Detail string
+}
+
+// parseRules reads an ordered rule list with one "CIDR action" pair per line.
+// Fields may be separated by whitespace or commas; blank lines and lines
+// starting with '#' are ignored.
+func parseRules(r io.Reader) ([]rule, error) {
+	var rules []rule
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(c rune) bool {
+			return c == ',' || c == ' ' || c == '\t'
+		})
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"CIDR action\", got %q", lineNum, line)
+		}
+		ipnet, err := parseCIDR(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		rules = append(rules, rule{Line: lineNum, CIDR: ipnet, Action: strings.ToLower(fields[1])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rules: %v", err)
+	}
+	return rules, nil
+}
+
+// analyzeRules inspects an ordered rule list and reports rules that are
+// shadowed by a single earlier rule, rules made unreachable by a combination
+// of earlier rules, and adjacent rules with the same action that could be
+// aggregated. It also returns the suggested cleaned-up rule list.
+func analyzeRules(rules []rule) ([]ruleFinding, []rule) {
+	findings := []ruleFinding{}
+	kept := []rule{}
+
+	var earlier []*net.IPNet
+	for i, r := range rules {
+		shadowed := false
+		for _, prev := range rules[:i] {
+			if cidrContains(prev.CIDR, r.CIDR) {
+				detail := fmt.Sprintf("covered by %s %s (line %d)", prev.CIDR, prev.Action, prev.Line)
+				if prev.Action != r.Action {
+					detail += " with a conflicting action"
+				}
+				findings = append(findings, ruleFinding{Rule: r, Kind: findingShadowed, Detail: detail})
+				shadowed = true
+				break
+			}
+		}
+		if !shadowed && coveredByUnion(r.CIDR, earlier) {
+			findings = append(findings, ruleFinding{
+				Rule:   r,
+				Kind:   findingUnreachable,
+				Detail: "covered by the combination of earlier rules",
+			})
+			shadowed = true
+		}
+		earlier = append(earlier, r.CIDR)
+		if !shadowed {
+			kept = append(kept, r)
+		}
+	}
+
+	// Repeatedly merge adjacent sibling rules, since a merged parent may in
+	// turn be mergeable with its own neighbour.
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i+1 < len(kept); i++ {
+			a, b := kept[i], kept[i+1]
+			if a.Action != b.Action {
+				continue
+			}
+			parent := siblingParent(a.CIDR, b.CIDR)
+			if parent == nil {
+				continue
+			}
+			findings = append(findings, ruleFinding{
+				Rule:   b,
+				Kind:   findingAggregatable,
+				Detail: fmt.Sprintf("can be aggregated with %s (line %d) into %s", a.CIDR, a.Line, parent),
+			})
+			kept[i] = rule{Line: a.Line, CIDR: parent, Action: a.Action}
+			kept = append(kept[:i+1], kept[i+2:]...)
+			merged = true
+		}
+	}
+	return findings, kept
+}
+
+// runAnalyze implements the "analyze" command.
+func runAnalyze(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: analyze <rules-file>")
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+
+	rules, err := parseRules(file)
+	if err != nil {
+		return err
+	}
+	findings, cleaned := analyzeRules(rules)
+
+	if len(findings) == 0 {
+		fmt.Println("No issues found.")
+	} else {
+		fmt.Println("Findings:")
+		for _, f := range findings {
+			fmt.Printf("line %d: %s %s is %s: %s\n", f.Rule.Line, f.Rule.CIDR, f.Rule.Action, f.Kind, f.Detail)
+		}
+	}
+
+	fmt.Println("\nSuggested rule list:")
+	for _, r := range cleaned {
+		fmt.Printf("%s %s\n", r.CIDR, r.Action)
+	}
+	return nil
+}
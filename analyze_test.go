@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{
+			name:    "Whitespace and comma separated",
+			input:   "# header\n10.0.0.0/8 allow\n\n192.168.0.0/16,DENY\n",
+			want:    2,
+			wantErr: false,
+		},
+		{
+			name:    "Missing action",
+			input:   "10.0.0.0/8\n",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid CIDR",
+			input:   "10.0.0.0/33 allow\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseRules(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseRules() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(rules) != tt.want {
+				t.Errorf("parseRules() returned %d rules, want %d", len(rules), tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		kinds []string
+		want  []string
+	}{
+		{
+			name:  "No issues",
+			input: "10.0.0.0/8 allow\n192.168.0.0/16 deny\n",
+			kinds: []string{},
+			want:  []string{"10.0.0.0/8 allow", "192.168.0.0/16 deny"},
+		},
+		{
+			name:  "Shadowed by earlier rule",
+			input: "10.0.0.0/8 deny\n10.1.0.0/16 allow\n",
+			kinds: []string{findingShadowed},
+			want:  []string{"10.0.0.0/8 deny"},
+		},
+		{
+			name:  "Unreachable through combined rules",
+			input: "10.0.0.0/25 allow\n10.0.0.128/25 deny\n10.0.0.0/24 allow\n",
+			kinds: []string{findingUnreachable},
+			want:  []string{"10.0.0.0/25 allow", "10.0.0.128/25 deny"},
+		},
+		{
+			name:  "Adjacent rules aggregated repeatedly",
+			input: "10.0.0.0/26 allow\n10.0.0.64/26 allow\n10.0.0.128/25 allow\n",
+			kinds: []string{findingAggregatable, findingAggregatable},
+			want:  []string{"10.0.0.0/24 allow"},
+		},
+		{
+			name:  "Siblings with different actions are kept",
+			input: "10.0.0.0/25 allow\n10.0.0.128/25 deny\n",
+			kinds: []string{},
+			want:  []string{"10.0.0.0/25 allow", "10.0.0.128/25 deny"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseRules(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("parseRules() error = %v", err)
+			}
+			findings, cleaned := analyzeRules(rules)
+			if len(findings) != len(tt.kinds) {
+				t.Fatalf("analyzeRules() returned %d findings, want %d: %v", len(findings), len(tt.kinds), findings)
+			}
+			for i, f := range findings {
+				if f.Kind != tt.kinds[i] {
+					t.Errorf("finding %d kind = %s, want %s", i, f.Kind, tt.kinds[i])
+				}
+			}
+			var got []string
+			for _, r := range cleaned {
+				got = append(got, r.CIDR.String()+" "+r.Action)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("analyzeRules() cleaned = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
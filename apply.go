@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ec2APIVersion is the EC2 Query API version used by the apply command.
+const ec2APIVersion = "2016-11-15"
+
+// awsCredentials are the static credentials used to sign requests.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// awsCredentialsFromEnv reads the standard AWS_* credential variables.
+func awsCredentialsFromEnv() (awsCredentials, error) {
+	creds := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return creds, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// signV4 adds an AWS Signature Version 4 Authorization header to req. The
+// signed headers are host, x-amz-date and, when set, content-type and
+// x-amz-security-token.
+func signV4(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	stamp := now.UTC().Format("20060102T150405Z")
+	date := stamp[:8]
+	req.Header.Set("X-Amz-Date", stamp)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host, "x-amz-date": stamp}
+	for _, name := range []string{"Content-Type", "X-Amz-Security-Token"} {
+		if value := req.Header.Get(name); value != "" {
+			headers[strings.ToLower(name)] = value
+		}
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// ec2Client calls the EC2 Query API.
+type ec2Client struct {
+	endpoint string
+	region   string
+	creds    awsCredentials
+	client   *http.Client
+	now      func() time.Time
+}
+
+// call posts an EC2 action with the given parameters and decodes the XML
+// response into out.
+func (c *ec2Client) call(ctx context.Context, action string, params url.Values, out interface{}) error {
+	form := url.Values{"Action": {action}, "Version": {ec2APIVersion}}
+	for key, values := range params {
+		form[key] = values
+	}
+	body := []byte(form.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signV4(req, body, c.creds, c.region, "ec2", c.now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling %s: %v", action, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error calling %s: %v", action, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Code    string `xml:"Errors>Error>Code"`
+			Message string `xml:"Errors>Error>Message"`
+		}
+		if xml.Unmarshal(data, &apiErr) == nil && apiErr.Code != "" {
+			return fmt.Errorf("error calling %s: %s: %s", action, apiErr.Code, apiErr.Message)
+		}
+		return fmt.Errorf("error calling %s: %s", action, resp.Status)
+	}
+	if out != nil {
+		if err := xml.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("error decoding %s response: %v", action, err)
+		}
+	}
+	return nil
+}
+
+// sgRule is a single ingress rule of a security group: one protocol, port
+// range and source block. Rules for all protocols carry no ports.
+type sgRule struct {
+	Protocol string
+	FromPort int
+	ToPort   int
+	CIDR     string
+}
+
+func (r sgRule) String() string {
+	if r.Protocol == "-1" {
+		return "all " + r.CIDR
+	}
+	return fmt.Sprintf("%s/%d-%d %s", r.Protocol, r.FromPort, r.ToPort, r.CIDR)
+}
+
+// describeSecurityGroupsResponse is the part of the DescribeSecurityGroups
+// response holding the ingress rules.
+type describeSecurityGroupsResponse struct {
+	Groups []struct {
+		Permissions []struct {
+			Protocol   string   `xml:"ipProtocol"`
+			FromPort   *int     `xml:"fromPort"`
+			ToPort     *int     `xml:"toPort"`
+			IPRanges   []string `xml:"ipRanges>item>cidrIp"`
+			IPv6Ranges []string `xml:"ipv6Ranges>item>cidrIpv6"`
+		} `xml:"ipPermissions>item"`
+	} `xml:"securityGroupInfo>item"`
+}
+
+// ingressRules returns the address-based ingress rules of a security group.
+// Rules referencing other groups or prefix lists are not returned.
+func (c *ec2Client) ingressRules(ctx context.Context, groupID string) ([]sgRule, error) {
+	var resp describeSecurityGroupsResponse
+	if err := c.call(ctx, "DescribeSecurityGroups", url.Values{"GroupId.1": {groupID}}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Groups) != 1 {
+		return nil, fmt.Errorf("security group not found: %s", groupID)
+	}
+	var rules []sgRule
+	for _, perm := range resp.Groups[0].Permissions {
+		rule := sgRule{Protocol: perm.Protocol}
+		if perm.Protocol != "-1" && perm.FromPort != nil && perm.ToPort != nil {
+			rule.FromPort, rule.ToPort = *perm.FromPort, *perm.ToPort
+		}
+		for _, cidr := range append(perm.IPRanges, perm.IPv6Ranges...) {
+			rule.CIDR = cidr
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// sgRules flattens the permissions computed by awsPermissions into rules.
+func sgRules(permissions []awsPermission) []sgRule {
+	var rules []sgRule
+	for _, perm := range permissions {
+		rule := sgRule{Protocol: perm.IPProtocol}
+		if perm.FromPort != nil && perm.ToPort != nil {
+			rule.FromPort, rule.ToPort = *perm.FromPort, *perm.ToPort
+		}
+		for _, r := range perm.IPRanges {
+			rule.CIDR = r.CidrIP
+			rules = append(rules, rule)
+		}
+		for _, r := range perm.IPv6Ranges {
+			rule.CIDR = r.CidrIPv6
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// diffSGRules returns the rules to add and to remove to turn current into
+// desired, each sorted.
+func diffSGRules(current, desired []sgRule) (add, remove []sgRule) {
+	have := map[sgRule]bool{}
+	for _, rule := range current {
+		have[rule] = true
+	}
+	want := map[sgRule]bool{}
+	for _, rule := range desired {
+		want[rule] = true
+		if !have[rule] {
+			add = append(add, rule)
+			have[rule] = true
+		}
+	}
+	for _, rule := range current {
+		if !want[rule] {
+			remove = append(remove, rule)
+			want[rule] = true
+		}
+	}
+	less := func(rules []sgRule) func(i, j int) bool {
+		return func(i, j int) bool { return rules[i].String() < rules[j].String() }
+	}
+	sort.Slice(add, less(add))
+	sort.Slice(remove, less(remove))
+	return add, remove
+}
+
+// sgPermissionParams encodes rules as the IpPermissions parameters of
+// AuthorizeSecurityGroupIngress and RevokeSecurityGroupIngress, one
+// permission per protocol and port range.
+func sgPermissionParams(groupID string, rules []sgRule) url.Values {
+	params := url.Values{"GroupId": {groupID}}
+	type service struct {
+		Protocol         string
+		FromPort, ToPort int
+	}
+	var services []service
+	ranges := map[service][]string{}
+	for _, rule := range rules {
+		s := service{rule.Protocol, rule.FromPort, rule.ToPort}
+		if _, ok := ranges[s]; !ok {
+			services = append(services, s)
+		}
+		ranges[s] = append(ranges[s], rule.CIDR)
+	}
+	for i, s := range services {
+		prefix := "IpPermissions." + strconv.Itoa(i+1) + "."
+		params.Set(prefix+"IpProtocol", s.Protocol)
+		if s.Protocol != "-1" {
+			params.Set(prefix+"FromPort", strconv.Itoa(s.FromPort))
+			params.Set(prefix+"ToPort", strconv.Itoa(s.ToPort))
+		}
+		v4, v6 := 0, 0
+		for _, cidr := range ranges[s] {
+			if strings.Contains(cidr, ":") {
+				v6++
+				params.Set(prefix+"Ipv6Ranges."+strconv.Itoa(v6)+".CidrIpv6", cidr)
+			} else {
+				v4++
+				params.Set(prefix+"IpRanges."+strconv.Itoa(v4)+".CidrIp", cidr)
+			}
+		}
+	}
+	return params
+}
+
+// applySecurityGroup converges the ingress rules of a security group on
+// desired, printing every change to w. With dryRun the changes and the API
+// payloads are printed but not made.
+func applySecurityGroup(ctx context.Context, w io.Writer, c *ec2Client, groupID string, desired []sgRule, dryRun bool) error {
+	current, err := c.ingressRules(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	add, remove := diffSGRules(current, desired)
+	if len(add) == 0 && len(remove) == 0 {
+		fmt.Fprintf(w, "%s is up to date\n", groupID)
+		return nil
+	}
+	for _, rule := range remove {
+		fmt.Fprintf(w, "-%s\n", rule)
+	}
+	for _, rule := range add {
+		fmt.Fprintf(w, "+%s\n", rule)
+	}
+
+	// Revoke first so that a group at its rule quota can take the new rules.
+	for _, change := range []struct {
+		action string
+		rules  []sgRule
+	}{
+		{"RevokeSecurityGroupIngress", remove},
+		{"AuthorizeSecurityGroupIngress", add},
+	} {
+		if len(change.rules) == 0 {
+			continue
+		}
+		params := sgPermissionParams(groupID, change.rules)
+		if dryRun {
+			fmt.Fprintf(w, "Dry run: would call %s with %s\n", change.action, params.Encode())
+			continue
+		}
+		if err := c.call(ctx, change.action, params, nil); err != nil {
+			return err
+		}
+	}
+	if !dryRun {
+		fmt.Fprintf(w, "%s updated: %d rules added, %d removed\n", groupID, len(add), len(remove))
+	}
+	return nil
+}
+
+// runApply implements the "apply" command.
+func runApply(args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	groupID := fs.String("aws-sg", "", "ID of the AWS security group to converge on the file's rules")
+	region := fs.String("region", "", "AWS region; AWS_REGION or AWS_DEFAULT_REGION when empty")
+	endpoint := fs.String("endpoint", "", "EC2 endpoint URL; https://ec2.<region>.amazonaws.com when empty")
+	dryRun := fs.Bool("dry-run", false, "print the rule changes and API payloads without applying them")
+	httpFlags := addHTTPFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *groupID == "" || fs.NArg() != 1 {
+		return fmt.Errorf("usage: apply -aws-sg <group-id> [-region name] [-endpoint url] [-dry-run] <file>")
+	}
+	if *region == "" {
+		*region = os.Getenv("AWS_REGION")
+	}
+	if *region == "" {
+		*region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if *region == "" {
+		return fmt.Errorf("no AWS region given")
+	}
+	if *endpoint == "" {
+		*endpoint = "https://ec2." + *region + ".amazonaws.com/"
+	}
+	creds, err := awsCredentialsFromEnv()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	entries, err := parseACL(file)
+	if err != nil {
+		return err
+	}
+
+	c := &ec2Client{endpoint: *endpoint, region: *region, creds: creds, client: httpFlags.client(), now: time.Now}
+	return applySecurityGroup(context.Background(), os.Stdout, c, *groupID, sgRules(awsPermissions(entries)), *dryRun)
+}
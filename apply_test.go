@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignV4(t *testing.T) {
+	// The get-vanilla case of the AWS Signature Version 4 test suite.
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signV4(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+}
+
+func TestDiffSGRules(t *testing.T) {
+	current := []sgRule{
+		{Protocol: "tcp", FromPort: 443, ToPort: 443, CIDR: "10.0.0.0/8"},
+		{Protocol: "tcp", FromPort: 443, ToPort: 443, CIDR: "192.0.2.0/24"},
+		{Protocol: "-1", CIDR: "172.16.0.0/12"},
+	}
+	desired := []sgRule{
+		{Protocol: "tcp", FromPort: 443, ToPort: 443, CIDR: "10.0.0.0/8"},
+		{Protocol: "tcp", FromPort: 22, ToPort: 22, CIDR: "10.0.0.0/8"},
+		{Protocol: "-1", CIDR: "172.16.0.0/12"},
+		{Protocol: "-1", CIDR: "172.16.0.0/12"},
+	}
+	add, remove := diffSGRules(current, desired)
+	if len(add) != 1 || add[0].String() != "tcp/22-22 10.0.0.0/8" {
+		t.Errorf("diffSGRules() add = %v, want [tcp/22-22 10.0.0.0/8]", add)
+	}
+	if len(remove) != 1 || remove[0].String() != "tcp/443-443 192.0.2.0/24" {
+		t.Errorf("diffSGRules() remove = %v, want [tcp/443-443 192.0.2.0/24]", remove)
+	}
+}
+
+func TestSGPermissionParams(t *testing.T) {
+	params := sgPermissionParams("sg-123", []sgRule{
+		{Protocol: "tcp", FromPort: 443, ToPort: 443, CIDR: "10.0.0.0/8"},
+		{Protocol: "tcp", FromPort: 443, ToPort: 443, CIDR: "2001:db8::/32"},
+		{Protocol: "-1", CIDR: "192.0.2.0/24"},
+	})
+	want := "GroupId=sg-123" +
+		"&IpPermissions.1.FromPort=443&IpPermissions.1.IpProtocol=tcp" +
+		"&IpPermissions.1.IpRanges.1.CidrIp=10.0.0.0%2F8" +
+		"&IpPermissions.1.Ipv6Ranges.1.CidrIpv6=2001%3Adb8%3A%3A%2F32" +
+		"&IpPermissions.1.ToPort=443" +
+		"&IpPermissions.2.IpProtocol=-1&IpPermissions.2.IpRanges.1.CidrIp=192.0.2.0%2F24"
+	if got := params.Encode(); got != want {
+		t.Errorf("sgPermissionParams() = %q, want %q", got, want)
+	}
+}
+
+// fakeEC2 serves a security group with one HTTPS rule for 192.0.2.0/24 and
+// records the mutating calls.
+func fakeEC2(t *testing.T, calls *[]url.Values) *ec2Client {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(body))
+		switch form.Get("Action") {
+		case "DescribeSecurityGroups":
+			if form.Get("GroupId.1") != "sg-123" {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `<Response><Errors><Error><Code>InvalidGroup.NotFound</Code><Message>not found</Message></Error></Errors></Response>`)
+				return
+			}
+			io.WriteString(w, `<DescribeSecurityGroupsResponse><securityGroupInfo><item><groupId>sg-123</groupId>
+<ipPermissions><item><ipProtocol>tcp</ipProtocol><fromPort>443</fromPort><toPort>443</toPort>
+<groups><item><groupId>sg-456</groupId></item></groups>
+<ipRanges><item><cidrIp>192.0.2.0/24</cidrIp></item></ipRanges></item></ipPermissions>
+</item></securityGroupInfo></DescribeSecurityGroupsResponse>`)
+		default:
+			*calls = append(*calls, form)
+			io.WriteString(w, `<Response><return>true</return></Response>`)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return &ec2Client{
+		endpoint: ts.URL,
+		region:   "us-east-1",
+		creds:    awsCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		client:   ts.Client(),
+		now:      time.Now,
+	}
+}
+
+func TestApplySecurityGroup(t *testing.T) {
+	desired := []sgRule{{Protocol: "tcp", FromPort: 443, ToPort: 443, CIDR: "10.0.0.0/8"}}
+
+	tests := []struct {
+		name   string
+		dryRun bool
+		calls  []string
+		want   string
+	}{
+		{
+			name:  "Applies the changes",
+			calls: []string{"RevokeSecurityGroupIngress", "AuthorizeSecurityGroupIngress"},
+			want:  "-tcp/443-443 192.0.2.0/24\n+tcp/443-443 10.0.0.0/8\nsg-123 updated: 1 rules added, 1 removed\n",
+		},
+		{
+			name:   "Dry run",
+			dryRun: true,
+			want:   "Dry run: would call AuthorizeSecurityGroupIngress with GroupId=sg-123",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []url.Values
+			c := fakeEC2(t, &calls)
+			var out strings.Builder
+			if err := applySecurityGroup(context.Background(), &out, c, "sg-123", desired, tt.dryRun); err != nil {
+				t.Fatalf("applySecurityGroup() error = %v", err)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("applySecurityGroup() output = %q, want it to contain %q", out.String(), tt.want)
+			}
+			var actions []string
+			for _, call := range calls {
+				actions = append(actions, call.Get("Action"))
+			}
+			if strings.Join(actions, ",") != strings.Join(tt.calls, ",") {
+				t.Errorf("calls = %v, want %v", actions, tt.calls)
+			}
+		})
+	}
+}
+
+func TestApplySecurityGroupErrors(t *testing.T) {
+	var calls []url.Values
+	c := fakeEC2(t, &calls)
+	err := applySecurityGroup(context.Background(), io.Discard, c, "sg-999", nil, false)
+	if err == nil || !strings.Contains(err.Error(), "InvalidGroup.NotFound") {
+		t.Errorf("applySecurityGroup() error = %v, want InvalidGroup.NotFound", err)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+)
+
+// benchmarkSizes are the input sizes every benchmark runs at.
+var benchmarkSizes = []int{10000, 100000, 1000000}
+
+// benchmarkCIDRs returns n pseudo-random IPv4 blocks between /16 and /32.
+// The same n always gives the same blocks.
+func benchmarkCIDRs(n int) []*net.IPNet {
+	rng := rand.New(rand.NewSource(int64(n)))
+	cidrs := make([]*net.IPNet, n)
+	for i := range cidrs {
+		ones := 16 + rng.Intn(17)
+		ip := make(net.IP, 4)
+		rng.Read(ip)
+		mask := net.CIDRMask(ones, 32)
+		cidrs[i] = &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	}
+	return cidrs
+}
+
+// benchmarkInput returns benchmarkCIDRs(n) in the line-based input format.
+func benchmarkInput(n int) string {
+	var b strings.Builder
+	for _, cidr := range benchmarkCIDRs(n) {
+		b.WriteString(cidr.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func BenchmarkParseCIDRList(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		input := benchmarkInput(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := parseCIDRList(strings.NewReader(input)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMergeCIDRs(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		cidrs := benchmarkCIDRs(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				input := make([]*net.IPNet, len(cidrs))
+				copy(input, cidrs)
+				mergeCIDRs(deduplicateCIDRs(input))
+			}
+		})
+	}
+}
+
+func BenchmarkAggregateCIDRs(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		cidrs := mergeCIDRs(deduplicateCIDRs(benchmarkCIDRs(n)))
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				input := make([]*net.IPNet, len(cidrs))
+				copy(input, cidrs)
+				aggregateCIDRs(input)
+			}
+		})
+	}
+}
+
+func BenchmarkCollapseCIDRs(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		cidrs := benchmarkCIDRs(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				collapseCIDRs(cidrs)
+			}
+		})
+	}
+}
+
+func BenchmarkIPBelongsToCIDR(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		cidrs := collapseCIDRs(benchmarkCIDRs(n))
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ipBelongsToCIDR("10.20.30.40", cidrs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheEntry is a cached lookup result and when it expires.
+type cacheEntry struct {
+	Value   string    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+// enrichCache is a persistent cache for expensive lookups such as reverse
+// DNS, keyed by lookup kind and prefix, so that enriching the same lists
+// day after day does not repeat the network calls. A result cached for a
+// prefix answers lookups of every block inside it. The cache is kept in a
+// JSON file; expired entries are dropped when it is saved.
+type enrichCache struct {
+	path    string
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+// defaultCachePath returns the cache file in the user's cache directory.
+func defaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("no cache directory: %v", err)
+	}
+	return filepath.Join(dir, "cidr-converter", "enrich.json"), nil
+}
+
+// openEnrichCache loads the cache kept at path. A missing file is an empty
+// cache.
+func openEnrichCache(path string) (*enrichCache, error) {
+	c := &enrichCache{path: path, now: time.Now, entries: map[string]cacheEntry{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache: %v", err)
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, fmt.Errorf("error decoding cache %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// cacheKey is the key of a lookup of kind for prefix.
+func cacheKey(kind string, prefix *net.IPNet) string {
+	return kind + " " + prefix.String()
+}
+
+// get returns the unexpired result of a lookup of kind for the most
+// specific cached prefix containing cidr.
+func (c *enrichCache) get(kind string, cidr *net.IPNet) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ones, bits := cidr.Mask.Size()
+	now := c.now()
+	for prefix := ones; prefix >= 0; prefix-- {
+		mask := net.CIDRMask(prefix, bits)
+		entry, ok := c.entries[cacheKey(kind, &net.IPNet{IP: cidr.IP.Mask(mask), Mask: mask})]
+		if ok && now.Before(entry.Expires) {
+			return entry.Value, true
+		}
+	}
+	return "", false
+}
+
+// put caches the result of a lookup of kind for prefix for ttl.
+func (c *enrichCache) put(kind string, prefix *net.IPNet, value string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[cacheKey(kind, prefix)] = cacheEntry{Value: value, Expires: c.now().Add(ttl)}
+	c.dirty = true
+}
+
+// save writes the cache back to its file if it changed. The file is
+// replaced atomically so that concurrent runs never see it half written.
+func (c *enrichCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	now := c.now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.Expires) {
+			delete(c.entries, key)
+		}
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("error encoding cache: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("error creating cache directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".enrich-*.json")
+	if err != nil {
+		return fmt.Errorf("error writing cache: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing cache: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing cache: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("error writing cache: %v", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// reverseNames looks up the PTR name of every address with lookup, using
+// the cache when it is not nil. Addresses without a name map to an empty
+// string; failed lookups are cached too, so dead addresses are not retried
+// on every run.
+func reverseNames(ips []net.IP, cache *enrichCache, ttl time.Duration, lookup func(addr string) ([]string, error)) map[string]string {
+	names := make(map[string]string, len(ips))
+	for _, ip := range ips {
+		host := &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+		if cache != nil {
+			if name, ok := cache.get("ptr", host); ok {
+				names[ip.String()] = name
+				continue
+			}
+		}
+		name := ""
+		if found, err := lookup(ip.String()); err == nil && len(found) > 0 {
+			name = found[0]
+		}
+		names[ip.String()] = name
+		if cache != nil {
+			cache.put("ptr", host, name, ttl)
+		}
+	}
+	return names
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnrichCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "enrich.json")
+	cache, err := openEnrichCache(path)
+	if err != nil {
+		t.Fatalf("openEnrichCache() error = %v", err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	_, block, _ := net.ParseCIDR("192.0.2.0/24")
+	cache.put("asn", block, "AS64500", time.Hour)
+
+	tests := []struct {
+		name   string
+		kind   string
+		cidr   string
+		want   string
+		wantOK bool
+	}{
+		{name: "Exact prefix", kind: "asn", cidr: "192.0.2.0/24", want: "AS64500", wantOK: true},
+		{name: "More specific block", kind: "asn", cidr: "192.0.2.128/25", want: "AS64500", wantOK: true},
+		{name: "Host", kind: "asn", cidr: "192.0.2.7/32", want: "AS64500", wantOK: true},
+		{name: "Broader block", kind: "asn", cidr: "192.0.2.0/23"},
+		{name: "Other kind", kind: "ptr", cidr: "192.0.2.7/32"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cidr, _ := net.ParseCIDR(tt.cidr)
+			got, ok := cache.get(tt.kind, cidr)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("get() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	if err := cache.save(); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	reopened, err := openEnrichCache(path)
+	if err != nil {
+		t.Fatalf("openEnrichCache() error = %v", err)
+	}
+	reopened.now = func() time.Time { return now.Add(30 * time.Minute) }
+	if got, ok := reopened.get("asn", block); !ok || got != "AS64500" {
+		t.Errorf("get() after reopening = %q, %v, want %q, true", got, ok, "AS64500")
+	}
+	reopened.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if _, ok := reopened.get("asn", block); ok {
+		t.Errorf("get() returned an expired entry")
+	}
+}
+
+func TestReverseNames(t *testing.T) {
+	cache, _ := openEnrichCache(filepath.Join(t.TempDir(), "enrich.json"))
+	lookups := 0
+	lookup := func(addr string) ([]string, error) {
+		lookups++
+		if addr == "192.0.2.1" {
+			return []string{"host1.example.com."}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	ips := []net.IP{net.ParseIP("192.0.2.1").To4(), net.ParseIP("192.0.2.2").To4()}
+
+	for run := 0; run < 2; run++ {
+		names := reverseNames(ips, cache, time.Hour, lookup)
+		if names["192.0.2.1"] != "host1.example.com." || names["192.0.2.2"] != "" {
+			t.Errorf("reverseNames() = %v", names)
+		}
+	}
+	if lookups != 2 {
+		t.Errorf("lookups = %d, want 2", lookups)
+	}
+
+	reverseNames(ips, nil, time.Hour, lookup)
+	if lookups != 4 {
+		t.Errorf("lookups without a cache = %d, want 4", lookups)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Size questions understood by the calc command.
+var (
+	hostsQuery    = regexp.MustCompile(`^(?:(?:what\s+)?(?:cidr|prefix|block|subnet)\s+)?(?:(?:is\s+)?(?:needed\s+)?for\s+)?(\d+)\s+(?:hosts?|addresses)$`)
+	capacityQuery = regexp.MustCompile(`^(?:(?:how\s+many\s+)?(?:hosts?|addresses)\s+(?:are\s+)?in\s+(?:an?\s+)?|(?:capacity\s+of\s+))?/(\d+)$`)
+	subnetsQuery  = regexp.MustCompile(`^(?:how\s+many\s+)?/(\d+)s?\s+(?:subnets?\s+)?(?:are\s+)?(?:in|inside|fit\s+in)\s+(?:an?\s+)?/(\d+)$`)
+)
+
+// addressCount returns the number of addresses of a prefix.
+func addressCount(prefix, bits int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits-prefix))
+}
+
+// usableHosts returns the number of assignable host addresses of a prefix.
+// IPv4 blocks lose the network and broadcast addresses, except /31
+// point-to-point links (RFC 3021) and /32 host routes. IPv6 has no
+// broadcast, so every address counts.
+func usableHosts(prefix, bits int) *big.Int {
+	count := addressCount(prefix, bits)
+	if bits == 32 && prefix < 31 {
+		count.Sub(count, big.NewInt(2))
+	}
+	return count
+}
+
+// hostCount formats a number of hosts, e.g. "1 host" or "254 hosts".
+func hostCount(n *big.Int) string {
+	if n.Cmp(big.NewInt(1)) == 0 {
+		return "1 host"
+	}
+	return n.String() + " hosts"
+}
+
+// prefixForHosts returns the longest prefix with at least hosts usable
+// addresses.
+func prefixForHosts(hosts *big.Int, bits int) (int, error) {
+	for prefix := bits; prefix >= 0; prefix-- {
+		if usableHosts(prefix, bits).Cmp(hosts) >= 0 {
+			return prefix, nil
+		}
+	}
+	return 0, fmt.Errorf("%s hosts do not fit in any IPv%d block", hosts, map[int]int{32: 4, 128: 6}[bits])
+}
+
+// parsePrefixLen parses a prefix length valid for the address size.
+func parsePrefixLen(s string, bits int) (int, error) {
+	prefix, err := strconv.Atoi(s)
+	if err != nil || prefix < 0 || prefix > bits {
+		return 0, fmt.Errorf("invalid prefix length: /%s", s)
+	}
+	return prefix, nil
+}
+
+// answerSizeQuery answers a question about block sizes: the prefix needed
+// for a number of hosts ("500 hosts"), the capacity of a prefix ("/24"),
+// or the number of subnets of one prefix inside another ("/26 in /22").
+func answerSizeQuery(query string, bits int) (string, error) {
+	query = strings.Join(strings.Fields(strings.ToLower(strings.TrimSuffix(strings.TrimSpace(query), "?"))), " ")
+
+	if m := hostsQuery.FindStringSubmatch(query); m != nil {
+		hosts, _ := new(big.Int).SetString(m[1], 10)
+		prefix, err := prefixForHosts(hosts, bits)
+		if err != nil {
+			return "", err
+		}
+		verb := "need"
+		if hosts.Cmp(big.NewInt(1)) == 0 {
+			verb = "needs"
+		}
+		return fmt.Sprintf("%s %s a /%d (%s usable)", hostCount(hosts), verb, prefix, usableHosts(prefix, bits)), nil
+	}
+	if m := capacityQuery.FindStringSubmatch(query); m != nil {
+		prefix, err := parsePrefixLen(m[1], bits)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("/%d holds %s addresses, %s usable", prefix, addressCount(prefix, bits), usableHosts(prefix, bits)), nil
+	}
+	if m := subnetsQuery.FindStringSubmatch(query); m != nil {
+		inner, err := parsePrefixLen(m[1], bits)
+		if err != nil {
+			return "", err
+		}
+		outer, err := parsePrefixLen(m[2], bits)
+		if err != nil {
+			return "", err
+		}
+		if inner < outer {
+			return "", fmt.Errorf("/%d is larger than /%d", inner, outer)
+		}
+		return fmt.Sprintf("/%d holds %s /%d subnets", outer, addressCount(bits-inner+outer, bits), inner), nil
+	}
+	return "", fmt.Errorf("unrecognized question: %q; try \"500 hosts\", \"/24\" or \"/26 in /22\"", query)
+}
+
+// runCalc implements the "calc" command.
+func runCalc(args []string) error {
+	fs := flag.NewFlagSet("calc", flag.ContinueOnError)
+	ipv6 := fs.Bool("6", false, "answer for IPv6 instead of IPv4")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: calc [-6] <question>, e.g. \"500 hosts\", \"/24\" or \"/26 in /22\"")
+	}
+	bits := 32
+	if *ipv6 {
+		bits = 128
+	}
+	answer, err := answerSizeQuery(strings.Join(fs.Args(), " "), bits)
+	if err != nil {
+		return err
+	}
+	fmt.Println(answer)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestAnswerSizeQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		ipv6    bool
+		want    string
+		wantErr bool
+	}{
+		{name: "Hosts", query: "500 hosts", want: "500 hosts need a /23 (510 usable)"},
+		{name: "Hosts as a sentence", query: "What CIDR for 500 hosts?", want: "500 hosts need a /23 (510 usable)"},
+		{name: "Exact fit", query: "prefix needed for 254 hosts", want: "254 hosts need a /24 (254 usable)"},
+		{name: "Point-to-point", query: "2 hosts", want: "2 hosts need a /31 (2 usable)"},
+		{name: "Single host", query: "1 host", want: "1 host needs a /32 (1 usable)"},
+		{name: "IPv6 hosts", query: "500 hosts", ipv6: true, want: "500 hosts need a /119 (512 usable)"},
+		{name: "Too many hosts", query: "5000000000 hosts", wantErr: true},
+		{name: "Capacity", query: "/24", want: "/24 holds 256 addresses, 254 usable"},
+		{name: "Capacity as a sentence", query: "how many hosts in a /29", want: "/29 holds 8 addresses, 6 usable"},
+		{name: "Capacity of a /31", query: "capacity of /31", want: "/31 holds 2 addresses, 2 usable"},
+		{name: "IPv6 capacity", query: "/64", ipv6: true, want: "/64 holds 18446744073709551616 addresses, 18446744073709551616 usable"},
+		{name: "Subnets", query: "/26 in /22", want: "/22 holds 16 /26 subnets"},
+		{name: "Subnets as a sentence", query: "how many /24s fit in a /16?", want: "/16 holds 256 /24 subnets"},
+		{name: "IPv6 subnets", query: "/64 in /48", ipv6: true, want: "/48 holds 65536 /64 subnets"},
+		{name: "Subnet larger than the block", query: "/20 in /24", wantErr: true},
+		{name: "Prefix too long", query: "/33", wantErr: true},
+		{name: "Unrecognized", query: "how big is the internet", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bits := 32
+			if tt.ipv6 {
+				bits = 128
+			}
+			got, err := answerSizeQuery(tt.query, bits)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("answerSizeQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("answerSizeQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
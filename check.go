@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// namedSet is a set of blocks read from a file under a label.
+type namedSet struct {
+	Name string
+	File string
+}
+
+// namedSetFlag collects repeated -set NAME=FILE flags.
+type namedSetFlag []namedSet
+
+func (f *namedSetFlag) String() string {
+	var values []string
+	for _, s := range *f {
+		values = append(values, s.Name+"="+s.File)
+	}
+	return strings.Join(values, ",")
+}
+
+func (f *namedSetFlag) Set(value string) error {
+	name, file, ok := strings.Cut(value, "=")
+	if !ok || name == "" || file == "" {
+		return fmt.Errorf("expected NAME=FILE, got %q", value)
+	}
+	for _, s := range *f {
+		if s.Name == name {
+			return fmt.Errorf("set %q given twice", name)
+		}
+	}
+	*f = append(*f, namedSet{Name: name, File: file})
+	return nil
+}
+
+// setClassifier labels addresses with the named sets containing them.
+type setClassifier struct {
+	names   []string
+	indexes []cidrIndex
+}
+
+// newSetClassifier indexes the blocks of every set, in the given order.
+func newSetClassifier(names []string, sets [][]*net.IPNet) *setClassifier {
+	c := &setClassifier{names: names}
+	for _, cidrs := range sets {
+		c.indexes = append(c.indexes, newTrieIndex(cidrs))
+	}
+	return c
+}
+
+// classify returns the names of the sets containing ip, in set order.
+func (c *setClassifier) classify(ip net.IP) []string {
+	matches := []string{}
+	for i, index := range c.indexes {
+		if len(index.Containing(ip)) > 0 {
+			matches = append(matches, c.names[i])
+		}
+	}
+	return matches
+}
+
+// classification is the result of checking one address.
+type classification struct {
+	IP   string   `json:"ip"`
+	Sets []string `json:"sets"`
+}
+
+// classifyAll checks every address and writes one result per address, as
+// text lines or JSON lines.
+func classifyAll(w io.Writer, c *setClassifier, ips []string, jsonLines bool) error {
+	encoder := json.NewEncoder(w)
+	for _, ipStr := range ips {
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			return fmt.Errorf("invalid IP address: %s", ipStr)
+		}
+		sets := c.classify(ip)
+		if jsonLines {
+			if err := encoder.Encode(classification{IP: ipStr, Sets: sets}); err != nil {
+				return err
+			}
+			continue
+		}
+		label := "-"
+		if len(sets) > 0 {
+			label = strings.Join(sets, ",")
+		}
+		fmt.Fprintf(w, "%s %s\n", ipStr, label)
+	}
+	return nil
+}
+
+// runCheck implements the "check" command.
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	var sets namedSetFlag
+	fs.Var(&sets, "set", "named set as NAME=FILE, repeatable")
+	format := fs.String("output-format", "text", "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(sets) == 0 {
+		return fmt.Errorf("usage: check -set NAME=FILE... [--output-format=text|json] [ip...]")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown output format: %s", *format)
+	}
+
+	var names []string
+	var cidrs [][]*net.IPNet
+	for _, set := range sets {
+		setCIDRs, err := readCIDRFile(set.File)
+		if err != nil {
+			return err
+		}
+		names = append(names, set.Name)
+		cidrs = append(cidrs, setCIDRs)
+	}
+	classifier := newSetClassifier(names, cidrs)
+
+	ips := fs.Args()
+	if len(ips) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				ips = append(ips, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("error reading input: %v", err)
+		}
+	}
+	return classifyAll(os.Stdout, classifier, ips, *format == "json")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestClassifyAll(t *testing.T) {
+	corp, _ := parseCIDRList(strings.NewReader("10.0.0.0/8\n"))
+	vpn, _ := parseCIDRList(strings.NewReader("10.8.0.0/16\n2001:db8::/32\n"))
+	classifier := newSetClassifier([]string{"corp", "vpn"}, [][]*net.IPNet{corp, vpn})
+
+	tests := []struct {
+		name      string
+		jsonLines bool
+		want      string
+	}{
+		{
+			name: "Text",
+			want: "10.1.2.3 corp\n10.8.0.1 corp,vpn\n2001:db8::1 vpn\n192.168.0.1 -\n",
+		},
+		{
+			name:      "JSON lines",
+			jsonLines: true,
+			want: `{"ip":"10.1.2.3","sets":["corp"]}` + "\n" +
+				`{"ip":"10.8.0.1","sets":["corp","vpn"]}` + "\n" +
+				`{"ip":"2001:db8::1","sets":["vpn"]}` + "\n" +
+				`{"ip":"192.168.0.1","sets":[]}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := classifyAll(&buf, classifier, []string{"10.1.2.3", "10.8.0.1", "2001:db8::1", "192.168.0.1"}, tt.jsonLines); err != nil {
+				t.Fatalf("classifyAll() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("classifyAll() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := classifyAll(&buf, classifier, []string{"bogus"}, false); err == nil {
+		t.Errorf("classifyAll() accepted an invalid IP")
+	}
+}
+
+func TestNamedSetFlag(t *testing.T) {
+	var f namedSetFlag
+	if err := f.Set("corp=corp.txt"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	for _, value := range []string{"corp=other.txt", "vpn", "=vpn.txt", "vpn="} {
+		if err := f.Set(value); err == nil {
+			t.Errorf("Set(%q) expected an error", value)
+		}
+	}
+	if f.String() != "corp=corp.txt" {
+		t.Errorf("String() = %q", f.String())
+	}
+}
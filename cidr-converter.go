@@ -4,13 +4,19 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"math/big"
 	"net"
 	"os"
-	"regexp"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // parseCIDR validates and returns a CIDR block.
@@ -25,6 +31,117 @@ func parseCIDR(input string) (*net.IPNet, error) {
 	return ipnet, nil
 }
 
+// parseEntry parses a single input entry in CIDR or wildcard notation.
+func parseEntry(input string) ([]*net.IPNet, error) {
+	if strings.Contains(input, "*") {
+		return parseWildcard(input)
+	}
+	ipnet, err := parseCIDR(input)
+	if err != nil {
+		return nil, err
+	}
+	return []*net.IPNet{ipnet}, nil
+}
+
+// inputEntry is a block read from an input source together with the file
+// and line it was read from, or its position for JSON documents.
+type inputEntry struct {
+	CIDR *net.IPNet
+	File string
+	Line int
+	// Count is the number of hits attributed to the block by counted input.
+	Count uint64
+	// Name and Tags are the metadata of JSON objects carrying them.
+	Name string
+	Tags []string
+	// Draining is the removal date of a block being decommissioned, zero
+	// for blocks that are not draining.
+	Draining time.Time
+}
+
+// entryCIDRs drops the positions from the result of one of the scan
+// functions.
+func entryCIDRs(entries []inputEntry, err error) ([]*net.IPNet, error) {
+	if err != nil {
+		return nil, err
+	}
+	var cidrs []*net.IPNet
+	for _, entry := range entries {
+		cidrs = append(cidrs, entry.CIDR)
+	}
+	return cidrs, nil
+}
+
+// parseCIDRList reads one entry per line from r. Blank lines and lines
+// starting with '#' are ignored.
+func parseCIDRList(r io.Reader) ([]*net.IPNet, error) {
+	return entryCIDRs(scanCIDRList(r))
+}
+
+// scanCIDRList is parseCIDRList keeping the line of every block.
+func scanCIDRList(r io.Reader) ([]inputEntry, error) {
+	var entries []inputEntry
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ipnets, err := parseEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		for _, ipnet := range ipnets {
+			entries = append(entries, inputEntry{CIDR: ipnet, Line: lineNum})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading input: %v", err)
+	}
+	return entries, nil
+}
+
+// readCIDRFile reads a list of CIDR blocks from the named file. Files ending
+// in .json or .yaml/.yml are read as the tool's own output documents, RIR
+// statistics files named delegated-* as their allocated and assigned
+// blocks, BIND zone files (*.zone, db.*) as the host routes of their A and
+// AAAA records, any other file as one entry per line.
+func readCIDRFile(filename string) ([]*net.IPNet, error) {
+	return entryCIDRs(readCIDRFileEntries(filename))
+}
+
+// readCIDRFileEntries is readCIDRFile keeping the position of every block.
+func readCIDRFileEntries(filename string) ([]inputEntry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+
+	scan := scanCIDRList
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		scan = scanCIDRJSON
+	case ".yaml", ".yml":
+		scan = scanCIDRYAML
+	}
+	if isDelegatedFile(filename) {
+		scan = scanDelegated
+	} else if isZoneFile(filename) {
+		scan = scanZoneFile
+	}
+	entries, err := scan(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	for i := range entries {
+		entries[i].File = filename
+	}
+	return entries, nil
+}
+
 // deduplicateCIDRs removes duplicate CIDR blocks from the list.
 func deduplicateCIDRs(cidrs []*net.IPNet) []*net.IPNet {
 	seen := make(map[string]struct{})
@@ -56,55 +173,111 @@ func ipBelongsToCIDR(ipStr string, cidrs []*net.IPNet) ([]*net.IPNet, error) {
 	return matchingCIDRs, nil
 }
 
+// maxWildcardBlocks bounds the number of blocks a single wildcard pattern may
+// expand to.
+const maxWildcardBlocks = 65536
+
 // parseWildcard converts wildcard notation (e.g., 192.168.*.*) to CIDR blocks.
+// Wildcards may appear in any octet. Trailing wildcards become the host part
+// of the block; every wildcard followed by a fixed octet is expanded, so a
+// pattern such as 10.*.3.* yields one block per value of the second octet.
 func parseWildcard(input string) ([]*net.IPNet, error) {
-	wildcardRegex := regexp.MustCompile(`^((?:\d{1,3}|\*)\.){3}(?:\d{1,3}|\*)$`)
-	if !wildcardRegex.MatchString(input) {
+	octets := strings.Split(input, ".")
+	if len(octets) != 4 {
 		return nil, fmt.Errorf("invalid wildcard notation: %s", input)
 	}
-	octets := strings.Split(input, ".")
-	var ipRange string
-	for _, octet := range octets {
+	values := make([]int, 4)
+	for i, octet := range octets {
 		if octet == "*" {
-			ipRange += "0."
-		} else {
-			ipRange += octet + "."
+			values[i] = -1
+			continue
+		}
+		if !isOctetDigits(octet) {
+			return nil, fmt.Errorf("invalid wildcard notation: %s: invalid octet %q", input, octet)
 		}
+		value, err := strconv.Atoi(octet)
+		if err != nil || value > 255 || (len(octet) > 1 && octet[0] == '0') {
+			return nil, fmt.Errorf("invalid wildcard notation: %s: invalid octet %q", input, octet)
+		}
+		values[i] = value
 	}
-	ipRange = strings.TrimSuffix(ipRange, ".") + "/"
 
-	prefix := 32
-	for _, octet := range octets {
-		if octet == "*" {
-			prefix -= 8
+	// The trailing run of wildcards is covered by the prefix length.
+	prefixOctets := 4
+	for prefixOctets > 0 && values[prefixOctets-1] == -1 {
+		prefixOctets--
+	}
+	count := 1
+	for _, value := range values[:prefixOctets] {
+		if value == -1 {
+			count *= 256
 		}
 	}
+	if count > maxWildcardBlocks {
+		return nil, fmt.Errorf("invalid wildcard notation: %s expands to more than %d blocks", input, maxWildcardBlocks)
+	}
 
-	cidr := fmt.Sprintf("%s%d", ipRange, prefix)
-	ipnet, err := parseCIDR(cidr)
-	if err != nil {
-		return nil, err
+	mask := net.CIDRMask(prefixOctets*8, 32)
+	cidrs := make([]*net.IPNet, 0, count)
+	for n := 0; n < count; n++ {
+		ip := make(net.IP, 4)
+		rest := n
+		for i := prefixOctets - 1; i >= 0; i-- {
+			if values[i] == -1 {
+				ip[i] = byte(rest % 256)
+				rest /= 256
+			} else {
+				ip[i] = byte(values[i])
+			}
+		}
+		cidrs = append(cidrs, &net.IPNet{IP: ip, Mask: mask})
 	}
-	return []*net.IPNet{ipnet}, nil
+	return cidrs, nil
+}
+
+// isOctetDigits reports whether octet is one to three ASCII digits, without
+// a sign.
+func isOctetDigits(octet string) bool {
+	if len(octet) == 0 || len(octet) > 3 {
+		return false
+	}
+	for i := 0; i < len(octet); i++ {
+		if octet[i] < '0' || octet[i] > '9' {
+			return false
+		}
+	}
+	return true
 }
 
-// mergeCIDRs merges a list of CIDR blocks into a minimal set.
+// mergeCIDRs merges a list of CIDR blocks into a minimal set by dropping
+// the blocks contained in others.
 func mergeCIDRs(cidrs []*net.IPNet) []*net.IPNet {
-	sort.Slice(cidrs, func(i, j int) bool {
-		return bytes.Compare(cidrs[i].IP, cidrs[j].IP) < 0
-	})
+	return pruneContained(cidrs)
+}
+
+// pruneContained returns the blocks of cidrs that are not contained in any
+// other block, each once, sorted by address. Two CIDR blocks are either
+// disjoint or one contains the other, so this leaves a list of disjoint
+// blocks covering the same addresses. Unlike collapseCIDRs it never
+// combines blocks.
+func pruneContained(cidrs []*net.IPNet) []*net.IPNet {
+	sorted := make([]*net.IPNet, len(cidrs))
+	copy(sorted, cidrs)
+	sortCIDRs(sorted)
 
+	// Sorting puts every block after the broader blocks containing it. The
+	// kept blocks of a family are disjoint, so only the last one kept can
+	// contain the next block of that family. IPv4 and IPv6 blocks may
+	// interleave in the sort order, hence one last block per family.
 	result := []*net.IPNet{}
-	for _, cidr := range cidrs {
-		if len(result) == 0 {
-			result = append(result, cidr)
-			continue
-		}
-		last := result[len(result)-1]
-		if last.Contains(cidr.IP) {
+	last := map[int]*net.IPNet{}
+	for _, cidr := range sorted {
+		_, bits := cidr.Mask.Size()
+		if prev := last[bits]; prev != nil && cidrContains(prev, cidr) {
 			continue
 		}
 		result = append(result, cidr)
+		last[bits] = cidr
 	}
 	return result
 }
@@ -115,23 +288,40 @@ func aggregateCIDRs(cidrs []*net.IPNet) []*net.IPNet {
 		return bytes.Compare(cidrs[i].IP, cidrs[j].IP) < 0
 	})
 
+	// canAggregate only matches blocks with the same address and prefix
+	// length, so the blocks aggregated so far are indexed by both, each key
+	// listing its blocks lowest index first. The first listed block is the
+	// one a scan of aggregated in order would find.
 	aggregated := []*net.IPNet{}
+	byKey := map[string][]int{}
 	for _, cidr := range cidrs {
-		merged := false
-		for i, agg := range aggregated {
-			if canAggregate(agg, cidr) {
-				aggregated[i] = mergeTwoCIDRs(agg, cidr)
-				merged = true
-				break
-			}
-		}
-		if !merged {
+		key := aggregateKey(cidr)
+		matches := byKey[key]
+		if len(matches) == 0 {
+			byKey[key] = append(matches, len(aggregated))
 			aggregated = append(aggregated, cidr)
+			continue
 		}
+		i := matches[0]
+		byKey[key] = matches[1:]
+		aggregated[i] = mergeTwoCIDRs(aggregated[i], cidr)
+		parentKey := aggregateKey(aggregated[i])
+		indices := byKey[parentKey]
+		at := sort.SearchInts(indices, i)
+		indices = append(indices, 0)
+		copy(indices[at+1:], indices[at:])
+		indices[at] = i
+		byKey[parentKey] = indices
 	}
 	return aggregated
 }
 
+// aggregateKey returns the address and prefix length canAggregate compares.
+func aggregateKey(cidr *net.IPNet) string {
+	ones, bits := cidr.Mask.Size()
+	return string(cidr.IP) + "/" + strconv.Itoa(ones) + "/" + strconv.Itoa(bits)
+}
+
 // canAggregate checks if two CIDR blocks can be aggregated into a larger block.
 func canAggregate(a, b *net.IPNet) bool {
 	if a == nil || b == nil {
@@ -162,12 +352,288 @@ func mergeTwoCIDRs(a, b *net.IPNet) *net.IPNet {
 	}
 }
 
-// saveToJSON saves CIDRs to a JSON file.
-func saveToJSON(filename string, cidrs []*net.IPNet) error {
-	var cidrStrings []string
-	for _, cidr := range cidrs {
-		cidrStrings = append(cidrStrings, cidr.String())
+// cidrContains reports whether block a fully contains block b.
+func cidrContains(a, b *net.IPNet) bool {
+	onesA, bitsA := a.Mask.Size()
+	onesB, bitsB := b.Mask.Size()
+	if bitsA != bitsB || onesA > onesB {
+		return false
+	}
+	return a.Contains(b.IP)
+}
+
+// cidrsOverlap reports whether blocks a and b share any addresses.
+func cidrsOverlap(a, b *net.IPNet) bool {
+	_, bitsA := a.Mask.Size()
+	_, bitsB := b.Mask.Size()
+	if bitsA != bitsB {
+		return false
+	}
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// splitCIDR splits a block into its two halves. It returns nil for single
+// address blocks.
+func splitCIDR(cidr *net.IPNet) (*net.IPNet, *net.IPNet) {
+	ones, bits := cidr.Mask.Size()
+	if ones >= bits {
+		return nil, nil
+	}
+	mask := net.CIDRMask(ones+1, bits)
+	lo := cidr.IP.Mask(cidr.Mask)
+	hi := make(net.IP, len(lo))
+	copy(hi, lo)
+	hi[ones/8] |= 0x80 >> uint(ones%8)
+	return &net.IPNet{IP: lo, Mask: mask}, &net.IPNet{IP: hi, Mask: mask}
+}
+
+// siblingParent returns the parent block when a and b are the two distinct
+// halves of it, or nil otherwise.
+func siblingParent(a, b *net.IPNet) *net.IPNet {
+	onesA, bitsA := a.Mask.Size()
+	onesB, bitsB := b.Mask.Size()
+	if bitsA != bitsB || onesA != onesB || onesA == 0 || a.IP.Equal(b.IP) {
+		return nil
+	}
+	mask := net.CIDRMask(onesA-1, bitsA)
+	if !a.IP.Mask(mask).Equal(b.IP.Mask(mask)) {
+		return nil
+	}
+	return &net.IPNet{IP: a.IP.Mask(mask), Mask: mask}
+}
+
+// collapseCIDRs returns the minimal list of blocks covering the same
+// addresses as cidrs, sorted by address.
+func collapseCIDRs(cidrs []*net.IPNet) []*net.IPNet {
+	sorted := make([]*net.IPNet, len(cidrs))
+	copy(sorted, cidrs)
+	sortCIDRs(sorted)
+
+	result := []*net.IPNet{}
+	for _, cidr := range sorted {
+		if len(result) > 0 && cidrContains(result[len(result)-1], cidr) {
+			continue
+		}
+		result = append(result, cidr)
+		for len(result) >= 2 {
+			parent := siblingParent(result[len(result)-2], result[len(result)-1])
+			if parent == nil {
+				break
+			}
+			result = append(result[:len(result)-2], parent)
+		}
+	}
+	return result
+}
+
+// coveredByUnion reports whether every address of cidr is contained in at
+// least one block of the given set.
+func coveredByUnion(cidr *net.IPNet, set []*net.IPNet) bool {
+	overlapping := false
+	for _, c := range set {
+		if cidrContains(c, cidr) {
+			return true
+		}
+		if cidrsOverlap(c, cidr) {
+			overlapping = true
+		}
+	}
+	if !overlapping {
+		return false
+	}
+	lo, hi := splitCIDR(cidr)
+	if lo == nil {
+		return false
 	}
+	return coveredByUnion(lo, set) && coveredByUnion(hi, set)
+}
+
+// subtractCIDRs returns the address space covered by from but not by
+// remove, as a list of non-overlapping blocks sorted by address.
+func subtractCIDRs(from, remove []*net.IPNet) []*net.IPNet {
+	result := []*net.IPNet{}
+	var subtract func(cidr *net.IPNet)
+	subtract = func(cidr *net.IPNet) {
+		overlapping := false
+		// Blocks already in the result are treated like removed space so
+		// that overlapping inputs do not produce overlapping output.
+		for _, set := range [][]*net.IPNet{remove, result} {
+			for _, c := range set {
+				if cidrContains(c, cidr) {
+					return
+				}
+				if cidrsOverlap(c, cidr) {
+					overlapping = true
+				}
+			}
+		}
+		if !overlapping {
+			result = append(result, cidr)
+			return
+		}
+		lo, hi := splitCIDR(cidr)
+		if lo == nil {
+			return
+		}
+		subtract(lo)
+		subtract(hi)
+	}
+	for _, cidr := range from {
+		subtract(&net.IPNet{IP: cidr.IP.Mask(cidr.Mask), Mask: cidr.Mask})
+	}
+	sortCIDRs(result)
+	return result
+}
+
+// sortCIDRs sorts blocks by network address, then by prefix length.
+func sortCIDRs(cidrs []*net.IPNet) {
+	sort.Slice(cidrs, func(i, j int) bool {
+		if c := bytes.Compare(cidrs[i].IP, cidrs[j].IP); c != 0 {
+			return c < 0
+		}
+		onesI, _ := cidrs[i].Mask.Size()
+		onesJ, _ := cidrs[j].Mask.Size()
+		return onesI < onesJ
+	})
+}
+
+// ipToInt converts an IP address to an integer.
+func ipToInt(ip net.IP) *big.Int {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	return new(big.Int).SetBytes(ip)
+}
+
+// intToIP converts an integer back to an IP address of the given byte
+// length. It returns nil when the value does not fit.
+func intToIP(n *big.Int, size int) net.IP {
+	if n.Sign() < 0 || n.BitLen() > size*8 {
+		return nil
+	}
+	ip := make(net.IP, size)
+	n.FillBytes(ip)
+	return ip
+}
+
+// rangeToCIDRs returns the minimal list of blocks covering the addresses
+// from first to last inclusive. Both must be of the same address family.
+func rangeToCIDRs(first, last net.IP) ([]*net.IPNet, error) {
+	if v4 := first.To4(); v4 != nil {
+		first = v4
+	}
+	if v4 := last.To4(); v4 != nil {
+		last = v4
+	}
+	if len(first) != len(last) {
+		return nil, fmt.Errorf("range mixes address families: %s-%s", first, last)
+	}
+	bits := len(first) * 8
+	start, end := ipToInt(first), ipToInt(last)
+	if start.Cmp(end) > 0 {
+		return nil, fmt.Errorf("range start is after its end: %s-%s", first, last)
+	}
+
+	cidrs := []*net.IPNet{}
+	one := big.NewInt(1)
+	for start.Cmp(end) <= 0 {
+		// Grow the block while it stays aligned on start and within end.
+		size := 0
+		for size < bits && start.Bit(size) == 0 {
+			blockEnd := new(big.Int).Add(start, new(big.Int).Lsh(one, uint(size+1)))
+			if blockEnd.Sub(blockEnd, one).Cmp(end) > 0 {
+				break
+			}
+			size++
+		}
+		cidrs = append(cidrs, &net.IPNet{IP: intToIP(start, len(first)), Mask: net.CIDRMask(bits-size, bits)})
+		start = new(big.Int).Add(start, new(big.Int).Lsh(one, uint(size)))
+	}
+	return cidrs, nil
+}
+
+// intersectCIDRs returns the address space covered by both a and b.
+func intersectCIDRs(a, b []*net.IPNet) []*net.IPNet {
+	return subtractCIDRs(a, subtractCIDRs(a, b))
+}
+
+// cidrSize returns the number of addresses in a block.
+func cidrSize(cidr *net.IPNet) *big.Int {
+	ones, bits := cidr.Mask.Size()
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+}
+
+// outputVersion is the version of the JSON document written by saveToJSON.
+// It is bumped whenever the shape of cidrOutput changes incompatibly.
+const outputVersion = 1
+
+// cidrInfo describes a single block in the JSON output. Sources and
+// MergedFrom are only filled in when provenance is requested, Hits with
+// counted input.
+type cidrInfo struct {
+	CIDR       string       `json:"cidr"`
+	First      string       `json:"first"`
+	Last       string       `json:"last"`
+	Count      *big.Int     `json:"count"`
+	Sources    []cidrSource `json:"sources,omitempty"`
+	MergedFrom []string     `json:"mergedFrom,omitempty"`
+	Hits       *uint64      `json:"hits,omitempty"`
+}
+
+// cidrOutput is the versioned JSON document described by
+// schema/cidrs.schema.json.
+type cidrOutput struct {
+	Version     int        `json:"version"`
+	ProcessedAt string     `json:"processedAt,omitempty"`
+	CIDRs       []cidrInfo `json:"cidrs"`
+}
+
+// newCIDRInfo returns the output description of a block.
+func newCIDRInfo(cidr *net.IPNet) cidrInfo {
+	first, _ := nthAddress(cidr, big.NewInt(0))
+	last, _ := nthAddress(cidr, big.NewInt(-1))
+	return cidrInfo{
+		CIDR:  cidr.String(),
+		First: first.String(),
+		Last:  last.String(),
+		Count: cidrSize(cidr),
+	}
+}
+
+// newCIDROutput builds the JSON document for a list of blocks, sorted by
+// address so that the output is deterministic.
+func newCIDROutput(cidrs []*net.IPNet) cidrOutput {
+	sorted := make([]*net.IPNet, len(cidrs))
+	copy(sorted, cidrs)
+	sortCIDRs(sorted)
+
+	output := cidrOutput{Version: outputVersion, CIDRs: []cidrInfo{}}
+	for _, cidr := range sorted {
+		output.CIDRs = append(output.CIDRs, newCIDRInfo(cidr))
+	}
+	return output
+}
+
+// saveToJSON saves CIDRs to a JSON file. When compat is set the file holds a
+// plain array of CIDR strings, as written by earlier versions of the tool.
+func saveToJSON(filename string, cidrs []*net.IPNet, compat bool) error {
+	return writeJSONFile(filename, jsonDocument(cidrs, compat))
+}
+
+// jsonDocument returns the document saveToJSON writes.
+func jsonDocument(cidrs []*net.IPNet, compat bool) interface{} {
+	if compat {
+		var cidrStrings []string
+		for _, cidr := range cidrs {
+			cidrStrings = append(cidrStrings, cidr.String())
+		}
+		return cidrStrings
+	}
+	return newCIDROutput(cidrs)
+}
+
+// writeJSONFile writes v to the named file as indented JSON.
+func writeJSONFile(filename string, v interface{}) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("error creating file: %v", err)
@@ -176,44 +642,276 @@ func saveToJSON(filename string, cidrs []*net.IPNet) error {
 
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(cidrStrings); err != nil {
+	if err := encoder.Encode(v); err != nil {
 		return fmt.Errorf("error encoding JSON: %v", err)
 	}
 	return nil
 }
 
+// commands maps subcommand names to their handlers. Each handler receives
+// the arguments following the subcommand name.
+var commands = map[string]func(args []string) error{
+	"acl":         runACL,
+	"adjacent":    runAdjacent,
+	"analyze":     runAnalyze,
+	"apply":       runApply,
+	"calc":        runCalc,
+	"check":       runCheck,
+	"consume":     runConsume,
+	"contains":    runContains,
+	"dnsbl":       runDNSBL,
+	"draining":    runDraining,
+	"equal":       runEqual,
+	"generate":    runGenerate,
+	"geo":         runGeo,
+	"history":     runHistory,
+	"lint":        runLint,
+	"offset":      runOffset,
+	"overlaps":    runOverlaps,
+	"rir":         runRIR,
+	"serve":       runServe,
+	"split":       runSplit,
+	"sweep":       runSweep,
+	"talkers":     runTalkers,
+	"tree":        runTree,
+	"utilization": runUtilization,
+	"wildcard":    runWildcard,
+}
+
 func main() {
-	var cidrs []*net.IPNet
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	if err := runMerge(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
 
-	fmt.Println("Enter CIDR blocks, one per line. Enter an empty line to finish input:")
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			break
+// runMerge merges the CIDR blocks read from the files named in args and
+// saves the merged list. Without files it reads blocks from stdin
+// interactively, or from the local interfaces with -from-interfaces, and
+// then checks a single IP against the result.
+func runMerge(args []string) error {
+	fs := flag.NewFlagSet("cidr-converter", flag.ContinueOnError)
+	compat := fs.Bool("compat", false, "write the merged list as a plain JSON array of strings")
+	format := fs.String("output-format", "json", "format of the saved merged list: json or proto")
+	xlsxFile := fs.String("xlsx", "", "also write the merged list to this XLSX workbook")
+	fromInterfaces := fs.Bool("from-interfaces", false, "use the subnets of the local network interfaces as input")
+	provenance := fs.Bool("provenance", false, "record the sources of every merged block in the JSON output")
+	storeURL := fs.String("store", "", "merge with the set kept in this consul:// or etcd:// store and write the result back")
+	maxPrefixLen := fs.Int("max-prefix-len", 0, "aggregate adjacent blocks but never into blocks broader than this prefix length")
+	boundaryFile := fs.String("boundaries", "", "file of blocks that aggregation must not cross")
+	timing := fs.Bool("timing", false, "report the time spent in every stage and the peak heap in use")
+	lenient := fs.Bool("lenient", false, "recover from malformed lines in input files and report every problem")
+	parseReportFile := fs.String("parse-report", "", "with -lenient, write the problems found to this JSON file")
+	slack := fs.String("slack", "", "allow merging blocks that are not adjacent when this adds at most this many addresses or percent of the merged block, e.g. 256 or 10%")
+	explain := fs.Bool("explain", false, "explain how every merged block was formed from the input")
+	counted := fs.Bool("counts", false, "read input files as \"CIDR COUNT\" rows and report the summed count of every merged block")
+	minCount := fs.Uint64("min-count", 0, "with -counts, leave out blocks counted fewer times before aggregating")
+	countRatio := fs.Float64("count-ratio", 0, "with -counts, never aggregate two blocks when one was counted more than this many times as often as the other")
+	dryRun := fs.Bool("dry-run", false, "print the changes to the output files and store instead of making them")
+	metadataPolicy := fs.String("metadata-policy", "", "report entries whose names and tags conflict and resolve them: first-wins, last-wins or error")
+	draining := fs.String("draining", drainingInclude, "how blocks marked as draining are merged: include, exclude or expired to leave out those past their removal date")
+	blockFormat := fs.String("block-format", "cidr", "how printed blocks are written: cidr, netmask, slash-netmask or range")
+	httpFlags := addHTTPFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "proto" {
+		return fmt.Errorf("unknown output format: %s", *format)
+	}
+	formatBlock, err := blockFormatter(*blockFormat)
+	if err != nil {
+		return err
+	}
+	if *counted && *lenient {
+		return fmt.Errorf("-counts and -lenient cannot be combined")
+	}
+	if *countRatio != 0 && (!*counted || *countRatio < 1) {
+		return fmt.Errorf("-count-ratio requires -counts and a ratio of at least 1")
+	}
+	if *countRatio != 0 && *maxPrefixLen == 0 && *boundaryFile == "" && *slack == "" {
+		return fmt.Errorf("-count-ratio only limits aggregation, which needs -max-prefix-len, -boundaries or -slack")
+	}
+	if *maxPrefixLen < 0 || *maxPrefixLen > 128 {
+		return fmt.Errorf("invalid maximum prefix length: %d", *maxPrefixLen)
+	}
+	var timer *stageTimer
+	if *timing {
+		timer = newStageTimer()
+	}
+	aggregation := aggregateOptions{MaxPrefixLen: *maxPrefixLen, CountRatio: *countRatio}
+	if *boundaryFile != "" {
+		boundaries, err := readCIDRFile(*boundaryFile)
+		if err != nil {
+			return err
 		}
-		ipnet, err := parseCIDR(line)
-		if err == nil {
-			cidrs = append(cidrs, ipnet)
+		aggregation.Boundaries = boundaries
+	}
+	if *slack != "" {
+		limit, err := parseSlack(*slack)
+		if err != nil {
+			return err
+		}
+		aggregation.Slack = limit
+	}
+
+	var entries []inputEntry
+	interactive := fs.NArg() == 0
+	report := parseReport{Problems: []parseProblem{}}
+	for _, filename := range fs.Args() {
+		var fileEntries []inputEntry
+		var err error
+		if *lenient {
+			var problems []parseProblem
+			fileEntries, problems, err = readCIDRFileEntriesLenient(filename)
+			report.Problems = append(report.Problems, problems...)
+		} else if *counted {
+			fileEntries, err = readCountedFileEntries(filename)
 		} else {
-			fmt.Printf("Invalid input: %s\n", err)
+			fileEntries, err = readCIDRFileEntries(filename)
+		}
+		if err != nil {
+			return err
+		}
+		entries = append(entries, fileEntries...)
+	}
+	for _, problem := range report.Problems {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", problem)
+	}
+	if *parseReportFile != "" {
+		if err := saveJSON(*parseReportFile, report, *dryRun); err != nil {
+			return err
+		}
+	}
+	var store cidrStore
+	var stored []*net.IPNet
+	if *storeURL != "" {
+		var err error
+		if store, err = openStore(*storeURL, httpFlags.client()); err != nil {
+			return err
+		}
+		if stored, err = store.Load(context.Background()); err != nil {
+			return err
+		}
+		for _, cidr := range stored {
+			entries = append(entries, inputEntry{CIDR: cidr, File: *storeURL})
+		}
+	}
+	if *fromInterfaces {
+		ifaceCIDRs, err := interfaceCIDRs()
+		if err != nil {
+			return err
+		}
+		for _, cidr := range ifaceCIDRs {
+			entries = append(entries, inputEntry{CIDR: cidr, File: "interfaces"})
 		}
 	}
 
+	scanner := bufio.NewScanner(os.Stdin)
+	if interactive && !*fromInterfaces {
+		fmt.Println("Enter CIDR blocks, one per line. Enter an empty line to finish input:")
+		lineNum := 0
+		for scanner.Scan() {
+			lineNum++
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				break
+			}
+			ipnet, err := parseCIDR(line)
+			if err == nil {
+				entries = append(entries, inputEntry{CIDR: ipnet, File: "stdin", Line: lineNum})
+			} else {
+				fmt.Printf("Invalid input: %s\n", err)
+			}
+		}
+	}
+
+	timer.mark("read")
+
+	if *metadataPolicy != "" {
+		var conflicts []metadataConflict
+		var err error
+		entries, conflicts, err = resolveMetadataConflicts(entries, *metadataPolicy)
+		for _, conflict := range conflicts {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", conflict)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	entries, drained, err := filterDraining(entries, *draining, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if drained > 0 {
+		fmt.Printf("Left out %d draining blocks\n", drained)
+	}
+
+	if *counted && *minCount > 0 {
+		var dropped int
+		entries, dropped = dropBelowCount(entries, *minCount)
+		if dropped > 0 {
+			fmt.Printf("Left out %d blocks counted fewer than %d times\n", dropped, *minCount)
+		}
+	}
+	aggregation.Counts = entries
+
 	// Deduplicate CIDRs
+	var cidrs []*net.IPNet
+	for _, entry := range entries {
+		cidrs = append(cidrs, entry.CIDR)
+	}
 	cidrs = deduplicateCIDRs(cidrs)
+	timer.mark("dedupe")
 
 	// Aggregate and merge CIDRs
-	mergedCIDRs := aggregateCIDRs(mergeCIDRs(cidrs))
+	mergedCIDRs := mergeCIDRs(cidrs)
+	timer.mark("merge")
+	mergedCIDRs = aggregateCIDRs(mergedCIDRs)
+	var slackExtra []*net.IPNet
+	if aggregation.enabled() {
+		input := mergedCIDRs
+		mergedCIDRs = aggregateWith(mergedCIDRs, aggregation)
+		slackExtra = subtractCIDRs(mergedCIDRs, input)
+	}
+	timer.mark("aggregate")
 
 	fmt.Println("Merged and deduplicated CIDRs:")
-	for _, cidr := range mergedCIDRs {
-		fmt.Println(cidr)
+	if *counted {
+		for i, count := range attributeCounts(mergedCIDRs, entries) {
+			fmt.Printf("%s %d\n", formatBlock(mergedCIDRs[i]), count)
+		}
+	} else {
+		for _, cidr := range mergedCIDRs {
+			fmt.Println(formatBlock(cidr))
+		}
+	}
+	if len(slackExtra) > 0 {
+		fmt.Println("\nExtra space included by -slack:")
+		for _, cidr := range slackExtra {
+			fmt.Println(formatBlock(cidr))
+		}
+	}
+	if *explain {
+		fmt.Println("\nExplanation:")
+		explainMerge(os.Stdout, mergedCIDRs, entries)
 	}
+	timer.mark("print")
 
 	// Check if an IP belongs to any CIDR
-	fmt.Println("\nEnter an IP address to check:")
-	if scanner.Scan() {
+	if interactive {
+		fmt.Println("\nEnter an IP address to check:")
+	}
+	if interactive && scanner.Scan() {
 		ipInput := strings.TrimSpace(scanner.Text())
 		matches, err := ipBelongsToCIDR(ipInput, mergedCIDRs)
 		if err != nil {
@@ -228,11 +926,73 @@ func main() {
 		}
 	}
 
-	// Save merged CIDRs to a JSON file
-	outputFile := "merged_cidrs.json"
-	if err := saveToJSON(outputFile, mergedCIDRs); err != nil {
-		fmt.Printf("Error saving JSON: %s\n", err)
+	timer.mark("lookup")
+
+	// Save merged CIDRs to a JSON or protobuf file
+	if *dryRun {
+		fmt.Println()
+	}
+	if *format == "proto" {
+		outputFile := "merged_cidrs.pb"
+		if *dryRun {
+			if err := previewFile(os.Stdout, outputFile, marshalCIDROutput(newCIDROutput(mergedCIDRs))); err != nil {
+				fmt.Printf("Error: %s\n", err)
+			}
+		} else if err := saveToProto(outputFile, mergedCIDRs); err != nil {
+			fmt.Printf("Error saving protobuf: %s\n", err)
+		} else {
+			fmt.Printf("\nMerged CIDRs saved to %s\n", outputFile)
+		}
 	} else {
-		fmt.Printf("\nMerged CIDRs saved to %s\n", outputFile)
+		outputFile := "merged_cidrs.json"
+		document := jsonDocument(mergedCIDRs, *compat)
+		if (*provenance || *counted) && !*compat {
+			output := newCIDROutput(mergedCIDRs)
+			if *provenance {
+				addProvenance(&output, entries, time.Now())
+			}
+			if *counted {
+				addCounts(&output, entries)
+			}
+			document = output
+		}
+		if err := saveJSON(outputFile, document, *dryRun); err != nil {
+			fmt.Printf("Error saving JSON: %s\n", err)
+		} else if !*dryRun {
+			fmt.Printf("\nMerged CIDRs saved to %s\n", outputFile)
+		}
+	}
+
+	if store != nil {
+		if *dryRun {
+			previewStore(os.Stdout, *storeURL, stored, mergedCIDRs)
+		} else if err := store.Save(context.Background(), mergedCIDRs); err != nil {
+			fmt.Printf("Error saving to store: %s\n", err)
+		} else {
+			fmt.Printf("Merged CIDRs saved to %s\n", *storeURL)
+		}
 	}
+
+	if *xlsxFile != "" {
+		if *dryRun {
+			var buf bytes.Buffer
+			err := writeXLSX(&buf, mergedCIDRs, entries)
+			if err == nil {
+				err = previewFile(os.Stdout, *xlsxFile, buf.Bytes())
+			}
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+			}
+		} else if err := saveToXLSX(*xlsxFile, mergedCIDRs, entries); err != nil {
+			fmt.Printf("Error saving XLSX: %s\n", err)
+		} else {
+			fmt.Printf("Merged CIDRs saved to %s\n", *xlsxFile)
+		}
+	}
+	timer.mark("save")
+	if timer != nil {
+		fmt.Println()
+		timer.report(os.Stdout)
+	}
+	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"net"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -123,6 +124,16 @@ func TestParseWildcard(t *testing.T) {
 			want:    "192.168.0.0/16",
 			wantErr: false,
 		},
+		{
+			name:  "No wildcard",
+			input: "10.1.2.3",
+			want:  "10.1.2.3/32",
+		},
+		{
+			name:  "All wildcards",
+			input: "*.*.*.*",
+			want:  "0.0.0.0/0",
+		},
 		{
 			name:    "Invalid wildcard format",
 			input:   "192.168.*",
@@ -133,6 +144,41 @@ func TestParseWildcard(t *testing.T) {
 			input:   "256.168.*.*",
 			wantErr: true,
 		},
+		{
+			name:    "Invalid octet after a wildcard",
+			input:   "10.*.300.*",
+			wantErr: true,
+		},
+		{
+			name:    "Leading zero",
+			input:   "10.01.*.*",
+			wantErr: true,
+		},
+		{
+			name:    "Negative octet",
+			input:   "10.-0.*.*",
+			wantErr: true,
+		},
+		{
+			name:    "Signed octet",
+			input:   "10.+1.*.*",
+			wantErr: true,
+		},
+		{
+			name:    "Empty octet",
+			input:   "10..*.*",
+			wantErr: true,
+		},
+		{
+			name:    "Partial wildcard octet",
+			input:   "10.1*.*.*",
+			wantErr: true,
+		},
+		{
+			name:    "Too many blocks",
+			input:   "*.*.*.1",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -142,8 +188,35 @@ func TestParseWildcard(t *testing.T) {
 				t.Errorf("parseWildcard() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !tt.wantErr && got[0].String() != tt.want {
-				t.Errorf("parseWildcard() = %v, want %v", got[0].String(), tt.want)
+			if !tt.wantErr && joinCIDRs(got) != tt.want {
+				t.Errorf("parseWildcard() = %v, want %v", joinCIDRs(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWildcardExpansion(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		count int
+		first string
+		last  string
+	}{
+		{name: "Inner wildcard", input: "10.*.3.*", count: 256, first: "10.0.3.0/24", last: "10.255.3.0/24"},
+		{name: "Leading wildcard", input: "*.1.2.3", count: 256, first: "0.1.2.3/32", last: "255.1.2.3/32"},
+		{name: "Two inner wildcards", input: "10.*.*.4", count: 65536, first: "10.0.0.4/32", last: "10.255.255.4/32"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWildcard(tt.input)
+			if err != nil {
+				t.Fatalf("parseWildcard() error = %v", err)
+			}
+			if len(got) != tt.count || got[0].String() != tt.first || got[len(got)-1].String() != tt.last {
+				t.Errorf("parseWildcard() = %d blocks from %v to %v, want %d from %s to %s",
+					len(got), got[0], got[len(got)-1], tt.count, tt.first, tt.last)
 			}
 		})
 	}
@@ -160,6 +233,38 @@ func TestMergeCIDRs(t *testing.T) {
 	}
 }
 
+func TestPruneContained(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Disjoint blocks", input: "192.168.1.0/24\n192.168.0.0/24\n", want: "192.168.0.0/24,192.168.1.0/24"},
+		{name: "Later block contains an earlier one", input: "10.1.0.0/16\n10.0.0.0/8\n", want: "10.0.0.0/8"},
+		{name: "Same network address", input: "10.0.0.0/24\n10.0.0.0/16\n10.0.0.0/8\n", want: "10.0.0.0/8"},
+		{name: "Duplicates", input: "10.0.0.0/24\n10.0.0.0/24\n", want: "10.0.0.0/24"},
+		{name: "Nested chain", input: "10.0.0.128/25\n10.0.0.0/24\n10.0.0.192/26\n10.0.1.0/24\n", want: "10.0.0.0/24,10.0.1.0/24"},
+		{name: "Siblings are not combined", input: "10.0.0.0/25\n10.0.0.128/25\n", want: "10.0.0.0/25,10.0.0.128/25"},
+		{
+			name:  "Interleaved families",
+			input: "10.0.0.0/8\na00::/16\n10.1.0.0/16\n2001:db8::/32\n2001:db8:1::/48\n",
+			want:  "10.0.0.0/8,a00::/16,2001:db8::/32",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cidrs, err := parseCIDRList(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("parseCIDRList() error = %v", err)
+			}
+			if got := joinCIDRs(pruneContained(cidrs)); got != tt.want {
+				t.Errorf("pruneContained() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestAggregateCIDRs(t *testing.T) {
 	_, net1, _ := net.ParseCIDR("192.168.0.0/24")
 	_, net2, _ := net.ParseCIDR("192.168.1.0/24")
@@ -169,22 +274,242 @@ func TestAggregateCIDRs(t *testing.T) {
 	if !reflect.DeepEqual(result, input) {
 		t.Errorf("aggregateCIDRs() = %v, want %v", result, input)
 	}
+
+	_, dup, _ := net.ParseCIDR("192.168.0.0/24")
+	result = aggregateCIDRs([]*net.IPNet{net1, dup, net2})
+	if got := joinCIDRs(result); got != "192.168.0.0/23,192.168.1.0/24" {
+		t.Errorf("aggregateCIDRs() with a duplicate = %q, want %q", got, "192.168.0.0/23,192.168.1.0/24")
+	}
+}
+
+func TestSiblingParent(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "Siblings", a: "192.168.0.0/24", b: "192.168.1.0/24", want: "192.168.0.0/23"},
+		{name: "Adjacent but not siblings", a: "192.168.1.0/24", b: "192.168.2.0/24", want: ""},
+		{name: "Different sizes", a: "192.168.0.0/24", b: "192.168.1.0/25", want: ""},
+		{name: "Same block", a: "192.168.0.0/24", b: "192.168.0.0/24", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, a, _ := net.ParseCIDR(tt.a)
+			_, b, _ := net.ParseCIDR(tt.b)
+			got := siblingParent(a, b)
+			if (got == nil) != (tt.want == "") || (got != nil && got.String() != tt.want) {
+				t.Errorf("siblingParent() = %v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollapseCIDRs(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Siblings merged", input: "10.0.0.1/32\n10.0.0.0/32\n10.0.0.2/31\n", want: "10.0.0.0/30"},
+		{name: "Contained block dropped", input: "10.0.1.0/24\n10.0.0.0/16\n", want: "10.0.0.0/16"},
+		{name: "Non-siblings kept", input: "10.0.1.0/24\n10.0.2.0/24\n", want: "10.0.1.0/24,10.0.2.0/24"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cidrs, _ := parseCIDRList(strings.NewReader(tt.input))
+			if got := joinCIDRs(collapseCIDRs(cidrs)); got != tt.want {
+				t.Errorf("collapseCIDRs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoveredByUnion(t *testing.T) {
+	_, low, _ := net.ParseCIDR("10.0.0.0/25")
+	_, high, _ := net.ParseCIDR("10.0.0.128/26")
+	set := []*net.IPNet{low, high}
+
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{name: "Contained in one block", input: "10.0.0.0/26", want: true},
+		{name: "Covered by two blocks", input: "10.0.0.0/25", want: true},
+		{name: "Spans both blocks", input: "10.0.0.64/26", want: true},
+		{name: "Partially covered", input: "10.0.0.0/24", want: false},
+		{name: "Disjoint", input: "10.0.1.0/24", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cidr, _ := net.ParseCIDR(tt.input)
+			if got := coveredByUnion(cidr, set); got != tt.want {
+				t.Errorf("coveredByUnion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCIDRList(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "CIDR and wildcard entries",
+			input: "# comment\n10.0.0.0/8\n\n192.168.*.*\n",
+			want:  "10.0.0.0/8,192.168.0.0/16",
+		},
+		{
+			name:    "Invalid entry",
+			input:   "10.0.0.0/8\nbogus\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCIDRList(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCIDRList() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && joinCIDRs(got) != tt.want {
+				t.Errorf("parseCIDRList() = %q, want %q", joinCIDRs(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestSubtractCIDRs(t *testing.T) {
+	_, from, _ := net.ParseCIDR("10.0.0.0/24")
+	_, remove, _ := net.ParseCIDR("10.0.0.64/26")
+
+	got := joinCIDRs(subtractCIDRs([]*net.IPNet{from}, []*net.IPNet{remove}))
+	want := "10.0.0.0/26,10.0.0.128/25"
+	if got != want {
+		t.Errorf("subtractCIDRs() = %q, want %q", got, want)
+	}
+}
+
+func TestRangeToCIDRs(t *testing.T) {
+	tests := []struct {
+		name    string
+		first   string
+		last    string
+		want    string
+		wantErr bool
+	}{
+		{name: "Aligned block", first: "10.0.0.0", last: "10.0.0.255", want: "10.0.0.0/24"},
+		{name: "Unaligned range", first: "10.0.0.1", last: "10.0.0.6", want: "10.0.0.1/32,10.0.0.2/31,10.0.0.4/31,10.0.0.6/32"},
+		{name: "Single address", first: "192.168.1.1", last: "192.168.1.1", want: "192.168.1.1/32"},
+		{name: "Whole IPv4 space", first: "0.0.0.0", last: "255.255.255.255", want: "0.0.0.0/0"},
+		{name: "IPv6", first: "2001:db8::", last: "2001:db8::1:ffff", want: "2001:db8::/111"},
+		{name: "Reversed", first: "10.0.0.2", last: "10.0.0.1", wantErr: true},
+		{name: "Mixed families", first: "10.0.0.1", last: "::1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rangeToCIDRs(net.ParseIP(tt.first), net.ParseIP(tt.last))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("rangeToCIDRs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && joinCIDRs(got) != tt.want {
+				t.Errorf("rangeToCIDRs() = %q, want %q", joinCIDRs(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestIntersectCIDRs(t *testing.T) {
+	a, _ := parseCIDRList(strings.NewReader("10.0.0.0/24\n192.168.0.0/16\n"))
+	b, _ := parseCIDRList(strings.NewReader("10.0.0.128/25\n192.168.1.0/24\n172.16.0.0/12\n"))
+
+	if got := joinCIDRs(intersectCIDRs(a, b)); got != "10.0.0.128/25,192.168.1.0/24" {
+		t.Errorf("intersectCIDRs() = %q", got)
+	}
 }
 
 func TestSaveToJSON(t *testing.T) {
-	_, net1, _ := net.ParseCIDR("192.168.0.0/24")
-	_, net2, _ := net.ParseCIDR("192.168.1.0/24")
+	_, net1, _ := net.ParseCIDR("192.168.1.0/24")
+	_, net2, _ := net.ParseCIDR("192.168.0.0/24")
 	cidrs := []*net.IPNet{net1, net2}
 
-	tempFile := "test_cidrs.json"
-	err := saveToJSON(tempFile, cidrs)
-	if err != nil {
-		t.Errorf("saveToJSON() error = %v", err)
+	tests := []struct {
+		name   string
+		compat bool
+		want   string
+	}{
+		{
+			name:   "Versioned output",
+			compat: false,
+			want: `{
+  "version": 1,
+  "cidrs": [
+    {
+      "cidr": "192.168.0.0/24",
+      "first": "192.168.0.0",
+      "last": "192.168.0.255",
+      "count": 256
+    },
+    {
+      "cidr": "192.168.1.0/24",
+      "first": "192.168.1.0",
+      "last": "192.168.1.255",
+      "count": 256
+    }
+  ]
+}
+`,
+		},
+		{
+			name:   "Compat output",
+			compat: true,
+			want: `[
+  "192.168.1.0/24",
+  "192.168.0.0/24"
+]
+`,
+		},
 	}
-	// Clean up
-	if err := deleteFile(tempFile); err != nil {
-		t.Logf("Warning: Failed to delete test file: %v", err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempFile := "test_cidrs.json"
+			err := saveToJSON(tempFile, cidrs, tt.compat)
+			if err != nil {
+				t.Errorf("saveToJSON() error = %v", err)
+			}
+			got, err := os.ReadFile(tempFile)
+			if err != nil {
+				t.Errorf("Failed to read test file: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("saveToJSON() wrote\n%s\nwant\n%s", got, tt.want)
+			}
+			// Clean up
+			if err := deleteFile(tempFile); err != nil {
+				t.Logf("Warning: Failed to delete test file: %v", err)
+			}
+		})
+	}
+}
+
+// Helper function to render CIDRs as a comma-separated string
+func joinCIDRs(cidrs []*net.IPNet) string {
+	parts := make([]string, len(cidrs))
+	for i, cidr := range cidrs {
+		parts[i] = cidr.String()
 	}
+	return strings.Join(parts, ",")
 }
 
 // Helper function to delete test files
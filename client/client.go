@@ -0,0 +1,149 @@
+// Package client is a typed Go client for the REST endpoints served by
+// "cidr-converter serve", as described in api/openapi.yaml.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CIDRInfo describes a single block.
+type CIDRInfo struct {
+	CIDR  string   `json:"cidr"`
+	First string   `json:"first"`
+	Last  string   `json:"last"`
+	Count *big.Int `json:"count"`
+}
+
+// CIDROutput is a versioned list of blocks sorted by network address.
+type CIDROutput struct {
+	Version int        `json:"version"`
+	CIDRs   []CIDRInfo `json:"cidrs"`
+}
+
+// LookupResult lists the blocks containing a looked up address.
+type LookupResult struct {
+	IP    string     `json:"ip"`
+	Match bool       `json:"match"`
+	CIDRs []CIDRInfo `json:"cidrs"`
+	// Sets names the matching scheduled sets when the server runs with a
+	// schedule.
+	Sets []string `json:"sets,omitempty"`
+}
+
+// PickResult is the address assigned to a key by the server.
+type PickResult struct {
+	Key    string `json:"key"`
+	IP     string `json:"ip"`
+	Method string `json:"method"`
+}
+
+// Error is returned for non-2xx responses.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("cidr-converter: %d: %s", e.StatusCode, e.Message)
+}
+
+// Client calls a cidr-converter server.
+type Client struct {
+	// BaseURL is the server address, e.g. "http://localhost:8080".
+	BaseURL string
+	// HTTPClient is used for requests; http.DefaultClient when nil.
+	HTTPClient *http.Client
+}
+
+// New returns a client for the server at baseURL.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// CIDRs returns the set served by the server.
+func (c *Client) CIDRs(ctx context.Context) (*CIDROutput, error) {
+	var out CIDROutput
+	if err := c.do(ctx, http.MethodGet, "/v1/cidrs", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Lookup reports which served blocks contain ip.
+func (c *Client) Lookup(ctx context.Context, ip string) (*LookupResult, error) {
+	var out LookupResult
+	if err := c.do(ctx, http.MethodGet, "/v1/lookup?ip="+url.QueryEscape(ip), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Pick returns the address of the served set assigned to key with method,
+// as computed locally by a Picker for the same set.
+func (c *Client) Pick(ctx context.Context, key string, method PickMethod) (*PickResult, error) {
+	var out PickResult
+	path := "/v1/pick?key=" + url.QueryEscape(key) + "&method=" + method.String()
+	if err := c.do(ctx, http.MethodGet, path, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Merge merges cidrs into a minimal list on the server.
+func (c *Client) Merge(ctx context.Context, cidrs []string) (*CIDROutput, error) {
+	body, err := json.Marshal(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	var out CIDROutput
+	if err := c.do(ctx, http.MethodPost, "/v1/merge", body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			apiErr.Error = http.StatusText(resp.StatusCode)
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: apiErr.Error}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("cidr-converter: decoding response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/cidrs":
+			io.WriteString(w, `{"version":1,"cidrs":[{"cidr":"10.0.0.0/8","first":"10.0.0.0","last":"10.255.255.255","count":16777216}]}`)
+		case "/v1/lookup":
+			if r.URL.Query().Get("ip") != "10.1.2.3" {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"error":"invalid IP address"}`)
+				return
+			}
+			io.WriteString(w, `{"ip":"10.1.2.3","match":true,"cidrs":[{"cidr":"10.0.0.0/8","first":"10.0.0.0","last":"10.255.255.255","count":16777216}]}`)
+		case "/v1/merge":
+			body, _ := io.ReadAll(r.Body)
+			if r.Method != http.MethodPost || string(body) != `["10.0.0.0/9","10.128.0.0/9"]` {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"error":"unexpected request"}`)
+				return
+			}
+			io.WriteString(w, `{"version":1,"cidrs":[{"cidr":"10.0.0.0/8","first":"10.0.0.0","last":"10.255.255.255","count":16777216}]}`)
+		}
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL + "/")
+	ctx := context.Background()
+
+	out, err := c.CIDRs(ctx)
+	if err != nil || len(out.CIDRs) != 1 || out.CIDRs[0].Count.Int64() != 16777216 {
+		t.Errorf("CIDRs() = %+v, %v", out, err)
+	}
+
+	result, err := c.Lookup(ctx, "10.1.2.3")
+	if err != nil || !result.Match || result.CIDRs[0].CIDR != "10.0.0.0/8" {
+		t.Errorf("Lookup() = %+v, %v", result, err)
+	}
+
+	merged, err := c.Merge(ctx, []string{"10.0.0.0/9", "10.128.0.0/9"})
+	if err != nil || merged.CIDRs[0].CIDR != "10.0.0.0/8" {
+		t.Errorf("Merge() = %+v, %v", merged, err)
+	}
+
+	_, err = c.Lookup(ctx, "bogus")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "invalid IP address" {
+		t.Errorf("Lookup() error = %v, want API error", err)
+	}
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"net"
+	"strings"
+)
+
+// ClientIP returns the address of the client behind a chain of proxies.
+// The set lists the trusted proxies, remoteAddr is the address of the
+// connection (with or without a port) and forwardedFor holds the values of
+// the request's X-Forwarded-For headers in order.
+//
+// The chain is walked from the connection backwards: each hop added by a
+// trusted proxy is believed, and the first address not in the set is the
+// client. Entries left of it are ignored since the client controls them.
+// When every hop is trusted the leftmost one is returned. Addresses that
+// cannot be parsed are reported as a *ParseError wrapping ErrInvalidIP.
+func (s CIDRSet) ClientIP(remoteAddr string, forwardedFor ...string) (net.IP, error) {
+	ip := parseHost(remoteAddr)
+	if ip == nil {
+		return nil, &ParseError{Input: remoteAddr, Err: ErrInvalidIP}
+	}
+
+	var hops []string
+	for _, value := range forwardedFor {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0 && s.Contains(ip); i-- {
+		hop := parseHost(hops[i])
+		if hop == nil {
+			return nil, &ParseError{Input: hops[i], Err: ErrInvalidIP}
+		}
+		ip = hop
+	}
+	return ip, nil
+}
+
+// parseHost parses an address with or without a port.
+func parseHost(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(strings.Trim(addr, "[]"))
+}
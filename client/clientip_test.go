@@ -0,0 +1,49 @@
+package client
+
+import "testing"
+
+func TestCIDRSetClientIP(t *testing.T) {
+	trusted, _ := ParseCIDRSet("10.0.0.0/8,2001:db8::/32")
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+		wantErr      bool
+	}{
+		{name: "Direct client", remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "Untrusted peer ignores the header", remoteAddr: "192.0.2.1:1234", forwardedFor: []string{"198.51.100.1"}, want: "192.0.2.1"},
+		{name: "One trusted proxy", remoteAddr: "10.0.0.1:1234", forwardedFor: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{
+			name:         "Chain of trusted proxies",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"198.51.100.1, 10.0.0.3", "10.0.0.2"},
+			want:         "198.51.100.1",
+		},
+		{
+			name:         "Spoofed entries left of the client",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"10.9.9.9, 198.51.100.1"},
+			want:         "198.51.100.1",
+		},
+		{name: "All hops trusted", remoteAddr: "10.0.0.1:1234", forwardedFor: []string{"10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		{name: "IPv6 proxy", remoteAddr: "[2001:db8::1]:443", forwardedFor: []string{"2001:db8::2, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "No port", remoteAddr: "10.0.0.1", forwardedFor: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "Invalid hop", remoteAddr: "10.0.0.1:1234", forwardedFor: []string{"unknown"}, wantErr: true},
+		{name: "Invalid hop beyond the client", remoteAddr: "10.0.0.1:1234", forwardedFor: []string{"unknown, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "Invalid remote address", remoteAddr: "pipe", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, err := trusted.ClientIP(tt.remoteAddr, tt.forwardedFor...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ClientIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && ip.String() != tt.want {
+				t.Errorf("ClientIP() = %s, want %s", ip, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors reported, wrapped in a *ParseError, for input that cannot be
+// parsed. Use errors.Is to tell them apart.
+var (
+	ErrInvalidCIDR = errors.New("invalid CIDR block")
+	ErrInvalidIP   = errors.New("invalid IP address")
+)
+
+// ParseError is returned for a block or address that cannot be parsed.
+type ParseError struct {
+	// Input is the text that failed to parse.
+	Input string
+	// Line and Column locate Input, both 1-based. Line is 0 for single-line
+	// input such as a flag value, and Column is 0 when the position is not
+	// known.
+	Line   int
+	Column int
+	// Err is ErrInvalidCIDR or ErrInvalidIP.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	switch {
+	case e.Line > 0:
+		return fmt.Sprintf("cidr-converter: line %d, column %d: %v %q", e.Line, e.Column, e.Err, e.Input)
+	case e.Column > 0:
+		return fmt.Sprintf("cidr-converter: column %d: %v %q", e.Column, e.Err, e.Input)
+	}
+	return fmt.Sprintf("cidr-converter: %v %q", e.Err, e.Input)
+}
+
+// Unwrap returns Err, so that errors.Is matches ErrInvalidCIDR and
+// ErrInvalidIP.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		parse      func() error
+		wantErr    error
+		wantLine   int
+		wantColumn int
+		wantInput  string
+	}{
+		{
+			name:      "NewCIDRInfo",
+			parse:     func() error { _, err := NewCIDRInfo("10.0.0.0/33"); return err },
+			wantErr:   ErrInvalidCIDR,
+			wantInput: "10.0.0.0/33",
+		},
+		{
+			name:       "CIDR set",
+			parse:      func() error { _, err := ParseCIDRSet("10.0.0.0/8, bogus"); return err },
+			wantErr:    ErrInvalidCIDR,
+			wantColumn: 13,
+			wantInput:  "bogus",
+		},
+		{
+			name: "CIDR list",
+			parse: func() error {
+				_, err := ParseCIDRList(strings.NewReader("# allow\n10.0.0.0/8\n  10.0.0.1\n"))
+				return err
+			},
+			wantErr:    ErrInvalidCIDR,
+			wantLine:   3,
+			wantColumn: 3,
+			wantInput:  "10.0.0.1",
+		},
+		{
+			name:      "JSON set",
+			parse:     func() error { var s CIDRSet; return json.Unmarshal([]byte(`[{"cidr":"10.0.0.0"}]`), &s) },
+			wantErr:   ErrInvalidCIDR,
+			wantInput: "10.0.0.0",
+		},
+		{
+			name:      "Remote address",
+			parse:     func() error { _, err := CIDRSet(nil).ClientIP("bogus:80"); return err },
+			wantErr:   ErrInvalidIP,
+			wantInput: "bogus:80",
+		},
+		{
+			name: "Forwarded address",
+			parse: func() error {
+				trusted, _ := ParseCIDRSet("10.0.0.0/8")
+				_, err := trusted.ClientIP("10.0.0.1:80", "unknown")
+				return err
+			},
+			wantErr:   ErrInvalidIP,
+			wantInput: "unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.parse()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("error = %T, want *ParseError", err)
+			}
+			if parseErr.Input != tt.wantInput || parseErr.Line != tt.wantLine || parseErr.Column != tt.wantColumn {
+				t.Errorf("ParseError = %+v, want input %q at line %d, column %d", parseErr, tt.wantInput, tt.wantLine, tt.wantColumn)
+			}
+		})
+	}
+}
+
+func TestParseErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  *ParseError
+		want string
+	}{
+		{err: &ParseError{Input: "x", Err: ErrInvalidCIDR}, want: `cidr-converter: invalid CIDR block "x"`},
+		{err: &ParseError{Input: "x", Column: 4, Err: ErrInvalidCIDR}, want: `cidr-converter: column 4: invalid CIDR block "x"`},
+		{err: &ParseError{Input: "x", Line: 2, Column: 1, Err: ErrInvalidIP}, want: `cidr-converter: line 2, column 1: invalid IP address "x"`},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestParseCIDRList(t *testing.T) {
+	set, err := ParseCIDRList(strings.NewReader("10.0.0.0/8\n\n# comment\n2001:db8::/32\n"))
+	if err != nil || set.String() != "10.0.0.0/8,2001:db8::/32" {
+		t.Errorf("ParseCIDRList() = %v, %v", set, err)
+	}
+}
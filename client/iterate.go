@@ -0,0 +1,74 @@
+package client
+
+import (
+	"fmt"
+	"net"
+)
+
+// IPNetAddrs calls yield with every address of ipnet in order, stopping
+// early when yield returns false. Addresses are generated one at a time, so
+// huge blocks can be streamed without building a slice. Each address passed
+// to yield is a fresh copy the callback may keep.
+func IPNetAddrs(ipnet *net.IPNet, yield func(ip net.IP) bool) {
+	ip := networkIP(ipnet)
+	for {
+		if !yield(append(net.IP(nil), ip...)) {
+			return
+		}
+		if !incrementIP(ip) || !ipnet.Contains(ip) {
+			return
+		}
+	}
+}
+
+// Subnets calls yield with every block of prefix length newPrefix inside
+// ipnet in order, stopping early when yield returns false. Like IPNetAddrs
+// it streams the blocks, so a /8 can be walked in /32s without building a
+// slice. It fails when newPrefix is shorter than the prefix of ipnet or
+// longer than the address.
+func Subnets(ipnet *net.IPNet, newPrefix int, yield func(subnet *net.IPNet) bool) error {
+	ones, bits := ipnet.Mask.Size()
+	if newPrefix < ones || newPrefix > bits {
+		return fmt.Errorf("cidr-converter: cannot split %s into /%d blocks", ipnet, newPrefix)
+	}
+	mask := net.CIDRMask(newPrefix, bits)
+	ip := networkIP(ipnet)
+	for {
+		if !yield(&net.IPNet{IP: append(net.IP(nil), ip...), Mask: mask}) {
+			return nil
+		}
+		if !addBit(ip, newPrefix-1) || !ipnet.Contains(ip) {
+			return nil
+		}
+	}
+}
+
+// networkIP returns a copy of the network address of ipnet, in 4-byte form
+// for IPv4 blocks.
+func networkIP(ipnet *net.IPNet) net.IP {
+	ip := ipnet.IP.Mask(ipnet.Mask)
+	return append(net.IP(nil), ip...)
+}
+
+// incrementIP adds one to ip in place. It returns false when the address
+// wrapped around past the end of the address space.
+func incrementIP(ip net.IP) bool {
+	return addBit(ip, len(ip)*8-1)
+}
+
+// addBit adds 2^(len(ip)*8-1-bit) to ip in place, i.e. one at the given bit
+// position counted from the most significant bit. It returns false on
+// overflow.
+func addBit(ip net.IP, bit int) bool {
+	if bit < 0 {
+		return false
+	}
+	i := bit / 8
+	carry := uint(0x80 >> uint(bit%8))
+	for ; i >= 0 && carry != 0; i-- {
+		sum := uint(ip[i]) + carry
+		ip[i] = byte(sum)
+		carry = sum >> 8
+	}
+	return carry == 0
+}
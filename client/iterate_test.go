@@ -0,0 +1,73 @@
+package client
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestIPNetAddrs(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		limit int
+		want  string
+	}{
+		{name: "Small block", input: "10.0.0.4/30", want: "10.0.0.4,10.0.0.5,10.0.0.6,10.0.0.7"},
+		{name: "Crosses an octet", input: "10.0.0.255/32", want: "10.0.0.255"},
+		{name: "End of the address space", input: "255.255.255.254/31", want: "255.255.255.254,255.255.255.255"},
+		{name: "Stops early", input: "10.0.0.0/8", limit: 3, want: "10.0.0.0,10.0.0.1,10.0.0.2"},
+		{name: "IPv6", input: "2001:db8::fe/127", want: "2001:db8::fe,2001:db8::ff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cidr, _ := net.ParseCIDR(tt.input)
+			var got []string
+			IPNetAddrs(cidr, func(ip net.IP) bool {
+				got = append(got, ip.String())
+				return tt.limit == 0 || len(got) < tt.limit
+			})
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("IPNetAddrs() = %q, want %q", strings.Join(got, ","), tt.want)
+			}
+		})
+	}
+}
+
+func TestSubnets(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		newPrefix int
+		limit     int
+		want      string
+		wantErr   bool
+	}{
+		{name: "Quarters", input: "10.0.0.0/22", newPrefix: 24, want: "10.0.0.0/24,10.0.1.0/24,10.0.2.0/24,10.0.3.0/24"},
+		{name: "Same size", input: "10.0.0.0/24", newPrefix: 24, want: "10.0.0.0/24"},
+		{name: "End of the address space", input: "255.255.255.0/24", newPrefix: 25, want: "255.255.255.0/25,255.255.255.128/25"},
+		{name: "Stops early", input: "10.0.0.0/8", newPrefix: 24, limit: 2, want: "10.0.0.0/24,10.0.1.0/24"},
+		{name: "IPv6", input: "2001:db8::/47", newPrefix: 48, want: "2001:db8::/48,2001:db8:1::/48"},
+		{name: "Broader prefix", input: "10.0.0.0/24", newPrefix: 16, wantErr: true},
+		{name: "Prefix too long", input: "10.0.0.0/24", newPrefix: 33, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cidr, _ := net.ParseCIDR(tt.input)
+			var got CIDRSet
+			err := Subnets(cidr, tt.newPrefix, func(subnet *net.IPNet) bool {
+				got = append(got, subnet)
+				return tt.limit == 0 || len(got) < tt.limit
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Subnets() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("Subnets() = %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
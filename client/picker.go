@@ -0,0 +1,114 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// PickMethod selects how a Picker maps keys to addresses.
+type PickMethod int
+
+const (
+	// HashMod maps a key to its hash modulo the pool size. Every address
+	// of the pool is used, but resizing the pool moves most keys.
+	HashMod PickMethod = iota
+	// Consistent uses jump consistent hashing: when addresses are appended
+	// to the pool, only the keys moving to the new addresses change. Pools
+	// are limited to 2^53 addresses.
+	Consistent
+)
+
+// maxConsistentPool is the largest pool supported by Consistent, beyond
+// which the float arithmetic of jump hashing loses precision.
+const maxConsistentPool = 1 << 53
+
+// ParsePickMethod parses "hash" or "consistent".
+func ParsePickMethod(name string) (PickMethod, error) {
+	switch name {
+	case "hash":
+		return HashMod, nil
+	case "consistent":
+		return Consistent, nil
+	}
+	return 0, fmt.Errorf("cidr-converter: unknown pick method %q, expected hash or consistent", name)
+}
+
+// String returns the name accepted by ParsePickMethod.
+func (m PickMethod) String() string {
+	if m == Consistent {
+		return "consistent"
+	}
+	return "hash"
+}
+
+// Picker deterministically maps arbitrary keys, such as host names or
+// test case IDs, to addresses of a pool of blocks. The addresses of the
+// pool are numbered in the order of its blocks.
+type Picker struct {
+	pool   CIDRSet
+	sizes  []*big.Int
+	total  *big.Int
+	method PickMethod
+}
+
+// NewPicker returns a picker for the addresses of pool.
+func NewPicker(pool CIDRSet, method PickMethod) (*Picker, error) {
+	if len(pool) == 0 {
+		return nil, fmt.Errorf("cidr-converter: empty pool")
+	}
+	p := &Picker{pool: pool, total: new(big.Int), method: method}
+	for _, ipnet := range pool {
+		ones, bits := ipnet.Mask.Size()
+		size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+		p.sizes = append(p.sizes, size)
+		p.total.Add(p.total, size)
+	}
+	if method == Consistent && p.total.Cmp(big.NewInt(maxConsistentPool)) > 0 {
+		return nil, fmt.Errorf("cidr-converter: pool of %s addresses is too large for consistent hashing", p.total)
+	}
+	return p, nil
+}
+
+// Pick returns the address of the pool assigned to key. The same key and
+// pool always give the same address.
+func (p *Picker) Pick(key string) net.IP {
+	digest := sha256.Sum256([]byte(key))
+	var index *big.Int
+	if p.method == Consistent {
+		index = big.NewInt(jumpHash(binary.BigEndian.Uint64(digest[:8]), p.total.Int64()))
+	} else {
+		index = new(big.Int).Mod(new(big.Int).SetBytes(digest[:]), p.total)
+	}
+	return p.address(index)
+}
+
+// address returns the index-th address of the pool.
+func (p *Picker) address(index *big.Int) net.IP {
+	offset := new(big.Int).Set(index)
+	for i, ipnet := range p.pool {
+		if offset.Cmp(p.sizes[i]) < 0 {
+			network := ipnet.IP.Mask(ipnet.Mask)
+			n := new(big.Int).Add(new(big.Int).SetBytes(network), offset)
+			ip := make(net.IP, len(network))
+			n.FillBytes(ip)
+			return ip
+		}
+		offset.Sub(offset, p.sizes[i])
+	}
+	return nil
+}
+
+// jumpHash is the jump consistent hash of Lamping and Veach, mapping key to
+// a bucket in [0, buckets).
+func jumpHash(key uint64, buckets int64) int64 {
+	var b, j int64 = -1, 0
+	for j < buckets {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return b
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPicker(t *testing.T) {
+	tests := []struct {
+		name   string
+		pool   string
+		method PickMethod
+	}{
+		{name: "Hash", pool: "10.0.0.0/24", method: HashMod},
+		{name: "Consistent", pool: "10.0.0.0/24", method: Consistent},
+		{name: "Several blocks", pool: "10.0.0.0/30,192.168.0.0/29", method: HashMod},
+		{name: "IPv6", pool: "2001:db8::/64", method: HashMod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, _ := ParseCIDRSet(tt.pool)
+			picker, err := NewPicker(pool, tt.method)
+			if err != nil {
+				t.Fatalf("NewPicker() error = %v", err)
+			}
+			seen := map[string]bool{}
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("host-%d", i)
+				ip := picker.Pick(key)
+				if !pool.Contains(ip) {
+					t.Fatalf("Pick(%q) = %s, outside the pool", key, ip)
+				}
+				if again := picker.Pick(key); !again.Equal(ip) {
+					t.Fatalf("Pick(%q) = %s then %s", key, ip, again)
+				}
+				seen[ip.String()] = true
+			}
+			if len(seen) < 10 {
+				t.Errorf("200 keys used only %d addresses", len(seen))
+			}
+		})
+	}
+}
+
+func TestPickerConsistentGrowth(t *testing.T) {
+	small, _ := ParseCIDRSet("10.0.0.0/24")
+	grown, _ := ParseCIDRSet("10.0.0.0/24,10.0.1.0/24")
+	before, _ := NewPicker(small, Consistent)
+	after, _ := NewPicker(grown, Consistent)
+
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("host-%d", i)
+		old, ip := before.Pick(key), after.Pick(key)
+		if !old.Equal(ip) {
+			moved++
+			if small.Contains(ip) {
+				t.Fatalf("Pick(%q) moved from %s to %s within the old pool", key, old, ip)
+			}
+		}
+	}
+	// About half of the keys move to the new addresses.
+	if moved < 400 || moved > 600 {
+		t.Errorf("%d of 1000 keys moved, want about 500", moved)
+	}
+}
+
+func TestNewPickerErrors(t *testing.T) {
+	if _, err := NewPicker(nil, HashMod); err == nil {
+		t.Errorf("NewPicker() expected an error for an empty pool")
+	}
+	huge, _ := ParseCIDRSet("2001:db8::/64")
+	if _, err := NewPicker(huge, Consistent); err == nil {
+		t.Errorf("NewPicker() expected an error for a pool too large for consistent hashing")
+	}
+	if _, err := ParsePickMethod("random"); err == nil {
+		t.Errorf("ParsePickMethod() expected an error")
+	}
+}
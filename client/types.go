@@ -0,0 +1,217 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+)
+
+// NewCIDRInfo describes the block cidr, e.g. "10.0.0.0/8". Invalid blocks
+// are reported as a *ParseError wrapping ErrInvalidCIDR.
+func NewCIDRInfo(cidr string) (CIDRInfo, error) {
+	_, ipnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return CIDRInfo{}, &ParseError{Input: cidr, Err: ErrInvalidCIDR}
+	}
+	return cidrInfoOf(ipnet), nil
+}
+
+func cidrInfoOf(ipnet *net.IPNet) CIDRInfo {
+	ones, bits := ipnet.Mask.Size()
+	last := make(net.IP, len(ipnet.IP))
+	for i := range ipnet.IP {
+		last[i] = ipnet.IP[i] | ^ipnet.Mask[i]
+	}
+	return CIDRInfo{
+		CIDR:  ipnet.String(),
+		First: ipnet.IP.String(),
+		Last:  last.String(),
+		Count: new(big.Int).Lsh(big.NewInt(1), uint(bits-ones)),
+	}
+}
+
+// String returns the block in CIDR notation.
+func (c CIDRInfo) String() string {
+	return c.CIDR
+}
+
+// MarshalText encodes the block in CIDR notation.
+func (c CIDRInfo) MarshalText() ([]byte, error) {
+	return []byte(c.CIDR), nil
+}
+
+// UnmarshalText decodes a block in CIDR notation and fills in the derived
+// fields.
+func (c *CIDRInfo) UnmarshalText(text []byte) error {
+	info, err := NewCIDRInfo(string(text))
+	if err != nil {
+		return err
+	}
+	*c = info
+	return nil
+}
+
+// cidrInfoJSON has the fields of CIDRInfo without its methods, so encoding
+// it does not recurse.
+type cidrInfoJSON CIDRInfo
+
+// MarshalJSON encodes the block as the object used by the API. It is
+// needed because encoding/json would otherwise prefer MarshalText.
+func (c CIDRInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cidrInfoJSON(c))
+}
+
+// UnmarshalJSON decodes either the object used by the API or a plain CIDR
+// string, whose derived fields are filled in.
+func (c *CIDRInfo) UnmarshalJSON(data []byte) error {
+	var cidr string
+	if err := json.Unmarshal(data, &cidr); err == nil {
+		return c.UnmarshalText([]byte(cidr))
+	}
+	var info cidrInfoJSON
+	if err := json.Unmarshal(data, &info); err != nil {
+		return err
+	}
+	if info.CIDR == "" {
+		return fmt.Errorf("cidr-converter: block has no \"cidr\" field: %w", ErrInvalidCIDR)
+	}
+	*c = CIDRInfo(info)
+	return nil
+}
+
+// CIDRSet is a list of blocks. It implements flag.Value, so it can be
+// filled from repeated or comma-separated command-line flags, and encodes
+// as a comma-separated string in text form and as an array of CIDR strings
+// in JSON.
+type CIDRSet []*net.IPNet
+
+// ParseCIDRSet parses a comma-separated list of blocks. An invalid block is
+// reported as a *ParseError with its Column in the list.
+func ParseCIDRSet(list string) (CIDRSet, error) {
+	var set CIDRSet
+	if err := set.Set(list); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// ParseCIDRList reads one block per line from r. Blank lines and lines
+// starting with '#' are ignored. An invalid block is reported as a
+// *ParseError with its Line and Column.
+func ParseCIDRList(r io.Reader) (CIDRSet, error) {
+	var set CIDRSet
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		cidr := strings.TrimSpace(line)
+		if cidr == "" || strings.HasPrefix(cidr, "#") {
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, &ParseError{Input: cidr, Line: lineNum, Column: strings.Index(line, cidr) + 1, Err: ErrInvalidCIDR}
+		}
+		set = append(set, ipnet)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cidr-converter: reading list: %w", err)
+	}
+	return set, nil
+}
+
+// Contains reports whether any block of the set contains ip.
+func (s CIDRSet) Contains(ip net.IP) bool {
+	for _, ipnet := range s {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Strings returns the blocks in CIDR notation.
+func (s CIDRSet) Strings() []string {
+	cidrs := make([]string, len(s))
+	for i, ipnet := range s {
+		cidrs[i] = ipnet.String()
+	}
+	return cidrs
+}
+
+// String returns the blocks as a comma-separated list.
+func (s CIDRSet) String() string {
+	return strings.Join(s.Strings(), ",")
+}
+
+// Set appends the blocks of a comma-separated list to the set.
+func (s *CIDRSet) Set(list string) error {
+	offset := 0
+	for _, item := range strings.Split(list, ",") {
+		start := offset
+		offset += len(item) + 1
+		cidr := strings.TrimSpace(item)
+		if cidr == "" {
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return &ParseError{Input: cidr, Column: start + strings.Index(item, cidr) + 1, Err: ErrInvalidCIDR}
+		}
+		*s = append(*s, ipnet)
+	}
+	return nil
+}
+
+// MarshalText encodes the set as a comma-separated list.
+func (s CIDRSet) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a comma-separated list, replacing the set.
+func (s *CIDRSet) UnmarshalText(text []byte) error {
+	set, err := ParseCIDRSet(string(text))
+	if err != nil {
+		return err
+	}
+	*s = set
+	return nil
+}
+
+// MarshalJSON encodes the set as an array of CIDR strings.
+func (s CIDRSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Strings())
+}
+
+// UnmarshalJSON decodes an array of CIDR strings or CIDRInfo objects, the
+// CIDROutput document served by /v1/cidrs, or a string holding the text
+// form, replacing the set.
+func (s *CIDRSet) UnmarshalJSON(data []byte) error {
+	var list string
+	if err := json.Unmarshal(data, &list); err == nil {
+		return s.UnmarshalText([]byte(list))
+	}
+	var infos []CIDRInfo
+	if err := json.Unmarshal(data, &infos); err != nil {
+		var out CIDROutput
+		if err := json.Unmarshal(data, &out); err != nil || out.CIDRs == nil {
+			return fmt.Errorf("cidr-converter: expected an array of blocks or an object with a \"cidrs\" field")
+		}
+		infos = out.CIDRs
+	}
+	set := make(CIDRSet, 0, len(infos))
+	for _, info := range infos {
+		_, ipnet, err := net.ParseCIDR(info.CIDR)
+		if err != nil {
+			return &ParseError{Input: info.CIDR, Err: ErrInvalidCIDR}
+		}
+		set = append(set, ipnet)
+	}
+	*s = set
+	return nil
+}
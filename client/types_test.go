@@ -0,0 +1,112 @@
+package client
+
+import (
+	"encoding/json"
+	"flag"
+	"net"
+	"testing"
+)
+
+func TestCIDRInfoJSON(t *testing.T) {
+	info, err := NewCIDRInfo("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("NewCIDRInfo() error = %v", err)
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	want := `{"cidr":"10.0.0.0/8","first":"10.0.0.0","last":"10.255.255.255","count":16777216}`
+	if string(data) != want {
+		t.Errorf("json.Marshal() = %s, want %s", data, want)
+	}
+
+	var decoded CIDRInfo
+	if err := json.Unmarshal([]byte(`"192.168.1.0/24"`), &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if decoded.String() != "192.168.1.0/24" || decoded.Last != "192.168.1.255" || decoded.Count.Int64() != 256 {
+		t.Errorf("json.Unmarshal() = %+v", decoded)
+	}
+	if err := json.Unmarshal([]byte(want), &decoded); err != nil || decoded.CIDR != "10.0.0.0/8" {
+		t.Errorf("json.Unmarshal() = %+v, %v", decoded, err)
+	}
+	if err := json.Unmarshal([]byte(`{"first":"10.0.0.0"}`), &decoded); err == nil {
+		t.Errorf("json.Unmarshal() accepted an object without a cidr field")
+	}
+}
+
+func TestCIDRInfoText(t *testing.T) {
+	var config struct {
+		Allow map[CIDRInfo]bool `json:"allow"`
+	}
+	if err := json.Unmarshal([]byte(`{"allow":{"10.0.0.0/8":true}}`), &config); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	for info := range config.Allow {
+		if info.First != "10.0.0.0" {
+			t.Errorf("UnmarshalText() = %+v", info)
+		}
+	}
+
+	var info CIDRInfo
+	if err := info.UnmarshalText([]byte("bogus")); err == nil {
+		t.Errorf("UnmarshalText() accepted an invalid block")
+	}
+}
+
+func TestCIDRSet(t *testing.T) {
+	var set CIDRSet
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Var(&set, "allow", "allowed blocks")
+	if err := flags.Parse([]string{"-allow", "10.0.0.0/8,192.168.1.0/24", "-allow", "2001:db8::/32"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := set.String(); got != "10.0.0.0/8,192.168.1.0/24,2001:db8::/32" {
+		t.Errorf("String() = %q", got)
+	}
+	if !set.Contains(net.ParseIP("10.1.2.3")) || set.Contains(net.ParseIP("172.16.0.1")) {
+		t.Errorf("Contains() gave the wrong answer")
+	}
+	if err := set.Set("bogus"); err == nil {
+		t.Errorf("Set() accepted an invalid block")
+	}
+
+	data, err := json.Marshal(set)
+	if err != nil || string(data) != `["10.0.0.0/8","192.168.1.0/24","2001:db8::/32"]` {
+		t.Errorf("json.Marshal() = %s, %v", data, err)
+	}
+	text, err := set.MarshalText()
+	if err != nil || string(text) != set.String() {
+		t.Errorf("MarshalText() = %s, %v", text, err)
+	}
+}
+
+func TestCIDRSetUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Strings", input: `["10.0.0.0/8","192.168.1.0/24"]`, want: "10.0.0.0/8,192.168.1.0/24"},
+		{name: "Objects", input: `[{"cidr":"10.0.0.0/8"}]`, want: "10.0.0.0/8"},
+		{name: "Versioned document", input: `{"version":1,"cidrs":[{"cidr":"10.0.0.0/8"}]}`, want: "10.0.0.0/8"},
+		{name: "Text form in a struct", input: `"10.0.0.0/8, 192.168.1.0/24"`, want: "10.0.0.0/8,192.168.1.0/24"},
+		{name: "Invalid block", input: `["bogus"]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var set CIDRSet
+			err := json.Unmarshal([]byte(tt.input), &set)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("json.Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && set.String() != tt.want {
+				t.Errorf("json.Unmarshal() = %q, want %q", set.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Metadata conflict policies accepted by -metadata-policy.
+const (
+	policyFirstWins = "first-wins"
+	policyLastWins  = "last-wins"
+	policyError     = "error"
+)
+
+// metadataConflict is a set of input entries whose names and tags
+// disagree: either the same block with different metadata, or the same
+// name on different blocks.
+type metadataConflict struct {
+	// Block is set for conflicts over the metadata of one block, Name for
+	// conflicts over the block of one name.
+	Block   string
+	Name    string
+	Entries []inputEntry
+}
+
+func (c metadataConflict) String() string {
+	var parts []string
+	for _, entry := range c.Entries {
+		if c.Block != "" {
+			parts = append(parts, fmt.Sprintf("%s (%s)", entryPosition(entry), describeMetadata(entry)))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s (%s)", entryPosition(entry), entry.CIDR))
+		}
+	}
+	if c.Block != "" {
+		return fmt.Sprintf("%s has conflicting metadata: %s", c.Block, strings.Join(parts, ", "))
+	}
+	return fmt.Sprintf("name %q is given to different blocks: %s", c.Name, strings.Join(parts, ", "))
+}
+
+// entryPosition returns where an entry was read, as "file:line".
+func entryPosition(entry inputEntry) string {
+	if entry.Line == 0 {
+		return entry.File
+	}
+	return fmt.Sprintf("%s:%d", entry.File, entry.Line)
+}
+
+// describeMetadata returns the name, tags and removal date of an entry for
+// messages.
+func describeMetadata(entry inputEntry) string {
+	var parts []string
+	if entry.Name != "" {
+		parts = append(parts, fmt.Sprintf("name %q", entry.Name))
+	}
+	if len(entry.Tags) > 0 {
+		parts = append(parts, "tags "+strings.Join(entry.Tags, ","))
+	}
+	if !entry.Draining.IsZero() {
+		parts = append(parts, "draining "+entry.Draining.Format(drainingLayout))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// hasMetadata reports whether an entry carries a name, tags or a removal
+// date.
+func hasMetadata(entry inputEntry) bool {
+	return entry.Name != "" || len(entry.Tags) > 0 || !entry.Draining.IsZero()
+}
+
+// metadataKey identifies the metadata of an entry, ignoring tag order.
+func metadataKey(entry inputEntry) string {
+	tags := append([]string(nil), entry.Tags...)
+	sort.Strings(tags)
+	return entry.Name + "\x00" + strings.Join(tags, ",") + "\x00" + entry.Draining.Format(drainingLayout)
+}
+
+// resolveMetadataConflicts finds the metadata conflicts among entries and
+// resolves them with policy. Under first-wins or last-wins, the first or
+// last entry of a conflict decides: entries whose name belongs to another
+// block are dropped, and entries for the same block take the deciding
+// entry's name and tags. Under the error policy, any conflict is an error.
+// Entries without metadata never conflict. The conflicts found are returned
+// in input order.
+func resolveMetadataConflicts(entries []inputEntry, policy string) ([]inputEntry, []metadataConflict, error) {
+	if policy != policyFirstWins && policy != policyLastWins && policy != policyError {
+		return nil, nil, fmt.Errorf("unknown metadata policy %q: expected %s, %s or %s", policy, policyFirstWins, policyLastWins, policyError)
+	}
+	decides := func(group []int) int {
+		if policy == policyLastWins {
+			return group[len(group)-1]
+		}
+		return group[0]
+	}
+
+	// The blocks expanded from one wildcard entry share its position and
+	// name without conflicting.
+	samePosition := func(a, b inputEntry) bool {
+		return a.File == b.File && a.Line == b.Line
+	}
+
+	var conflicts []metadataConflict
+	var names []string
+	byName := map[string][]int{}
+	for i, entry := range entries {
+		if entry.Name == "" {
+			continue
+		}
+		if byName[entry.Name] == nil {
+			names = append(names, entry.Name)
+		}
+		byName[entry.Name] = append(byName[entry.Name], i)
+	}
+	dropped := map[int]bool{}
+	for _, name := range names {
+		group := byName[name]
+		winner := entries[decides(group)]
+		conflict := metadataConflict{Name: name}
+		differs := false
+		for _, i := range group {
+			conflict.Entries = append(conflict.Entries, entries[i])
+			if entries[i].CIDR.String() != winner.CIDR.String() && !samePosition(entries[i], winner) {
+				differs = true
+				dropped[i] = true
+			}
+		}
+		if differs {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	var kept []inputEntry
+	for i, entry := range entries {
+		if !dropped[i] {
+			kept = append(kept, entry)
+		}
+	}
+
+	var blocks []string
+	byBlock := map[string][]int{}
+	for i, entry := range kept {
+		if !hasMetadata(entry) {
+			continue
+		}
+		block := entry.CIDR.String()
+		if byBlock[block] == nil {
+			blocks = append(blocks, block)
+		}
+		byBlock[block] = append(byBlock[block], i)
+	}
+	for _, block := range blocks {
+		group := byBlock[block]
+		winner := kept[decides(group)]
+		conflict := metadataConflict{Block: block}
+		differs := false
+		for _, i := range group {
+			conflict.Entries = append(conflict.Entries, kept[i])
+			if metadataKey(kept[i]) != metadataKey(winner) {
+				differs = true
+			}
+		}
+		if !differs {
+			continue
+		}
+		conflicts = append(conflicts, conflict)
+		for _, i := range group {
+			kept[i].Name, kept[i].Tags, kept[i].Draining = winner.Name, winner.Tags, winner.Draining
+		}
+	}
+
+	if policy == policyError && len(conflicts) > 0 {
+		return nil, conflicts, fmt.Errorf("metadata conflicts found: %d", len(conflicts))
+	}
+	return kept, conflicts, nil
+}
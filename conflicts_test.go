@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// metadataInputA and metadataInputB are two input files: office is named twice with
+// different blocks, and 10.2.0.0/16 has two different names.
+const metadataInputA = `[
+  {"cidr": "10.0.0.0/16", "name": "office", "tags": ["hq"]},
+  {"cidr": "10.2.0.0/16", "name": "lab"},
+  "192.168.0.0/24"
+]`
+
+const metadataInputB = `[
+  {"cidr": "10.1.0.0/16", "name": "office"},
+  {"cidr": "10.2.0.0/16", "name": "staging", "tags": ["b", "a"]},
+  "192.168.0.0/24"
+]`
+
+func metadataEntries(t *testing.T) []inputEntry {
+	t.Helper()
+	var entries []inputEntry
+	for _, input := range []struct{ file, text string }{{"a.json", metadataInputA}, {"b.json", metadataInputB}} {
+		fileEntries, err := scanCIDRJSON(strings.NewReader(input.text))
+		if err != nil {
+			t.Fatalf("scanCIDRJSON() error = %v", err)
+		}
+		for i := range fileEntries {
+			fileEntries[i].File = input.file
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return entries
+}
+
+func TestResolveMetadataConflicts(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		wantKept  string
+		wantNames string
+	}{
+		{
+			name:      "First wins",
+			policy:    policyFirstWins,
+			wantKept:  "10.0.0.0/16,10.2.0.0/16,192.168.0.0/24,10.2.0.0/16,192.168.0.0/24",
+			wantNames: "office,lab,,lab,",
+		},
+		{
+			name:      "Last wins",
+			policy:    policyLastWins,
+			wantKept:  "10.2.0.0/16,192.168.0.0/24,10.1.0.0/16,10.2.0.0/16,192.168.0.0/24",
+			wantNames: "staging,,office,staging,",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, conflicts, err := resolveMetadataConflicts(metadataEntries(t), tt.policy)
+			if err != nil {
+				t.Fatalf("resolveMetadataConflicts() error = %v", err)
+			}
+			var cidrs, names []string
+			for _, entry := range kept {
+				cidrs = append(cidrs, entry.CIDR.String())
+				names = append(names, entry.Name)
+			}
+			if got := strings.Join(cidrs, ","); got != tt.wantKept {
+				t.Errorf("kept = %q, want %q", got, tt.wantKept)
+			}
+			if got := strings.Join(names, ","); got != tt.wantNames {
+				t.Errorf("names = %q, want %q", got, tt.wantNames)
+			}
+			if len(conflicts) != 2 {
+				t.Fatalf("found %d conflicts, want 2: %v", len(conflicts), conflicts)
+			}
+			want := `name "office" is given to different blocks: a.json:1 (10.0.0.0/16), b.json:1 (10.1.0.0/16)`
+			if conflicts[0].String() != want {
+				t.Errorf("conflict = %q, want %q", conflicts[0], want)
+			}
+		})
+	}
+}
+
+func TestResolveMetadataConflictsError(t *testing.T) {
+	_, conflicts, err := resolveMetadataConflicts(metadataEntries(t), policyError)
+	if err == nil {
+		t.Fatalf("resolveMetadataConflicts() expected an error")
+	}
+	want := `10.2.0.0/16 has conflicting metadata: a.json:2 (name "lab"), b.json:2 (name "staging", tags b,a)`
+	if len(conflicts) != 2 || conflicts[1].String() != want {
+		t.Errorf("conflicts = %v, want the second to be %q", conflicts, want)
+	}
+
+	if _, _, err := resolveMetadataConflicts(metadataEntries(t), "newest"); err == nil {
+		t.Errorf("resolveMetadataConflicts() expected an error for an unknown policy")
+	}
+}
+
+func TestResolveMetadataConflictsAgreeing(t *testing.T) {
+	entries, _ := scanCIDRJSON(strings.NewReader(`[
+  {"cidr": "10.0.0.0/16", "name": "office", "tags": ["a", "b"]},
+  {"cidr": "10.0.0.0/16", "name": "office", "tags": ["b", "a"]},
+  {"cidr": "10.0.0.0/16"}
+]`))
+	kept, conflicts, err := resolveMetadataConflicts(entries, policyError)
+	if err != nil || len(conflicts) != 0 || len(kept) != 3 {
+		t.Errorf("resolveMetadataConflicts() = %d entries, %v, %v, want no conflicts", len(kept), conflicts, err)
+	}
+}
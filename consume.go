@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// setEvent is an add or remove operation on the compiled set.
+type setEvent struct {
+	Op   string `json:"op"`
+	CIDR string `json:"cidr"`
+}
+
+// parseSetEvent parses an event message, either JSON such as
+// {"op":"add","cidr":"10.0.0.0/8"} or plain text such as "remove 10.0.0.0/8".
+func parseSetEvent(payload []byte) (string, *net.IPNet, error) {
+	var event setEvent
+	text := strings.TrimSpace(string(payload))
+	if strings.HasPrefix(text, "{") {
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return "", nil, fmt.Errorf("invalid event: %v", err)
+		}
+	} else {
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return "", nil, fmt.Errorf("invalid event, expected \"add|remove CIDR\": %q", text)
+		}
+		event = setEvent{Op: fields[0], CIDR: fields[1]}
+	}
+	op := strings.ToLower(event.Op)
+	if op != "add" && op != "remove" {
+		return "", nil, fmt.Errorf("unknown event operation: %s", event.Op)
+	}
+	cidr, err := parseCIDR(event.CIDR)
+	if err != nil {
+		return "", nil, err
+	}
+	return op, cidr, nil
+}
+
+// setCompiler maintains the aggregated set built from a stream of events.
+type setCompiler struct {
+	mu      sync.Mutex
+	cidrs   []*net.IPNet
+	changed bool
+}
+
+// apply adds or removes a block. Removing part of a larger block splits the
+// larger block around the removed range.
+func (c *setCompiler) apply(op string, cidr *net.IPNet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if op == "add" {
+		c.cidrs = collapseCIDRs(append(c.cidrs, cidr))
+	} else {
+		c.cidrs = collapseCIDRs(subtractCIDRs(c.cidrs, []*net.IPNet{cidr}))
+	}
+	c.changed = true
+}
+
+// restoreCompiler returns a compiler holding the set last saved to store, so
+// that a restarted consumer carries on from it instead of overwriting it
+// with only the events received since.
+func restoreCompiler(ctx context.Context, store cidrStore) (*setCompiler, error) {
+	cidrs, err := store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &setCompiler{cidrs: collapseCIDRs(cidrs)}, nil
+}
+
+// takeChanges returns the current set and whether it changed since the
+// last call.
+func (c *setCompiler) takeChanges() ([]*net.IPNet, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changed := c.changed
+	c.changed = false
+	return c.cidrs, changed
+}
+
+// natsConn is a minimal client for the NATS text protocol, supporting a
+// single subscription and publishing.
+type natsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+}
+
+// dialNATS connects to a nats://host:port URL.
+func dialNATS(rawURL string) (*natsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "nats" || u.Host == "" {
+		return nil, fmt.Errorf("invalid NATS URL: %s", rawURL)
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to NATS: %v", err)
+	}
+	nc := &natsConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	line, err := nc.reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected NATS greeting: %q", line)
+	}
+	if err := nc.write("CONNECT {\"verbose\":false,\"pedantic\":false,\"name\":\"cidr-converter\"}\r\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return nc, nil
+}
+
+func (nc *natsConn) write(s string) error {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	if _, err := io.WriteString(nc.conn, s); err != nil {
+		return fmt.Errorf("error writing to NATS: %v", err)
+	}
+	return nil
+}
+
+// subscribe subscribes to subject.
+func (nc *natsConn) subscribe(subject string) error {
+	return nc.write(fmt.Sprintf("SUB %s 1\r\n", subject))
+}
+
+// publish publishes payload on subject.
+func (nc *natsConn) publish(subject string, payload []byte) error {
+	return nc.write(fmt.Sprintf("PUB %s %d\r\n%s\r\n", subject, len(payload), payload))
+}
+
+// readMessages delivers the payload of every received message to handle,
+// answering server pings, until the connection fails.
+func (nc *natsConn) readMessages(handle func(payload []byte)) error {
+	for {
+		line, err := nc.reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("error reading from NATS: %v", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "PING":
+			if err := nc.write("PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "MSG "):
+			fields := strings.Fields(line)
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				return fmt.Errorf("invalid NATS message header: %q", line)
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(nc.reader, payload); err != nil {
+				return fmt.Errorf("error reading from NATS: %v", err)
+			}
+			handle(payload[:size])
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS error: %s", line)
+		}
+	}
+}
+
+func (nc *natsConn) Close() error {
+	return nc.conn.Close()
+}
+
+// natsRetryDelay is how long a subscriber waits before its first attempt to
+// reconnect after the connection fails. The delay doubles with each failed
+// attempt, up to natsMaxRetryDelay.
+var natsRetryDelay = time.Second
+
+const natsMaxRetryDelay = time.Minute
+
+// natsSubscriber keeps a subscription to a subject alive across connection
+// failures, reconnecting and subscribing again whenever the connection
+// drops.
+type natsSubscriber struct {
+	url     string
+	subject string
+	mu      sync.Mutex
+	conn    *natsConn
+}
+
+// connect dials the server and subscribes to the subject.
+func (s *natsSubscriber) connect() error {
+	nc, err := dialNATS(s.url)
+	if err != nil {
+		return err
+	}
+	if err := nc.subscribe(s.subject); err != nil {
+		nc.Close()
+		return err
+	}
+	s.mu.Lock()
+	s.conn = nc
+	s.mu.Unlock()
+	return nil
+}
+
+// publish publishes payload on subject over the current connection.
+func (s *natsSubscriber) publish(subject string, payload []byte) error {
+	s.mu.Lock()
+	nc := s.conn
+	s.mu.Unlock()
+	if nc == nil {
+		return fmt.Errorf("not connected to NATS")
+	}
+	return nc.publish(subject, payload)
+}
+
+// run delivers the payload of every message on the subject to handle until
+// ctx is cancelled. It fails at once if the first connection cannot be made;
+// later failures are logged and retried with a doubling delay.
+func (s *natsSubscriber) run(ctx context.Context, handle func(payload []byte)) error {
+	if err := s.connect(); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.conn.Close()
+	})
+	defer stop()
+
+	for {
+		s.mu.Lock()
+		nc := s.conn
+		s.mu.Unlock()
+		err := nc.readMessages(handle)
+		nc.Close()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("consume: %v", err)
+
+		delay := natsRetryDelay
+		for {
+			log.Printf("consume: reconnecting in %v", delay)
+			if err := sleepContext(ctx, delay); err != nil {
+				return err
+			}
+			if err = s.connect(); err == nil {
+				break
+			}
+			log.Printf("consume: %v", err)
+			delay = min(2*delay, natsMaxRetryDelay)
+		}
+		if ctx.Err() != nil {
+			// The connection made after cancellation was not closed by stop.
+			s.conn.Close()
+			return ctx.Err()
+		}
+	}
+}
+
+// runConsume implements the "consume" command: it compiles the set from a
+// NATS event stream and periodically publishes or persists the result.
+func runConsume(args []string) error {
+	fs := flag.NewFlagSet("consume", flag.ContinueOnError)
+	natsURL := fs.String("nats", "nats://127.0.0.1:4222", "NATS server URL")
+	subject := fs.String("subject", "", "subject carrying add/remove events")
+	publish := fs.String("publish", "", "subject to publish the compiled set on")
+	output := fs.String("output", "", "file to write the compiled set to")
+	storeURL := fs.String("store", "", "consul:// or etcd:// store to write the compiled set to")
+	interval := fs.Duration("interval", 10*time.Second, "how often to publish or persist changes")
+	dryRun := fs.Bool("dry-run", false, "print the changes that would be published or persisted instead of making them")
+	httpFlags := addHTTPFlags(fs)
+	webhookFlags := addWebhookFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *subject == "" || fs.NArg() != 0 {
+		return fmt.Errorf("usage: consume -subject name [-nats url] [-publish subject] [-output file] [-store url] [-webhook url]... [-interval d] [-dry-run]")
+	}
+
+	var store cidrStore
+	if *storeURL != "" {
+		var err error
+		if store, err = openStore(*storeURL, httpFlags.client()); err != nil {
+			return err
+		}
+	}
+	var hooks *webhooks
+	if !*dryRun {
+		hooks = webhookFlags.notifier(httpFlags.client())
+	}
+	// The compiler starts from the stored set. saved is the set last
+	// persisted, which dry runs and webhooks compare against.
+	compiler := &setCompiler{}
+	var saved []*net.IPNet
+	if store != nil {
+		var err error
+		if compiler, err = restoreCompiler(context.Background(), store); err != nil {
+			return err
+		}
+		saved = compiler.cidrs
+	}
+	subscriber := &natsSubscriber{url: *natsURL, subject: *subject}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	go func() {
+		for range ticker.C {
+			cidrs, changed := compiler.takeChanges()
+			if !changed {
+				continue
+			}
+			if *publish != "" {
+				payload, _ := json.Marshal(newCIDROutput(cidrs))
+				if *dryRun {
+					fmt.Printf("Dry run: would publish on %s: %s\n", *publish, payload)
+				} else if err := subscriber.publish(*publish, payload); err != nil {
+					log.Printf("error publishing compiled set: %v", err)
+				}
+			}
+			if *output != "" {
+				if err := saveJSON(*output, newCIDROutput(cidrs), *dryRun); err != nil {
+					log.Printf("error saving compiled set: %v", err)
+				}
+			}
+			if store != nil {
+				if *dryRun {
+					previewStore(os.Stdout, *storeURL, saved, cidrs)
+				} else if err := store.Save(context.Background(), cidrs); err != nil {
+					log.Printf("error saving compiled set: %v", err)
+				}
+			}
+			if *dryRun && len(webhookFlags.URLs) > 0 {
+				if change, changed := newSetChange(*subject, saved, cidrs, time.Now()); changed {
+					payload, _ := json.Marshal(change)
+					fmt.Printf("Dry run: would notify %s: %s\n", webhookFlags.URLs.String(), payload)
+				}
+			}
+			hooks.notify(*subject, saved, cidrs)
+			saved = cidrs
+			log.Printf("compiled set now holds %d blocks", len(cidrs))
+		}
+	}()
+
+	return subscriber.run(context.Background(), func(payload []byte) {
+		op, cidr, err := parseSetEvent(payload)
+		if err != nil {
+			log.Printf("skipping event: %v", err)
+			return
+		}
+		compiler.apply(op, cidr)
+	})
+}
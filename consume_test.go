@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSetEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantOp  string
+		want    string
+		wantErr bool
+	}{
+		{name: "JSON add", input: `{"op":"add","cidr":"10.0.0.0/8"}`, wantOp: "add", want: "10.0.0.0/8"},
+		{name: "Text remove", input: "REMOVE 10.1.0.0/16\n", wantOp: "remove", want: "10.1.0.0/16"},
+		{name: "Unknown operation", input: "replace 10.0.0.0/8", wantErr: true},
+		{name: "Invalid CIDR", input: `{"op":"add","cidr":"bogus"}`, wantErr: true},
+		{name: "Malformed text", input: "add", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, cidr, err := parseSetEvent([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSetEvent() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && (op != tt.wantOp || cidr.String() != tt.want) {
+				t.Errorf("parseSetEvent() = %s %v, want %s %s", op, cidr, tt.wantOp, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetCompiler(t *testing.T) {
+	compiler := &setCompiler{}
+	for _, event := range []string{"add 10.0.0.0/25", "add 10.0.0.128/25", "remove 10.0.0.64/26"} {
+		op, cidr, _ := parseSetEvent([]byte(event))
+		compiler.apply(op, cidr)
+	}
+
+	cidrs, changed := compiler.takeChanges()
+	if !changed {
+		t.Errorf("takeChanges() reported no change")
+	}
+	if got := joinCIDRs(cidrs); got != "10.0.0.0/26,10.0.0.128/25" {
+		t.Errorf("compiled set = %q", got)
+	}
+	if _, changed := compiler.takeChanges(); changed {
+		t.Errorf("takeChanges() reported a change twice")
+	}
+}
+
+func TestRestoreCompiler(t *testing.T) {
+	stored, _ := parseCIDRList(strings.NewReader("10.0.0.0/25\n10.0.0.128/25\n192.168.0.0/16\n"))
+	store := &memoryStore{cidrs: stored}
+	compiler, err := restoreCompiler(context.Background(), store)
+	if err != nil {
+		t.Fatalf("restoreCompiler() error = %v", err)
+	}
+
+	// The first events after a restart change the stored set rather than
+	// replace it.
+	for _, event := range []string{"add 172.16.0.0/12", "remove 192.168.1.0/24"} {
+		op, cidr, _ := parseSetEvent([]byte(event))
+		compiler.apply(op, cidr)
+	}
+	cidrs, changed := compiler.takeChanges()
+	if !changed {
+		t.Fatal("takeChanges() reported no change")
+	}
+	store.Save(context.Background(), cidrs)
+	want := "10.0.0.0/24,172.16.0.0/12,192.168.0.0/24,192.168.2.0/23,192.168.4.0/22,192.168.8.0/21,192.168.16.0/20,192.168.32.0/19,192.168.64.0/18,192.168.128.0/17"
+	if got := joinCIDRs(store.cidrs); got != want {
+		t.Errorf("store after restart = %q, want %q", got, want)
+	}
+}
+
+func TestNATSConn(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen on loopback: %v", err)
+	}
+	defer listener.Close()
+
+	published := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "SUB cidr.events "):
+				conn.Write([]byte("PING\r\n"))
+				conn.Write([]byte("MSG cidr.events 1 14\r\nadd 10.0.0.0/8\r\n"))
+			case strings.HasPrefix(line, "PONG"):
+				conn.Write([]byte("MSG cidr.events 1 18\r\nremove 10.1.0.0/16\r\n"))
+			case strings.HasPrefix(line, "PUB "):
+				payload, _ := reader.ReadString('\n')
+				published <- strings.TrimSpace(line) + " " + strings.TrimSpace(payload)
+				return
+			}
+		}
+	}()
+
+	nc, err := dialNATS("nats://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dialNATS() error = %v", err)
+	}
+	defer nc.Close()
+	if err := nc.subscribe("cidr.events"); err != nil {
+		t.Fatalf("subscribe() error = %v", err)
+	}
+
+	var messages []string
+	nc.readMessages(func(payload []byte) {
+		messages = append(messages, string(payload))
+		if len(messages) == 2 {
+			nc.publish("cidr.compiled", []byte("done"))
+		}
+	})
+	if len(messages) != 2 || messages[0] != "add 10.0.0.0/8" || messages[1] != "remove 10.1.0.0/16" {
+		t.Errorf("readMessages() delivered %q", messages)
+	}
+	if got := <-published; got != "PUB cidr.compiled 4 done" {
+		t.Errorf("publish() sent %q", got)
+	}
+}
+
+func TestNATSSubscriberReconnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen on loopback: %v", err)
+	}
+	defer listener.Close()
+	defer func(delay time.Duration) { natsRetryDelay = delay }(natsRetryDelay)
+	natsRetryDelay = time.Millisecond
+
+	go func() {
+		for attempt := 1; ; attempt++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			reader := bufio.NewReader(conn)
+			conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					break
+				}
+				if strings.HasPrefix(line, "SUB cidr.events ") {
+					if attempt == 1 {
+						// Drop the first connection once subscribed.
+						break
+					}
+					conn.Write([]byte("MSG cidr.events 1 14\r\nadd 10.0.0.0/8\r\n"))
+				}
+			}
+			conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	subscriber := &natsSubscriber{url: "nats://" + listener.Addr().String(), subject: "cidr.events"}
+	var messages []string
+	err = subscriber.run(ctx, func(payload []byte) {
+		messages = append(messages, string(payload))
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("run() error = %v, want %v", err, context.Canceled)
+	}
+	if len(messages) != 1 || messages[0] != "add 10.0.0.0/8" {
+		t.Errorf("run() delivered %q after reconnecting", messages)
+	}
+
+	unreachable := &natsSubscriber{url: "nats://127.0.0.1:0", subject: "cidr.events"}
+	if err := unreachable.run(context.Background(), func([]byte) {}); err == nil {
+		t.Error("run() without a server succeeded")
+	}
+}
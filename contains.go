@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// cidrRelation describes how one CIDR block, or set of blocks, relates to
+// another.
+type cidrRelation int
+
+const (
+	relationDisjoint cidrRelation = iota
+	relationEqual
+	relationSubnet
+	relationSupernet
+	// relationOverlapping only relates sets: two single blocks are either
+	// disjoint or one contains the other.
+	relationOverlapping
+)
+
+func (r cidrRelation) String() string {
+	switch r {
+	case relationEqual:
+		return "equal to"
+	case relationSubnet:
+		return "a subnet of"
+	case relationSupernet:
+		return "a supernet of"
+	case relationOverlapping:
+		return "overlapping"
+	default:
+		return "disjoint from"
+	}
+}
+
+// containsCIDR reports how block a relates to block b: a is a subnet of b
+// when b fully contains it, a supernet when a fully contains b. Blocks of
+// different address families are always disjoint.
+func containsCIDR(a, b *net.IPNet) cidrRelation {
+	aInB := cidrContains(b, a)
+	bInA := cidrContains(a, b)
+	switch {
+	case aInB && bInA:
+		return relationEqual
+	case aInB:
+		return relationSubnet
+	case bInA:
+		return relationSupernet
+	default:
+		return relationDisjoint
+	}
+}
+
+// containsCIDRSet reports how the addresses of the blocks of a relate to
+// those of b. Unlike single blocks, sets may share some addresses without
+// either covering the other; they are then overlapping.
+func containsCIDRSet(a, b []*net.IPNet) cidrRelation {
+	aInB := len(subtractCIDRs(a, b)) == 0
+	bInA := len(subtractCIDRs(b, a)) == 0
+	switch {
+	case aInB && bInA:
+		return relationEqual
+	case aInB:
+		return relationSubnet
+	case bInA:
+		return relationSupernet
+	case len(intersectCIDRs(a, b)) > 0:
+		return relationOverlapping
+	default:
+		return relationDisjoint
+	}
+}
+
+// parseCIDRSet parses a comma-separated list of blocks.
+func parseCIDRSet(list string) ([]*net.IPNet, error) {
+	var cidrs []*net.IPNet
+	for _, value := range strings.Split(list, ",") {
+		cidr, err := parseCIDR(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+// runContains implements the "contains" command. Either argument may be a
+// comma-separated list of blocks, which is compared as a set.
+func runContains(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: contains <cidr-a>[,<cidr>...] <cidr-b>[,<cidr>...]")
+	}
+	a, err := parseCIDRSet(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := parseCIDRSet(args[1])
+	if err != nil {
+		return err
+	}
+	if len(a) == 1 && len(b) == 1 {
+		fmt.Printf("%s is %s %s\n", a[0], containsCIDR(a[0], b[0]), b[0])
+		return nil
+	}
+	fmt.Printf("%s is %s %s\n", joinCIDRList(a), containsCIDRSet(a, b), joinCIDRList(b))
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestContainsCIDR(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want cidrRelation
+	}{
+		{name: "Equal", a: "10.0.0.0/8", b: "10.0.0.0/8", want: relationEqual},
+		{name: "Subnet", a: "10.1.0.0/16", b: "10.0.0.0/8", want: relationSubnet},
+		{name: "Supernet", a: "10.0.0.0/8", b: "10.1.0.0/16", want: relationSupernet},
+		{name: "Disjoint", a: "10.0.0.0/8", b: "192.168.0.0/16", want: relationDisjoint},
+		{name: "Different families", a: "0.0.0.0/0", b: "::/0", want: relationDisjoint},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, a, _ := net.ParseCIDR(tt.a)
+			_, b, _ := net.ParseCIDR(tt.b)
+			if got := containsCIDR(a, b); got != tt.want {
+				t.Errorf("containsCIDR() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainsCIDRSet(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want cidrRelation
+	}{
+		{name: "Equal", a: "10.0.0.0/25,10.0.0.128/25", b: "10.0.0.0/24", want: relationEqual},
+		{name: "Subnet", a: "10.0.0.0/24,10.0.2.0/24", b: "10.0.0.0/22", want: relationSubnet},
+		{name: "Supernet", a: "10.0.0.0/8", b: "10.1.0.0/16,10.2.0.0/16", want: relationSupernet},
+		{name: "Overlapping", a: "10.0.0.0/24,10.0.2.0/24", b: "10.0.0.0/23", want: relationOverlapping},
+		{name: "Disjoint", a: "10.0.0.0/24,10.0.2.0/24", b: "10.0.1.0/24,2001:db8::/32", want: relationDisjoint},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := parseCIDRSet(tt.a)
+			if err != nil {
+				t.Fatalf("parseCIDRSet() error = %v", err)
+			}
+			b, err := parseCIDRSet(tt.b)
+			if err != nil {
+				t.Fatalf("parseCIDRSet() error = %v", err)
+			}
+			if got := containsCIDRSet(a, b); got != tt.want {
+				t.Errorf("containsCIDRSet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseCIDRSet("10.0.0.0/8,bogus"); err == nil {
+		t.Error("parseCIDRSet() accepted an invalid block")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// scanCountedList reads one "CIDR COUNT" row per line from r, such as the
+// output of the talkers command. The count may also follow a comma. Blank
+// lines and lines starting with '#' are ignored.
+func scanCountedList(r io.Reader) ([]inputEntry, error) {
+	var entries []inputEntry
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(strings.Replace(line, ",", " ", 1))
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a block and a count", lineNum)
+		}
+		count, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid count: %s", lineNum, fields[1])
+		}
+		ipnets, err := parseEntry(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		// A wildcard's count is spread evenly over the blocks it expands to.
+		for i, ipnet := range ipnets {
+			share := count / uint64(len(ipnets))
+			if uint64(i) < count%uint64(len(ipnets)) {
+				share++
+			}
+			entries = append(entries, inputEntry{CIDR: ipnet, Line: lineNum, Count: share})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading input: %v", err)
+	}
+	return entries, nil
+}
+
+// readCountedFileEntries reads the named file with scanCountedList.
+func readCountedFileEntries(filename string) ([]inputEntry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+
+	entries, err := scanCountedList(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	for i := range entries {
+		entries[i].File = filename
+	}
+	return entries, nil
+}
+
+// dropBelowCount returns the entries with a count of at least minCount, so
+// that quiet blocks do not drive aggregation, and the number dropped.
+func dropBelowCount(entries []inputEntry, minCount uint64) ([]inputEntry, int) {
+	var kept []inputEntry
+	for _, entry := range entries {
+		if entry.Count >= minCount {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, len(entries) - len(kept)
+}
+
+// attributeCounts sums the counts of the entries into the blocks of merged
+// containing them. An entry split over several merged blocks, as with
+// -boundaries, is attributed to each in proportion to the addresses it
+// shares with it, rounded down.
+func attributeCounts(merged []*net.IPNet, entries []inputEntry) []uint64 {
+	counts := make([]uint64, len(merged))
+	for _, entry := range entries {
+		for i, block := range merged {
+			if !cidrsOverlap(block, entry.CIDR) {
+				continue
+			}
+			if cidrContains(block, entry.CIDR) {
+				counts[i] += entry.Count
+				continue
+			}
+			// The entry is broader than the block.
+			share := new(big.Int).SetUint64(entry.Count)
+			share.Mul(share, cidrSize(block))
+			share.Quo(share, cidrSize(entry.CIDR))
+			counts[i] += share.Uint64()
+		}
+	}
+	return counts
+}
+
+// addCounts records the count attributed to every block of output.
+func addCounts(output *cidrOutput, entries []inputEntry) {
+	blocks := make([]*net.IPNet, len(output.CIDRs))
+	for i, info := range output.CIDRs {
+		_, blocks[i], _ = net.ParseCIDR(info.CIDR)
+	}
+	for i, count := range attributeCounts(blocks, entries) {
+		count := count
+		output.CIDRs[i].Hits = &count
+	}
+}
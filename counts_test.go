@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestScanCountedList(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Talkers output", input: "10.0.1.0/24 1532\n# comment\n\n198.51.100.0/24 48213\n", want: "10.0.1.0/24=1532,198.51.100.0/24=48213"},
+		{name: "Comma-separated", input: "10.0.1.0/24,7\n", want: "10.0.1.0/24=7"},
+		{name: "Wildcard spreads the count", input: "10.0.*.* 5\n", want: "10.0.0.0/16=5"},
+		{name: "Missing count", input: "10.0.1.0/24\n", wantErr: true},
+		{name: "Invalid count", input: "10.0.1.0/24 -3\n", wantErr: true},
+		{name: "Invalid block", input: "10.0.1.0/33 3\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := scanCountedList(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("scanCountedList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, fmt.Sprintf("%s=%d", entry.CIDR, entry.Count))
+			}
+			if joined := strings.Join(got, ","); joined != tt.want {
+				t.Errorf("scanCountedList() = %q, want %q", joined, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttributeCounts(t *testing.T) {
+	entries, _ := scanCountedList(strings.NewReader("10.0.0.0/25 10\n10.0.0.128/25 30\n10.0.2.0/23 100\n192.0.2.0/24 1\n"))
+	tests := []struct {
+		name   string
+		merged string
+		want   string
+	}{
+		{name: "Summed into the aggregate", merged: "10.0.0.0/24\n", want: "40"},
+		{name: "Split in proportion", merged: "10.0.2.0/24\n10.0.3.0/25\n10.0.3.128/25\n", want: "50,25,25"},
+		{name: "Uncounted block", merged: "172.16.0.0/12\n", want: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, _ := parseCIDRList(strings.NewReader(tt.merged))
+			var got []string
+			for _, count := range attributeCounts(merged, entries) {
+				got = append(got, fmt.Sprint(count))
+			}
+			if joined := strings.Join(got, ","); joined != tt.want {
+				t.Errorf("attributeCounts() = %q, want %q", joined, tt.want)
+			}
+		})
+	}
+}
+
+func TestDropBelowCount(t *testing.T) {
+	entries, _ := scanCountedList(strings.NewReader("10.0.0.0/24 10\n10.0.1.0/24 2\n10.0.2.0/24 5\n"))
+	kept, dropped := dropBelowCount(entries, 5)
+	var got []string
+	for _, entry := range kept {
+		got = append(got, entry.CIDR.String())
+	}
+	if joined := strings.Join(got, ","); joined != "10.0.0.0/24,10.0.2.0/24" || dropped != 1 {
+		t.Errorf("dropBelowCount() = %q, %d, want %q, 1", joined, dropped, "10.0.0.0/24,10.0.2.0/24")
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DNS constants used by the DNSBL server.
+const (
+	dnsTypeA    = 1
+	dnsTypeTXT  = 16
+	dnsTypeAAAA = 28
+	dnsClassIN  = 1
+
+	dnsRcodeNoError  = 0
+	dnsRcodeFormErr  = 1
+	dnsRcodeNXDomain = 3
+	dnsRcodeNotImp   = 4
+	dnsRcodeRefused  = 5
+)
+
+// dnsQuestion is the question of a DNS query.
+type dnsQuestion struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	// end is the offset just past the question in the packet.
+	end int
+}
+
+// parseDNSQuery reads the header and the single question of a query.
+func parseDNSQuery(packet []byte) (id, flags uint16, q dnsQuestion, err error) {
+	if len(packet) < 12 {
+		return 0, 0, q, errors.New("short DNS header")
+	}
+	id = binary.BigEndian.Uint16(packet[0:2])
+	flags = binary.BigEndian.Uint16(packet[2:4])
+	if binary.BigEndian.Uint16(packet[4:6]) != 1 {
+		return id, flags, q, errors.New("expected exactly one question")
+	}
+
+	var labels []string
+	i := 12
+	for {
+		if i >= len(packet) {
+			return id, flags, q, errors.New("truncated question name")
+		}
+		n := int(packet[i])
+		i++
+		if n == 0 {
+			break
+		}
+		if n&0xC0 != 0 || i+n > len(packet) {
+			return id, flags, q, errors.New("invalid question name")
+		}
+		labels = append(labels, string(packet[i:i+n]))
+		i += n
+	}
+	if i+4 > len(packet) {
+		return id, flags, q, errors.New("truncated question")
+	}
+	q = dnsQuestion{
+		Name:  strings.Join(labels, "."),
+		Type:  binary.BigEndian.Uint16(packet[i : i+2]),
+		Class: binary.BigEndian.Uint16(packet[i+2 : i+4]),
+		end:   i + 4,
+	}
+	return id, flags, q, nil
+}
+
+// parseDNSBLName returns the address queried by name, a reversed IPv4
+// address or, as in RFC 5782, a reversed sequence of 32 IPv6 nibbles
+// followed by zone. It returns false for names that are not queries under
+// zone, and a nil IP for malformed ones.
+func parseDNSBLName(name, zone string) (net.IP, bool) {
+	labels, inZone := dnsblLabels(name, zone)
+	if !inZone {
+		return nil, false
+	}
+	switch len(labels) {
+	case 4:
+		ip := net.ParseIP(strings.Join(labels, "."))
+		if ip == nil || ip.To4() == nil {
+			return nil, true
+		}
+		return ip.To4(), true
+	case 32:
+		ip := make(net.IP, net.IPv6len)
+		for i, label := range labels {
+			nibble, err := strconv.ParseUint(label, 16, 8)
+			if err != nil || len(label) != 1 {
+				return nil, true
+			}
+			ip[i/2] |= byte(nibble) << uint(4*(1-i%2))
+		}
+		return ip, true
+	}
+	return nil, true
+}
+
+// dnsblLabels returns the labels of name before zone, most significant
+// first, and false for names that are not under zone. The zone apex has no
+// labels.
+func dnsblLabels(name, zone string) ([]string, bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	zone = strings.ToLower(strings.Trim(zone, "."))
+	if name != zone && !strings.HasSuffix(name, "."+zone) {
+		return nil, false
+	}
+	prefix := strings.TrimSuffix(strings.TrimSuffix(name, zone), ".")
+	if prefix == "" {
+		return nil, true
+	}
+	labels := strings.Split(prefix, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return labels, true
+}
+
+// dnsblPrefixes returns the blocks of the addresses queried by names below
+// the partial address labels: its first one to three IPv4 octets or one to
+// 31 IPv6 nibbles, most significant first. A short name such as "1" may
+// stand for both.
+func dnsblPrefixes(labels []string) []*net.IPNet {
+	var prefixes []*net.IPNet
+	if len(labels) <= 3 {
+		ip := make(net.IP, net.IPv4len)
+		valid := true
+		for i, label := range labels {
+			octet, err := strconv.Atoi(label)
+			if err != nil || octet > 255 || strconv.Itoa(octet) != label {
+				valid = false
+				break
+			}
+			ip[i] = byte(octet)
+		}
+		if valid {
+			prefixes = append(prefixes, &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(labels), 32)})
+		}
+	}
+	if len(labels) <= 31 {
+		ip := make(net.IP, net.IPv6len)
+		for i, label := range labels {
+			nibble, err := strconv.ParseUint(label, 16, 8)
+			if err != nil || len(label) != 1 {
+				return prefixes
+			}
+			ip[i/2] |= byte(nibble) << uint(4*(1-i%2))
+		}
+		prefixes = append(prefixes, &net.IPNet{IP: ip, Mask: net.CIDRMask(4*len(labels), 128)})
+	}
+	return prefixes
+}
+
+// dnsblServer answers DNSBL queries for a set of blocks.
+type dnsblServer struct {
+	zone   string
+	index  cidrIndex
+	answer net.IP
+	ttl    uint32
+}
+
+// newDNSBLServer returns a server listing cidrs under zone. Listed
+// addresses resolve to answer.
+func newDNSBLServer(zone string, cidrs []*net.IPNet, answer net.IP, ttl uint32) *dnsblServer {
+	return &dnsblServer{zone: zone, index: newTrieIndex(collapseCIDRs(cidrs)), answer: answer.To4(), ttl: ttl}
+}
+
+// handleQuery builds the response to a query packet. Listed addresses get
+// an A record with the answer address and a TXT record naming the
+// matching block; unlisted ones get NXDOMAIN. As RFC 8020 requires, names
+// that exist without records of their own, the zone apex and partial
+// addresses with listed addresses below them, get NOERROR with no answers.
+func (s *dnsblServer) handleQuery(packet []byte) ([]byte, error) {
+	id, flags, q, err := parseDNSQuery(packet)
+	if err != nil {
+		if len(packet) < 12 {
+			return nil, err
+		}
+		return dnsResponse(id, flags, nil, dnsRcodeFormErr, nil), nil
+	}
+	question := packet[12:q.end]
+	if flags&0x7800 != 0 {
+		return dnsResponse(id, flags, question, dnsRcodeNotImp, nil), nil
+	}
+
+	ip, inZone := parseDNSBLName(q.Name, s.zone)
+	if !inZone || q.Class != dnsClassIN {
+		return dnsResponse(id, flags, question, dnsRcodeRefused, nil), nil
+	}
+	if ip == nil {
+		if s.emptyNode(q.Name) {
+			return dnsResponse(id, flags, question, dnsRcodeNoError, nil), nil
+		}
+		return dnsResponse(id, flags, question, dnsRcodeNXDomain, nil), nil
+	}
+	matches := s.index.Containing(ip)
+	if len(matches) == 0 {
+		return dnsResponse(id, flags, question, dnsRcodeNXDomain, nil), nil
+	}
+
+	var answers [][]byte
+	if q.Type == dnsTypeA || q.Type == 255 {
+		answers = append(answers, s.record(dnsTypeA, s.answer))
+	}
+	if q.Type == dnsTypeTXT || q.Type == 255 {
+		text := fmt.Sprintf("%s is listed in %s", ip, matches[0])
+		answers = append(answers, s.record(dnsTypeTXT, append([]byte{byte(len(text))}, text...)))
+	}
+	return dnsResponse(id, flags, question, dnsRcodeNoError, answers), nil
+}
+
+// emptyNode reports whether name, which does not query an address, is the
+// zone apex or an empty non-terminal: a partial address with listed
+// addresses below it.
+func (s *dnsblServer) emptyNode(name string) bool {
+	labels, _ := dnsblLabels(name, s.zone)
+	if len(labels) == 0 {
+		return true
+	}
+	for _, prefix := range dnsblPrefixes(labels) {
+		if len(s.index.Overlapping(prefix)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// record builds a resource record for the question name.
+func (s *dnsblServer) record(rrType uint16, data []byte) []byte {
+	rr := []byte{0xC0, 12} // Pointer to the question name.
+	rr = binary.BigEndian.AppendUint16(rr, rrType)
+	rr = binary.BigEndian.AppendUint16(rr, dnsClassIN)
+	rr = binary.BigEndian.AppendUint32(rr, s.ttl)
+	rr = binary.BigEndian.AppendUint16(rr, uint16(len(data)))
+	return append(rr, data...)
+}
+
+// dnsResponse builds a response echoing the query's id, opcode, recursion
+// desired flag and question.
+func dnsResponse(id, queryFlags uint16, question []byte, rcode uint16, answers [][]byte) []byte {
+	flags := uint16(0x8400) | queryFlags&0x7900 | rcode // QR, AA, opcode, RD.
+	qdcount := uint16(0)
+	if question != nil {
+		qdcount = 1
+	}
+	packet := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(packet[0:2], id)
+	binary.BigEndian.PutUint16(packet[2:4], flags)
+	binary.BigEndian.PutUint16(packet[4:6], qdcount)
+	binary.BigEndian.PutUint16(packet[6:8], uint16(len(answers)))
+	packet = append(packet, question...)
+	for _, answer := range answers {
+		packet = append(packet, answer...)
+	}
+	return packet
+}
+
+// serve answers queries on conn until it fails.
+func (s *dnsblServer) serve(conn net.PacketConn) error {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		response, err := s.handleQuery(buf[:n])
+		if err != nil {
+			continue
+		}
+		if _, err := conn.WriteTo(response, addr); err != nil {
+			log.Printf("error answering %s: %v", addr, err)
+		}
+	}
+}
+
+// runDNSBL implements the "dnsbl" command.
+func runDNSBL(args []string) error {
+	fs := flag.NewFlagSet("dnsbl", flag.ContinueOnError)
+	addr := fs.String("addr", ":5353", "UDP address to listen on")
+	zone := fs.String("zone", "", "DNS zone the list is served under, e.g. bl.example.com")
+	answer := fs.String("answer", "127.0.0.2", "address returned for listed IPs")
+	ttl := fs.Uint("ttl", 300, "TTL of the answers in seconds")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *zone == "" || fs.NArg() == 0 {
+		return fmt.Errorf("usage: dnsbl -zone <zone> [-addr host:port] [-answer ip] [-ttl seconds] <file>...")
+	}
+	answerIP := net.ParseIP(*answer)
+	if answerIP == nil || answerIP.To4() == nil {
+		return fmt.Errorf("invalid answer address: %s", *answer)
+	}
+
+	var cidrs []*net.IPNet
+	for _, filename := range fs.Args() {
+		fileCIDRs, err := readCIDRFile(filename)
+		if err != nil {
+			return err
+		}
+		cidrs = append(cidrs, fileCIDRs...)
+	}
+
+	conn, err := net.ListenPacket("udp", *addr)
+	if err != nil {
+		return fmt.Errorf("error listening: %v", err)
+	}
+	defer conn.Close()
+	log.Printf("serving %d blocks under %s on %s", len(collapseCIDRs(cidrs)), *zone, conn.LocalAddr())
+	return newDNSBLServer(*zone, cidrs, answerIP, uint32(*ttl)).serve(conn)
+}
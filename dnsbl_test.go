@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseDNSBLName(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		want   string
+		inZone bool
+	}{
+		{name: "IPv4", query: "1.2.0.192.bl.example.com", want: "192.0.2.1", inZone: true},
+		{name: "Trailing dot and case", query: "1.2.0.192.BL.Example.com.", want: "192.0.2.1", inZone: true},
+		{
+			name:   "IPv6 nibbles",
+			query:  "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.bl.example.com",
+			want:   "2001:db8::1",
+			inZone: true,
+		},
+		{name: "Zone apex", query: "bl.example.com", inZone: true},
+		{name: "Malformed octet", query: "1.2.0.300.bl.example.com", inZone: true},
+		{name: "Outside the zone", query: "1.2.0.192.example.org"},
+		{name: "Suffix without a label boundary", query: "1.2.0.192xbl.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, inZone := parseDNSBLName(tt.query, "bl.example.com")
+			if inZone != tt.inZone {
+				t.Fatalf("parseDNSBLName() inZone = %v, want %v", inZone, tt.inZone)
+			}
+			got := ""
+			if ip != nil {
+				got = ip.String()
+			}
+			if got != tt.want {
+				t.Errorf("parseDNSBLName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// dnsQuery builds a query packet for name and type.
+func dnsQuery(name string, qtype uint16) []byte {
+	packet := []byte{0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0}
+	for _, label := range strings.Split(name, ".") {
+		packet = append(packet, byte(len(label)))
+		packet = append(packet, label...)
+	}
+	packet = append(packet, 0)
+	packet = binary.BigEndian.AppendUint16(packet, qtype)
+	return binary.BigEndian.AppendUint16(packet, dnsClassIN)
+}
+
+func TestDNSBLHandleQuery(t *testing.T) {
+	cidrs, _ := parseCIDRList(strings.NewReader("192.0.2.0/24\n2001:db8::/32\n"))
+	s := newDNSBLServer("bl.example.com", cidrs, net.ParseIP("127.0.0.2"), 300)
+
+	tests := []struct {
+		name    string
+		query   string
+		qtype   uint16
+		rcode   uint16
+		answers int
+	}{
+		{name: "Listed A", query: "1.2.0.192.bl.example.com", qtype: dnsTypeA, answers: 1},
+		{name: "Listed TXT", query: "1.2.0.192.bl.example.com", qtype: dnsTypeTXT, answers: 1},
+		{name: "Listed AAAA", query: "1.2.0.192.bl.example.com", qtype: dnsTypeAAAA},
+		{name: "Unlisted", query: "1.3.0.192.bl.example.com", qtype: dnsTypeA, rcode: dnsRcodeNXDomain},
+		{name: "Malformed", query: "x.2.0.192.bl.example.com", qtype: dnsTypeA, rcode: dnsRcodeNXDomain},
+		{name: "Other zone", query: "1.2.0.192.example.org", qtype: dnsTypeA, rcode: dnsRcodeRefused},
+		{name: "Zone apex", query: "bl.example.com", qtype: dnsTypeA},
+		{name: "IPv4 empty non-terminal", query: "2.0.192.bl.example.com", qtype: dnsTypeA},
+		{name: "Unlisted IPv4 prefix", query: "3.0.192.bl.example.com", qtype: dnsTypeA, rcode: dnsRcodeNXDomain},
+		{name: "IPv6 empty non-terminal", query: "8.b.d.0.1.0.0.2.bl.example.com", qtype: dnsTypeTXT},
+		{name: "Unlisted IPv6 prefix", query: "9.b.d.0.1.0.0.2.bl.example.com", qtype: dnsTypeTXT, rcode: dnsRcodeNXDomain},
+		{name: "Label of either family", query: "2.bl.example.com", qtype: dnsTypeA},
+		{name: "Non-canonical octet", query: "02.0.192.bl.example.com", qtype: dnsTypeA, rcode: dnsRcodeNXDomain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := dnsQuery(tt.query, tt.qtype)
+			response, err := s.handleQuery(query)
+			if err != nil {
+				t.Fatalf("handleQuery() error = %v", err)
+			}
+			if id := binary.BigEndian.Uint16(response[0:2]); id != 0x1234 {
+				t.Errorf("id = %#x, want 0x1234", id)
+			}
+			flags := binary.BigEndian.Uint16(response[2:4])
+			if flags&0x8000 == 0 || flags&0x0100 == 0 {
+				t.Errorf("flags = %#x, want QR and RD set", flags)
+			}
+			if rcode := flags & 0xF; rcode != tt.rcode {
+				t.Errorf("rcode = %d, want %d", rcode, tt.rcode)
+			}
+			if n := binary.BigEndian.Uint16(response[6:8]); int(n) != tt.answers {
+				t.Errorf("answers = %d, want %d", n, tt.answers)
+			}
+			if !strings.HasPrefix(string(response[12:]), string(query[12:])) {
+				t.Errorf("response does not echo the question")
+			}
+		})
+	}
+}
+
+func TestDNSBLAnswerRecord(t *testing.T) {
+	cidrs, _ := parseCIDRList(strings.NewReader("192.0.2.0/24\n"))
+	s := newDNSBLServer("bl.example.com", cidrs, net.ParseIP("127.0.0.2"), 300)
+
+	query := dnsQuery("1.2.0.192.bl.example.com", dnsTypeA)
+	response, _ := s.handleQuery(query)
+	rr := response[len(query):]
+	if len(rr) != 16 {
+		t.Fatalf("answer length = %d, want 16", len(rr))
+	}
+	if ttl := binary.BigEndian.Uint32(rr[6:10]); ttl != 300 {
+		t.Errorf("ttl = %d, want 300", ttl)
+	}
+	if got := net.IP(rr[12:16]).String(); got != "127.0.0.2" {
+		t.Errorf("answer = %s, want 127.0.0.2", got)
+	}
+}
+
+func TestParseDNSQueryErrors(t *testing.T) {
+	good := dnsQuery("1.2.0.192.bl.example.com", dnsTypeA)
+	for _, packet := range [][]byte{good[:5], good[:20], good[:len(good)-1]} {
+		if _, _, _, err := parseDNSQuery(packet); err == nil {
+			t.Errorf("parseDNSQuery(%d bytes) expected an error", len(packet))
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// drainingLayout is the format of the removal dates of draining blocks.
+const drainingLayout = "2006-01-02"
+
+// How the merge treats draining blocks.
+const (
+	drainingInclude = "include"
+	drainingExclude = "exclude"
+	drainingExpired = "expired"
+)
+
+// parseDrainingDate parses the "draining" field of a JSON entry.
+func parseDrainingDate(value string) (time.Time, error) {
+	date, err := time.Parse(drainingLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid draining date %q, expected YYYY-MM-DD", value)
+	}
+	return date, nil
+}
+
+// drainingDue reports whether an entry is draining and its removal date is
+// on or before the day of now.
+func drainingDue(entry inputEntry, now time.Time) bool {
+	if entry.Draining.IsZero() {
+		return false
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return !entry.Draining.After(today)
+}
+
+// filterDraining applies a -draining mode to entries: include keeps them
+// all, exclude drops every draining entry and expired only those past their
+// removal date. It returns the entries kept and the number dropped.
+func filterDraining(entries []inputEntry, mode string, now time.Time) ([]inputEntry, int, error) {
+	switch mode {
+	case drainingInclude:
+		return entries, 0, nil
+	case drainingExclude, drainingExpired:
+	default:
+		return nil, 0, fmt.Errorf("unknown draining mode %q, expected include, exclude or expired", mode)
+	}
+	var kept []inputEntry
+	for _, entry := range entries {
+		if entry.Draining.IsZero() || (mode == drainingExpired && !drainingDue(entry, now)) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, len(entries) - len(kept), nil
+}
+
+// drainingBlock is a line of the "draining" report.
+type drainingBlock struct {
+	CIDR    string `json:"cidr"`
+	Name    string `json:"name,omitempty"`
+	Source  string `json:"source"`
+	Removal string `json:"removal"`
+	// Days is the number of days until the removal date, negative once it
+	// has passed.
+	Days int  `json:"days"`
+	Due  bool `json:"due"`
+}
+
+// drainingReport returns the draining entries, earliest removal first.
+func drainingReport(entries []inputEntry, now time.Time) []drainingBlock {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var draining []inputEntry
+	for _, entry := range entries {
+		if !entry.Draining.IsZero() {
+			draining = append(draining, entry)
+		}
+	}
+	sort.SliceStable(draining, func(i, j int) bool { return draining[i].Draining.Before(draining[j].Draining) })
+
+	blocks := []drainingBlock{}
+	for _, entry := range draining {
+		blocks = append(blocks, drainingBlock{
+			CIDR:    entry.CIDR.String(),
+			Name:    entry.Name,
+			Source:  entryPosition(entry),
+			Removal: entry.Draining.Format(drainingLayout),
+			Days:    int(entry.Draining.Sub(today).Hours() / 24),
+			Due:     drainingDue(entry, now),
+		})
+	}
+	return blocks
+}
+
+// renderDrainingReport writes blocks as a table followed by a summary, and
+// returns the number of blocks past their removal date.
+func renderDrainingReport(w io.Writer, blocks []drainingBlock) (int, error) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REMOVAL\tCIDR\tNAME\tSOURCE\tSTATUS")
+	due := 0
+	for _, b := range blocks {
+		name := b.Name
+		if name == "" {
+			name = "-"
+		}
+		status := fmt.Sprintf("due in %d days", b.Days)
+		switch {
+		case b.Days == 0:
+			status = "due today"
+		case b.Days < 0:
+			status = fmt.Sprintf("overdue by %d days", -b.Days)
+		}
+		if b.Due {
+			due++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", b.Removal, b.CIDR, name, b.Source, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return 0, err
+	}
+	_, err := fmt.Fprintf(w, "\ndue: %d, draining: %d\n", due, len(blocks)-due)
+	return due, err
+}
+
+// runDraining implements the "draining" command.
+func runDraining(args []string) error {
+	fs := flag.NewFlagSet("draining", flag.ContinueOnError)
+	at := fs.String("at", "", "report as of this date, YYYY-MM-DD, instead of today")
+	format := fs.String("output-format", "text", "output format: text or json")
+	failDue := fs.Bool("fail-due", false, "exit with an error when blocks are past their removal date")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: draining [--at=YYYY-MM-DD] [--output-format=text|json] [--fail-due] <file>...")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown output format: %s", *format)
+	}
+	now := time.Now().UTC()
+	if *at != "" {
+		var err error
+		if now, err = time.Parse(drainingLayout, *at); err != nil {
+			return fmt.Errorf("invalid date: %s", *at)
+		}
+	}
+	var entries []inputEntry
+	for _, filename := range fs.Args() {
+		fileEntries, err := readCIDRFileEntries(filename)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	blocks := drainingReport(entries, now)
+	due := 0
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(blocks); err != nil {
+			return err
+		}
+		for _, b := range blocks {
+			if b.Due {
+				due++
+			}
+		}
+	} else {
+		var err error
+		if due, err = renderDrainingReport(os.Stdout, blocks); err != nil {
+			return err
+		}
+	}
+	if *failDue && due > 0 {
+		return fmt.Errorf("%d blocks are past their removal date", due)
+	}
+	return nil
+}
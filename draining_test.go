@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const drainingSample = `[
+  "10.0.0.0/24",
+  {"cidr": "10.1.0.0/24", "name": "old-dc", "draining": "2024-05-01"},
+  {"cidr": "10.2.0.0/24", "draining": "2024-07-01"},
+  {"cidr": "10.3.0.0/24", "name": "legacy-vpn", "draining": "2024-06-01"}
+]`
+
+func drainingEntries(t *testing.T) []inputEntry {
+	t.Helper()
+	entries, err := scanCIDRJSON(strings.NewReader(drainingSample))
+	if err != nil {
+		t.Fatalf("scanCIDRJSON() error = %v", err)
+	}
+	for i := range entries {
+		entries[i].File = "plan.json"
+	}
+	return entries
+}
+
+func TestScanCIDRJSONDraining(t *testing.T) {
+	entries := drainingEntries(t)
+	if !entries[0].Draining.IsZero() {
+		t.Errorf("entry 1 draining = %v, want none", entries[0].Draining)
+	}
+	if got := entries[1].Draining.Format(drainingLayout); got != "2024-05-01" {
+		t.Errorf("entry 2 draining = %s, want 2024-05-01", got)
+	}
+
+	_, err := scanCIDRJSON(strings.NewReader(`[{"cidr": "10.0.0.0/24", "draining": "soon"}]`))
+	if err == nil || !strings.Contains(err.Error(), "entry 1: invalid draining date") {
+		t.Errorf("scanCIDRJSON() error = %v, want invalid draining date", err)
+	}
+}
+
+func TestFilterDraining(t *testing.T) {
+	now := time.Date(2024, 6, 1, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		mode    string
+		want    string
+		dropped int
+	}{
+		{drainingInclude, "10.0.0.0/24,10.1.0.0/24,10.2.0.0/24,10.3.0.0/24", 0},
+		{drainingExclude, "10.0.0.0/24", 3},
+		{drainingExpired, "10.0.0.0/24,10.2.0.0/24", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			kept, dropped, err := filterDraining(drainingEntries(t), tt.mode, now)
+			if err != nil {
+				t.Fatalf("filterDraining() error = %v", err)
+			}
+			var cidrs []string
+			for _, entry := range kept {
+				cidrs = append(cidrs, entry.CIDR.String())
+			}
+			if got := strings.Join(cidrs, ","); got != tt.want || dropped != tt.dropped {
+				t.Errorf("filterDraining() = %q, %d, want %q, %d", got, dropped, tt.want, tt.dropped)
+			}
+		})
+	}
+
+	if _, _, err := filterDraining(nil, "drop", now); err == nil {
+		t.Error("filterDraining() with unknown mode succeeded")
+	}
+}
+
+func TestDrainingReport(t *testing.T) {
+	blocks := drainingReport(drainingEntries(t), time.Date(2024, 6, 1, 15, 0, 0, 0, time.UTC))
+	var got []string
+	for _, b := range blocks {
+		got = append(got, b.Removal+" "+b.CIDR+" "+b.Source)
+	}
+	want := "2024-05-01 10.1.0.0/24 plan.json:2,2024-06-01 10.3.0.0/24 plan.json:4,2024-07-01 10.2.0.0/24 plan.json:3"
+	if strings.Join(got, ",") != want {
+		t.Errorf("drainingReport() = %q, want %q", strings.Join(got, ","), want)
+	}
+
+	var buf bytes.Buffer
+	due, err := renderDrainingReport(&buf, blocks)
+	if err != nil {
+		t.Fatalf("renderDrainingReport() error = %v", err)
+	}
+	if due != 2 {
+		t.Errorf("renderDrainingReport() due = %d, want 2", due)
+	}
+	for _, status := range []string{"overdue by 31 days", "due today", "due in 30 days", "due: 2, draining: 1"} {
+		if !strings.Contains(buf.String(), status) {
+			t.Errorf("renderDrainingReport() output lacks %q:\n%s", status, buf.String())
+		}
+	}
+}
+
+func TestDrainingConflict(t *testing.T) {
+	entries, err := scanCIDRJSON(strings.NewReader(`[
+  {"cidr": "10.1.0.0/24", "draining": "2024-05-01"},
+  {"cidr": "10.1.0.0/24", "draining": "2024-06-01"}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, conflicts, err := resolveMetadataConflicts(entries, policyLastWins)
+	if err != nil {
+		t.Fatalf("resolveMetadataConflicts() error = %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("resolveMetadataConflicts() conflicts = %v, want 1", conflicts)
+	}
+	if got := kept[0].Draining.Format(drainingLayout); got != "2024-06-01" {
+		t.Errorf("kept draining = %s, want 2024-06-01", got)
+	}
+}
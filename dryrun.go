@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDiffCells bounds the size of the table used to diff the changed middle
+// of two files. Larger changes are shown as a removal of all the old lines
+// followed by the new ones.
+const maxDiffCells = 4000000
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 3
+
+// diffOp is one line of an edit script: ' ' kept, '-' removed, '+' added.
+type diffOp struct {
+	Kind byte
+	Line string
+}
+
+// diffLines returns an edit script turning a into b.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > maxDiffCells {
+		for _, line := range midA {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range midB {
+			ops = append(ops, diffOp{'+', line})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of
+		// midA[i:] and midB[j:].
+		lcs := make([][]int, len(midA)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(midB)+1)
+		}
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(midA) || j < len(midB) {
+			switch {
+			case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+				ops = append(ops, diffOp{' ', midA[i]})
+				i++
+				j++
+			case j == len(midB) || (i < len(midA) && lcs[i+1][j] >= lcs[i][j+1]):
+				ops = append(ops, diffOp{'-', midA[i]})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', midB[j]})
+				j++
+			}
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// writeUnifiedDiff writes the edit script as unified diff hunks.
+func writeUnifiedDiff(w io.Writer, ops []diffOp) {
+	for start := 0; start < len(ops); {
+		// Find the next change and the end of its hunk.
+		first := start
+		for first < len(ops) && ops[first].Kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			return
+		}
+		end := first
+		for unchanged := 0; end < len(ops) && unchanged <= 2*diffContext; end++ {
+			if ops[end].Kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		for end > first && ops[end-1].Kind == ' ' {
+			end--
+		}
+		from := first - diffContext
+		if from < start {
+			from = start
+		}
+		to := end + diffContext
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		lineA, lineB := 1, 1
+		for _, op := range ops[:from] {
+			if op.Kind != '+' {
+				lineA++
+			}
+			if op.Kind != '-' {
+				lineB++
+			}
+		}
+		countA, countB := 0, 0
+		for _, op := range ops[from:to] {
+			if op.Kind != '+' {
+				countA++
+			}
+			if op.Kind != '-' {
+				countB++
+			}
+		}
+		// Empty ranges are numbered by the line before them.
+		if countA == 0 {
+			lineA--
+		}
+		if countB == 0 {
+			lineB--
+		}
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+		for _, op := range ops[from:to] {
+			fmt.Fprintf(w, "%c%s\n", op.Kind, op.Line)
+		}
+		start = to
+	}
+}
+
+// splitLines splits text into lines without their line endings.
+func splitLines(text []byte) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(text), "\n"), "\n")
+}
+
+// isText reports whether content can be shown as a line diff.
+func isText(content []byte) bool {
+	return utf8.Valid(content) && bytes.IndexByte(content, 0) < 0
+}
+
+// previewFile describes, instead of writing it, the change writing content
+// to the named file would make: a unified diff for text files and the size
+// for binary ones.
+func previewFile(w io.Writer, filename string, content []byte) error {
+	current, err := os.ReadFile(filename)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+	switch {
+	case exists && bytes.Equal(current, content):
+		fmt.Fprintf(w, "Dry run: %s is unchanged\n", filename)
+	case !isText(content) || (exists && !isText(current)):
+		if exists {
+			fmt.Fprintf(w, "Dry run: would replace %s (%d bytes) with %d bytes\n", filename, len(current), len(content))
+		} else {
+			fmt.Fprintf(w, "Dry run: would create %s with %d bytes\n", filename, len(content))
+		}
+	default:
+		if exists {
+			fmt.Fprintf(w, "Dry run: would change %s:\n", filename)
+			fmt.Fprintf(w, "--- %s\n+++ %s\n", filename, filename)
+		} else {
+			fmt.Fprintf(w, "Dry run: would create %s:\n", filename)
+			fmt.Fprintf(w, "--- /dev/null\n+++ %s\n", filename)
+		}
+		writeUnifiedDiff(w, diffLines(splitLines(current), splitLines(content)))
+	}
+	return nil
+}
+
+// previewStore describes, instead of saving it, how saving cidrs to the
+// store at url would change the stored set.
+func previewStore(w io.Writer, url string, stored, cidrs []*net.IPNet) {
+	old := map[string]bool{}
+	for _, cidr := range stored {
+		old[cidr.String()] = true
+	}
+	saved := map[string]bool{}
+	var added []string
+	for _, cidr := range cidrs {
+		saved[cidr.String()] = true
+		if !old[cidr.String()] {
+			added = append(added, cidr.String())
+		}
+	}
+	var removed []string
+	for _, cidr := range stored {
+		if !saved[cidr.String()] {
+			removed = append(removed, cidr.String())
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		fmt.Fprintf(w, "Dry run: the set in %s is unchanged\n", url)
+		return
+	}
+	fmt.Fprintf(w, "Dry run: would save %d blocks to %s:\n", len(cidrs), url)
+	for _, cidr := range removed {
+		fmt.Fprintf(w, "-%s\n", cidr)
+	}
+	for _, cidr := range added {
+		fmt.Fprintf(w, "+%s\n", cidr)
+	}
+}
+
+// saveJSON writes v to the named file like writeJSONFile, or previews the
+// change when dryRun is set.
+func saveJSON(filename string, v interface{}, dryRun bool) error {
+	if !dryRun {
+		return writeJSONFile(filename, v)
+	}
+	content, err := marshalJSONDocument(v)
+	if err != nil {
+		return err
+	}
+	return previewFile(os.Stdout, filename, content)
+}
+
+// marshalJSONDocument encodes v as written by writeJSONFile.
+func marshalJSONDocument(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return nil, fmt.Errorf("error encoding JSON: %v", err)
+	}
+	return buf.Bytes(), nil
+}
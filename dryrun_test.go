@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{name: "Unchanged", a: "a\nb\n", b: "a\nb\n", want: ""},
+		{name: "Added line", a: "a\nb\nc\n", b: "a\nb\nx\nc\n", want: "@@ -1,3 +1,4 @@\n a\n b\n+x\n c\n"},
+		{name: "Replaced line", a: "a\nb\nc\n", b: "a\nx\nc\n", want: "@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{name: "New file", a: "", b: "a\nb\n", want: "@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{
+			name: "Separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			want: "@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -9,4 +10,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			writeUnifiedDiff(&out, diffLines(splitLines([]byte(tt.a)), splitLines([]byte(tt.b))))
+			if out.String() != tt.want {
+				t.Errorf("writeUnifiedDiff() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewFile(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "out.json")
+	if err := os.WriteFile(existing, []byte("a\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		want     string
+	}{
+		{name: "Unchanged", filename: existing, content: "a\nb\n", want: "is unchanged"},
+		{name: "Changed", filename: existing, content: "a\nc\n", want: "would change " + existing + ":\n--- " + existing + "\n+++ " + existing + "\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n"},
+		{name: "New", filename: filepath.Join(dir, "new.json"), content: "a\n", want: "+++ " + filepath.Join(dir, "new.json") + "\n@@ -0,0 +1,1 @@\n+a\n"},
+		{name: "Binary", filename: filepath.Join(dir, "out.pb"), content: "\x00\x01", want: "would create " + filepath.Join(dir, "out.pb") + " with 2 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := previewFile(&out, tt.filename, []byte(tt.content)); err != nil {
+				t.Fatalf("previewFile() error = %v", err)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("previewFile() = %q, want it to contain %q", out.String(), tt.want)
+			}
+		})
+	}
+
+	data, _ := os.ReadFile(existing)
+	if string(data) != "a\nb\n" {
+		t.Errorf("previewFile() changed the file to %q", data)
+	}
+}
+
+func TestPreviewStore(t *testing.T) {
+	stored, _ := parseCIDRList(strings.NewReader("10.0.0.0/24\n10.0.1.0/24\n"))
+	cidrs, _ := parseCIDRList(strings.NewReader("10.0.0.0/24\n192.0.2.0/24\n"))
+
+	var out strings.Builder
+	previewStore(&out, "consul://localhost/set", stored, cidrs)
+	want := "Dry run: would save 2 blocks to consul://localhost/set:\n-10.0.1.0/24\n+192.0.2.0/24\n"
+	if out.String() != want {
+		t.Errorf("previewStore() = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	previewStore(&out, "consul://localhost/set", stored, stored)
+	if !strings.Contains(out.String(), "unchanged") {
+		t.Errorf("previewStore() = %q, want an unchanged set", out.String())
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// compareCIDRSets compares the address space covered by two lists of CIDR
+// blocks. It returns the blocks covered only by a and only by b; both are
+// empty when the lists cover exactly the same addresses.
+func compareCIDRSets(a, b []*net.IPNet) (onlyA, onlyB []*net.IPNet) {
+	return subtractCIDRs(a, b), subtractCIDRs(b, a)
+}
+
+// runEqual implements the "equal" command.
+func runEqual(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: equal <file-a> <file-b>")
+	}
+	a, err := readCIDRFile(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := readCIDRFile(args[1])
+	if err != nil {
+		return err
+	}
+
+	onlyA, onlyB := compareCIDRSets(a, b)
+	if len(onlyA) == 0 && len(onlyB) == 0 {
+		fmt.Println("Both files cover the same address space.")
+		return nil
+	}
+
+	fmt.Printf("Only in %s:\n", args[0])
+	for _, cidr := range onlyA {
+		fmt.Printf("- %s\n", cidr)
+	}
+	fmt.Printf("Only in %s:\n", args[1])
+	for _, cidr := range onlyB {
+		fmt.Printf("+ %s\n", cidr)
+	}
+	return fmt.Errorf("address space differs")
+}
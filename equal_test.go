@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareCIDRSets(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      string
+		wantOnlyA string
+		wantOnlyB string
+	}{
+		{
+			name: "Same blocks sliced differently",
+			a:    "10.0.0.0/24\n",
+			b:    "10.0.0.0/25\n10.0.0.128/26\n10.0.0.192/26\n",
+		},
+		{
+			name: "Overlapping input blocks",
+			a:    "10.0.0.0/16\n10.0.1.0/24\n",
+			b:    "10.0.0.0/16\n",
+		},
+		{
+			name:      "Missing range",
+			a:         "10.0.0.0/24\n",
+			b:         "10.0.0.0/25\n",
+			wantOnlyA: "10.0.0.128/25",
+		},
+		{
+			name:      "Differences on both sides",
+			a:         "10.0.0.0/24\n192.168.0.0/24\n",
+			b:         "10.0.0.0/23\n",
+			wantOnlyA: "192.168.0.0/24",
+			wantOnlyB: "10.0.1.0/24",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := parseCIDRList(strings.NewReader(tt.a))
+			if err != nil {
+				t.Fatalf("parseCIDRList() error = %v", err)
+			}
+			b, err := parseCIDRList(strings.NewReader(tt.b))
+			if err != nil {
+				t.Fatalf("parseCIDRList() error = %v", err)
+			}
+			onlyA, onlyB := compareCIDRSets(a, b)
+			if got := joinCIDRs(onlyA); got != tt.wantOnlyA {
+				t.Errorf("compareCIDRSets() onlyA = %q, want %q", got, tt.wantOnlyA)
+			}
+			if got := joinCIDRs(onlyB); got != tt.wantOnlyB {
+				t.Errorf("compareCIDRSets() onlyB = %q, want %q", got, tt.wantOnlyB)
+			}
+		})
+	}
+}
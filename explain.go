@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// prefixBits renders the network address of cidr in binary, grouped by
+// octet for IPv4 and by 16-bit group for IPv6, with a '|' marking where the
+// prefix ends.
+func prefixBits(cidr *net.IPNet) string {
+	ip := cidr.IP
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	ones, bits := cidr.Mask.Size()
+	group := 8
+	if bits == 128 {
+		group = 16
+	}
+
+	var b strings.Builder
+	for i := 0; i < bits; i++ {
+		if i > 0 && i == ones {
+			b.WriteByte('|')
+		} else if i > 0 && i%group == 0 {
+			b.WriteByte('.')
+		}
+		if ip[i/8]&(0x80>>uint(i%8)) != 0 {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	}
+	if ones == bits {
+		b.WriteByte('|')
+	}
+	return b.String()
+}
+
+// entryLocation describes where an input entry was read from.
+func entryLocation(entry inputEntry) string {
+	switch {
+	case entry.File == "":
+		return "input"
+	case entry.Line == 0:
+		return entry.File
+	default:
+		return fmt.Sprintf("%s:%d", entry.File, entry.Line)
+	}
+}
+
+// explainMerge writes, for every merged block, its binary prefix alignment
+// and what happened to each input entry that ended up in it: kept as is,
+// dropped as a duplicate, swallowed by a larger input block or combined
+// with other inputs to fill the block.
+func explainMerge(w io.Writer, merged []*net.IPNet, entries []inputEntry) {
+	for _, block := range merged {
+		ones, _ := block.Mask.Size()
+		fmt.Fprintf(w, "%s\n", block)
+		fmt.Fprintf(w, "  alignment: %s (prefix /%d, %s addresses)\n", prefixBits(block), ones, cidrSize(block))
+
+		var members []inputEntry
+		for _, entry := range entries {
+			if cidrsOverlap(block, entry.CIDR) {
+				members = append(members, entry)
+			}
+		}
+
+		seen := map[string]inputEntry{}
+		for _, entry := range members {
+			cidr := entry.CIDR.String()
+			fmt.Fprintf(w, "  %s (%s): %s\n", cidr, entryLocation(entry), explainEntry(block, entry, members, seen))
+			if _, ok := seen[cidr]; !ok {
+				seen[cidr] = entry
+			}
+		}
+	}
+}
+
+// explainEntry returns the reason entry, one of members, ended up in block.
+// seen holds the earlier members by block.
+func explainEntry(block *net.IPNet, entry inputEntry, members []inputEntry, seen map[string]inputEntry) string {
+	cidr := entry.CIDR.String()
+	if first, ok := seen[cidr]; ok {
+		return fmt.Sprintf("duplicate of %s", entryLocation(first))
+	}
+	if cidr == block.String() {
+		return "kept unchanged"
+	}
+	for _, other := range members {
+		if other.CIDR.String() != cidr && cidrContains(other.CIDR, entry.CIDR) && cidrContains(block, other.CIDR) {
+			return fmt.Sprintf("swallowed by %s (%s), which contains it", other.CIDR, entryLocation(other))
+		}
+	}
+	var others []string
+	for _, other := range members {
+		if other.CIDR.String() != cidr {
+			others = append(others, other.CIDR.String())
+		}
+	}
+	if len(others) == 0 {
+		return fmt.Sprintf("widened to %s", block)
+	}
+	return fmt.Sprintf("combined with %s to fill %s", strings.Join(deduplicateStrings(others), ", "), block)
+}
+
+// deduplicateStrings drops repeated values, keeping the first occurrence.
+func deduplicateStrings(values []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
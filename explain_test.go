@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrefixBits(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Octet boundary", input: "10.0.0.0/8", want: "00001010|00000000.00000000.00000000"},
+		{name: "Inside an octet", input: "192.168.0.0/15", want: "11000000.1010100|0.00000000.00000000"},
+		{name: "Host route", input: "10.0.0.1/32", want: "00001010.00000000.00000000.00000001|"},
+		{name: "Whole space", input: "0.0.0.0/0", want: "00000000.00000000.00000000.00000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cidr, _ := parseCIDR(tt.input)
+			if got := prefixBits(cidr); got != tt.want {
+				t.Errorf("prefixBits() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	cidr, _ := parseCIDR("2001:db8::/32")
+	if got := prefixBits(cidr); !strings.HasPrefix(got, "0010000000000001.0000110110111000|0000000000000000.") {
+		t.Errorf("prefixBits() = %q", got)
+	}
+}
+
+func TestExplainMerge(t *testing.T) {
+	entries, _ := scanCIDRList(strings.NewReader("10.0.0.0/8\n10.1.0.0/16\n10.0.0.0/8\n192.168.1.0/24\n"))
+	for i := range entries {
+		entries[i].File = "a.txt"
+	}
+	merged, _ := parseCIDRList(strings.NewReader("10.0.0.0/8\n192.168.1.0/24\n"))
+
+	var buf bytes.Buffer
+	explainMerge(&buf, merged, entries)
+	got := buf.String()
+
+	for _, want := range []string{
+		"10.0.0.0/8\n  alignment: 00001010|00000000.00000000.00000000 (prefix /8, 16777216 addresses)\n",
+		"  10.0.0.0/8 (a.txt:1): kept unchanged\n",
+		"  10.1.0.0/16 (a.txt:2): swallowed by 10.0.0.0/8 (a.txt:1), which contains it\n",
+		"  10.0.0.0/8 (a.txt:3): duplicate of a.txt:1\n",
+		"  192.168.1.0/24 (a.txt:4): kept unchanged\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("explainMerge() output is missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestExplainEntryCombined(t *testing.T) {
+	entries, _ := scanCIDRList(strings.NewReader("10.0.0.0/25\n10.0.0.128/25\n"))
+	block, _ := parseCIDR("10.0.0.0/24")
+
+	got := explainEntry(block, entries[0], entries, map[string]inputEntry{})
+	if want := "combined with 10.0.0.128/25 to fill 10.0.0.0/24"; got != want {
+		t.Errorf("explainEntry() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+)
+
+// blockFormats maps the names accepted by -block-format to functions
+// writing a block in that form.
+var blockFormats = map[string]func(cidr *net.IPNet) string{
+	// cidr is the usual 10.0.0.0/8 form.
+	"cidr": func(cidr *net.IPNet) string { return cidr.String() },
+	// netmask is "10.0.0.0 255.0.0.0", as used by Cisco and route commands.
+	"netmask": func(cidr *net.IPNet) string { return cidr.IP.String() + " " + net.IP(cidr.Mask).String() },
+	// slash-netmask is "10.0.0.0/255.0.0.0", as accepted by iptables.
+	"slash-netmask": func(cidr *net.IPNet) string { return cidr.IP.String() + "/" + net.IP(cidr.Mask).String() },
+	// range is "10.0.0.0-10.255.255.255".
+	"range": func(cidr *net.IPNet) string {
+		first, _ := nthAddress(cidr, big.NewInt(0))
+		last, _ := nthAddress(cidr, big.NewInt(-1))
+		return first.String() + "-" + last.String()
+	},
+}
+
+// blockFormatter returns the formatting function for a -block-format name.
+func blockFormatter(name string) (func(cidr *net.IPNet) string, error) {
+	format, ok := blockFormats[name]
+	if !ok {
+		var names []string
+		for name := range blockFormats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown block format: %s (expected %s)", name, strings.Join(names, ", "))
+	}
+	return format, nil
+}
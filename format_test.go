@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestBlockFormats(t *testing.T) {
+	tests := []struct {
+		cidr   string
+		format string
+		want   string
+	}{
+		{cidr: "10.0.0.0/8", format: "cidr", want: "10.0.0.0/8"},
+		{cidr: "10.0.0.0/8", format: "netmask", want: "10.0.0.0 255.0.0.0"},
+		{cidr: "192.168.1.0/26", format: "slash-netmask", want: "192.168.1.0/255.255.255.192"},
+		{cidr: "192.168.1.0/26", format: "range", want: "192.168.1.0-192.168.1.63"},
+		{cidr: "192.0.2.7/32", format: "range", want: "192.0.2.7-192.0.2.7"},
+		{cidr: "2001:db8::/32", format: "netmask", want: "2001:db8:: ffff:ffff::"},
+		{cidr: "2001:db8::/32", format: "range", want: "2001:db8::-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format+" "+tt.cidr, func(t *testing.T) {
+			format, err := blockFormatter(tt.format)
+			if err != nil {
+				t.Fatalf("blockFormatter() error = %v", err)
+			}
+			_, cidr, _ := net.ParseCIDR(tt.cidr)
+			if got := format(cidr); got != tt.want {
+				t.Errorf("format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := blockFormatter("hex"); err == nil {
+		t.Errorf("blockFormatter(%q) expected an error", "hex")
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"net"
+	"time"
+
+	"D/Pratik/Code/cidr-converter/client"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and
+// the Unix epoch (1970).
+const ntpEpochOffset = 2208988800
+
+// documentationBlocks are the prefixes reserved for documentation and
+// examples: TEST-NET-1 to 3 (RFC 5737) and the IPv6 documentation prefixes
+// (RFC 3849 and RFC 9637), in the order lab ranges are carved from them.
+var documentationBlocks = map[bool][]string{
+	false: {"192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24"},
+	true:  {"2001:db8::/32", "3fff::/20"},
+}
+
+// ntpTimestamp returns t in the 64-bit NTP format: seconds since 1900 in
+// the upper 32 bits and the fraction of a second in the lower 32.
+func ntpTimestamp(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / 1e9
+	return seconds<<32 | fraction
+}
+
+// eui64FromMAC returns the modified EUI-64 identifier of mac (RFC 4291
+// appendix A): FFFE inserted in the middle and the universal/local bit
+// inverted. An 8-byte address is used as is, apart from that bit.
+func eui64FromMAC(mac net.HardwareAddr) ([]byte, error) {
+	var id []byte
+	switch len(mac) {
+	case 6:
+		id = []byte{mac[0], mac[1], mac[2], 0xff, 0xfe, mac[3], mac[4], mac[5]}
+	case 8:
+		id = append([]byte(nil), mac...)
+	default:
+		return nil, fmt.Errorf("cannot derive an EUI-64 from %s", mac)
+	}
+	id[0] ^= 0x02
+	return id, nil
+}
+
+// systemEUI64 returns the EUI-64 of the first interface with a hardware
+// address, or a random identifier when there is none, as RFC 4193 allows.
+func systemEUI64() []byte {
+	if ifaces, err := net.Interfaces(); err == nil {
+		for _, iface := range ifaces {
+			if iface.Flags&net.FlagLoopback != 0 {
+				continue
+			}
+			if id, err := eui64FromMAC(iface.HardwareAddr); err == nil {
+				return id
+			}
+		}
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	return id
+}
+
+// ulaPrefix returns the unique local /48 derived from the time and an
+// EUI-64 with the algorithm of RFC 4193 section 3.2.2: the global ID is
+// the low 40 bits of the SHA-1 of the NTP timestamp followed by the EUI-64.
+func ulaPrefix(now time.Time, eui64 []byte) *net.IPNet {
+	key := make([]byte, 8, 8+len(eui64))
+	binary.BigEndian.PutUint64(key, ntpTimestamp(now))
+	digest := sha1.Sum(append(key, eui64...))
+
+	ip := make(net.IP, net.IPv6len)
+	ip[0] = 0xfd
+	copy(ip[1:6], digest[len(digest)-5:])
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(48, 128)}
+}
+
+// carveDocumentation returns count consecutive blocks of prefix length
+// prefix taken from the documentation blocks of the family, for lab and
+// example addressing plans.
+func carveDocumentation(prefix, count int, ipv6 bool) ([]*net.IPNet, error) {
+	var carved []*net.IPNet
+	for _, block := range documentationBlocks[ipv6] {
+		if len(carved) == count {
+			break
+		}
+		_, ipnet, _ := net.ParseCIDR(block)
+		if ones, _ := ipnet.Mask.Size(); prefix < ones {
+			continue
+		}
+		err := client.Subnets(ipnet, prefix, func(subnet *net.IPNet) bool {
+			carved = append(carved, subnet)
+			return len(carved) < count
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(carved) < count {
+		return nil, fmt.Errorf("the documentation blocks hold only %d /%d blocks", len(carved), prefix)
+	}
+	return carved, nil
+}
+
+// runGenerate implements the "generate" command.
+func runGenerate(args []string) error {
+	usage := fmt.Errorf("usage: generate ula [-mac addr] [-subnets n] | generate doc [-6] -prefix n [-count n]")
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "ula":
+		return runGenerateULA(args[1:])
+	case "doc":
+		return runGenerateDoc(args[1:])
+	}
+	return usage
+}
+
+// runGenerateULA implements "generate ula".
+func runGenerateULA(args []string) error {
+	fs := flag.NewFlagSet("generate ula", flag.ContinueOnError)
+	macFlag := fs.String("mac", "", "hardware address to derive the EUI-64 from instead of the first interface's")
+	count := fs.Int("subnets", 0, "also print the first n /64 subnets of the prefix")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *count < 0 || *count > 1<<16 {
+		return fmt.Errorf("usage: generate ula [-mac addr] [-subnets n], with n at most 65536")
+	}
+	eui64 := systemEUI64()
+	if *macFlag != "" {
+		mac, err := net.ParseMAC(*macFlag)
+		if err != nil {
+			return err
+		}
+		if eui64, err = eui64FromMAC(mac); err != nil {
+			return err
+		}
+	}
+
+	prefix := ulaPrefix(time.Now(), eui64)
+	fmt.Println(prefix)
+	printed := 0
+	return client.Subnets(prefix, 64, func(subnet *net.IPNet) bool {
+		if printed == *count {
+			return false
+		}
+		fmt.Println(subnet)
+		printed++
+		return true
+	})
+}
+
+// runGenerateDoc implements "generate doc".
+func runGenerateDoc(args []string) error {
+	fs := flag.NewFlagSet("generate doc", flag.ContinueOnError)
+	ipv6 := fs.Bool("6", false, "carve from the IPv6 documentation prefixes")
+	prefix := fs.Int("prefix", 0, "prefix length of the blocks")
+	count := fs.Int("count", 1, "number of blocks")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *prefix <= 0 || *count <= 0 {
+		return fmt.Errorf("usage: generate doc [-6] -prefix n [-count n]")
+	}
+	blocks, err := carveDocumentation(*prefix, *count, *ipv6)
+	if err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		fmt.Println(block)
+	}
+	return nil
+}
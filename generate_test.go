@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNTPTimestamp(t *testing.T) {
+	got := ntpTimestamp(time.Unix(0, 500000000))
+	if seconds, fraction := got>>32, uint32(got); seconds != ntpEpochOffset || fraction != 1<<31 {
+		t.Errorf("ntpTimestamp() = %d.%d, want %d.%d", seconds, fraction, ntpEpochOffset, uint32(1<<31))
+	}
+}
+
+func TestEUI64FromMAC(t *testing.T) {
+	tests := []struct {
+		name    string
+		mac     string
+		want    []byte
+		wantErr bool
+	}{
+		{name: "48-bit address", mac: "00:1b:21:3a:4f:5c", want: []byte{0x02, 0x1b, 0x21, 0xff, 0xfe, 0x3a, 0x4f, 0x5c}},
+		{name: "Local bit inverted", mac: "02:00:00:00:00:01", want: []byte{0x00, 0x00, 0x00, 0xff, 0xfe, 0x00, 0x00, 0x01}},
+		{name: "64-bit address", mac: "00:11:22:33:44:55:66:77", want: []byte{0x02, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}},
+		{name: "20-byte address", mac: "00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mac, err := net.ParseMAC(tt.mac)
+			if err != nil {
+				t.Fatalf("ParseMAC() error = %v", err)
+			}
+			got, err := eui64FromMAC(mac)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("eui64FromMAC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("eui64FromMAC() = % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestULAPrefix(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	eui64 := []byte{0x02, 0x1b, 0x21, 0xff, 0xfe, 0x3a, 0x4f, 0x5c}
+
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, ntpTimestamp(now))
+	digest := sha1.Sum(append(key, eui64...))
+	want := net.IP(append([]byte{0xfd}, append(digest[15:], make([]byte, 10)...)...))
+
+	got := ulaPrefix(now, eui64)
+	if ones, _ := got.Mask.Size(); ones != 48 || !got.IP.Equal(want) {
+		t.Errorf("ulaPrefix() = %s, want %s/48", got, want)
+	}
+	if other := ulaPrefix(now.Add(time.Millisecond), eui64); other.IP.Equal(got.IP) {
+		t.Errorf("ulaPrefix() = %s at two different times", got)
+	}
+}
+
+func TestCarveDocumentation(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  int
+		count   int
+		ipv6    bool
+		want    string
+		wantErr bool
+	}{
+		{name: "Subnets of TEST-NET-1", prefix: 26, count: 3, want: "192.0.2.0/26,192.0.2.64/26,192.0.2.128/26"},
+		{name: "Continues in the next block", prefix: 25, count: 3, want: "192.0.2.0/25,192.0.2.128/25,198.51.100.0/25"},
+		{name: "Whole blocks", prefix: 24, count: 3, want: "192.0.2.0/24,198.51.100.0/24,203.0.113.0/24"},
+		{name: "IPv6", prefix: 48, count: 2, ipv6: true, want: "2001:db8::/48,2001:db8:1::/48"},
+		{name: "Larger than the IPv4 blocks", prefix: 23, count: 1, wantErr: true},
+		{name: "Not enough space", prefix: 30, count: 200, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := carveDocumentation(tt.prefix, tt.count, tt.ipv6)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("carveDocumentation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if joinCIDRs(got) != tt.want {
+				t.Errorf("carveDocumentation() = %q, want %q", joinCIDRs(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// geoDB maps upper-case ISO 3166-1 alpha-2 country codes to the blocks
+// assigned to them.
+type geoDB map[string][]*net.IPNet
+
+// parseGeoCSV reads a country database in CSV form. Each line is either
+// "network,country" or "first,last,country", which covers the DB-IP exports
+// and GeoLite2 blocks joined with their locations. The first and last
+// addresses may also be decimal integers, as in the IP2Location LITE
+// exports. Quotes, a header line and '#' comments are ignored.
+func parseGeoCSV(r io.Reader) (geoDB, error) {
+	db := geoDB{}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		for i := range fields {
+			fields[i] = strings.Trim(strings.TrimSpace(fields[i]), `"`)
+		}
+
+		var cidrs []*net.IPNet
+		var country string
+		switch {
+		case len(fields) >= 3 && net.ParseIP(fields[0]) != nil:
+			first, last := net.ParseIP(fields[0]), net.ParseIP(fields[1])
+			if last == nil {
+				return nil, fmt.Errorf("line %d: invalid IP %q", lineNum, fields[1])
+			}
+			var err error
+			if cidrs, err = rangeToCIDRs(first, last); err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			country = fields[2]
+		case len(fields) >= 3 && isDecimal(fields[0]):
+			first, last, err := parseDecimalRange(fields[0], fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			if cidrs, err = rangeToCIDRs(first, last); err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			country = fields[2]
+		case len(fields) >= 2:
+			_, ipnet, err := net.ParseCIDR(fields[0])
+			if err != nil {
+				if lineNum == 1 {
+					// Header line.
+					continue
+				}
+				return nil, fmt.Errorf("line %d: invalid CIDR block %q", lineNum, fields[0])
+			}
+			cidrs = []*net.IPNet{ipnet}
+			country = fields[1]
+		default:
+			return nil, fmt.Errorf("line %d: expected network and country fields", lineNum)
+		}
+
+		country = strings.ToUpper(country)
+		db[country] = append(db[country], cidrs...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading input: %v", err)
+	}
+	return db, nil
+}
+
+// isDecimal reports whether s is a non-empty string of ASCII digits.
+func isDecimal(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseDecimalRange parses a range of addresses written as decimal integers.
+// The range is IPv4 when its last address fits in 32 bits and IPv6
+// otherwise. The IPv4-mapped ranges of the IPv6 IP2Location files thereby
+// come out as IPv4 blocks.
+func parseDecimalRange(firstField, lastField string) (net.IP, net.IP, error) {
+	first, ok := new(big.Int).SetString(firstField, 10)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid address %q", firstField)
+	}
+	last, ok := new(big.Int).SetString(lastField, 10)
+	if !ok || !isDecimal(lastField) {
+		return nil, nil, fmt.Errorf("invalid address %q", lastField)
+	}
+	size := net.IPv6len
+	if last.BitLen() <= 32 {
+		size = net.IPv4len
+	}
+	firstIP, lastIP := intToIP(first, size), intToIP(last, size)
+	if firstIP == nil || lastIP == nil {
+		return nil, nil, fmt.Errorf("address out of range: %s-%s", firstField, lastField)
+	}
+	return firstIP, lastIP, nil
+}
+
+// countryCIDRs returns the aggregated blocks assigned to any of countries.
+func (db geoDB) countryCIDRs(countries []string) []*net.IPNet {
+	var cidrs []*net.IPNet
+	for _, country := range countries {
+		cidrs = append(cidrs, db[strings.ToUpper(country)]...)
+	}
+	return collapseCIDRs(cidrs)
+}
+
+// readGeoDB reads a country database from filename.
+func readGeoDB(filename string) (geoDB, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	return parseGeoCSV(file)
+}
+
+// parseCountryList splits a comma-separated list of country codes.
+func parseCountryList(list string) ([]string, error) {
+	var countries []string
+	for _, country := range strings.Split(list, ",") {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if country == "" {
+			continue
+		}
+		if len(country) != 2 {
+			return nil, fmt.Errorf("invalid country code %q", country)
+		}
+		countries = append(countries, country)
+	}
+	if len(countries) == 0 {
+		return nil, fmt.Errorf("no country codes given")
+	}
+	sort.Strings(countries)
+	return countries, nil
+}
+
+// runGeo implements the "geo" command.
+func runGeo(args []string) error {
+	flags := flag.NewFlagSet("geo", flag.ContinueOnError)
+	dbFile := flags.String("db", "", "country database CSV")
+	countryList := flags.String("country", "", "comma-separated country codes")
+	keep := flags.String("keep", "", "keep only the parts of this file inside the countries")
+	drop := flags.String("drop", "", "remove the parts of this file inside the countries")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *dbFile == "" || *countryList == "" || flags.NArg() != 0 {
+		return fmt.Errorf("usage: geo -db <file> -country <codes> [-keep <file> | -drop <file>]")
+	}
+	if *keep != "" && *drop != "" {
+		return fmt.Errorf("-keep and -drop are mutually exclusive")
+	}
+
+	countries, err := parseCountryList(*countryList)
+	if err != nil {
+		return err
+	}
+	db, err := readGeoDB(*dbFile)
+	if err != nil {
+		return err
+	}
+	geo := db.countryCIDRs(countries)
+
+	result := geo
+	switch {
+	case *keep != "":
+		list, err := readCIDRFile(*keep)
+		if err != nil {
+			return err
+		}
+		result = intersectCIDRs(collapseCIDRs(list), geo)
+	case *drop != "":
+		list, err := readCIDRFile(*drop)
+		if err != nil {
+			return err
+		}
+		result = subtractCIDRs(collapseCIDRs(list), geo)
+	}
+
+	for _, cidr := range collapseCIDRs(result) {
+		fmt.Println(cidr)
+	}
+	return nil
+}
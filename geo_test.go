@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGeoCSV(t *testing.T) {
+	input := `network,country_iso_code
+10.0.0.0/16,de
+10.1.0.0/16,DE
+"192.168.0.0","192.168.0.255","FR"
+2001:db8::/32,DE
+`
+	db, err := parseGeoCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseGeoCSV() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		countries []string
+		want      string
+	}{
+		{name: "Single country", countries: []string{"DE"}, want: "10.0.0.0/15,2001:db8::/32"},
+		{name: "Lower-case code", countries: []string{"fr"}, want: "192.168.0.0/24"},
+		{name: "Several countries", countries: []string{"DE", "FR"}, want: "10.0.0.0/15,2001:db8::/32,192.168.0.0/24"},
+		{name: "Unknown country", countries: []string{"XX"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := joinCIDRs(db.countryCIDRs(tt.countries)); got != tt.want {
+				t.Errorf("countryCIDRs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGeoCSVDecimal(t *testing.T) {
+	input := `"ip_from","ip_to","country_code","country_name"
+"167772160","167837695","DE","Germany"
+"3232235520","3232235775","FR","France"
+"281470849581056","281470849646591","DE","Germany"
+"42540766411282592856903984951653826560","42540766490510755371168322545197776895","DE","Germany"
+`
+	db, err := parseGeoCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseGeoCSV() error = %v", err)
+	}
+	tests := []struct {
+		country string
+		want    string
+	}{
+		{"DE", "10.0.0.0/15,2001:db8::/32"},
+		{"FR", "192.168.0.0/24"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.country, func(t *testing.T) {
+			if got := joinCIDRs(db.countryCIDRs([]string{tt.country})); got != tt.want {
+				t.Errorf("countryCIDRs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGeoCSVErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "Invalid CIDR", input: "10.0.0.0/8,DE\nbogus,FR\n"},
+		{name: "Reversed range", input: "10.0.0.9,10.0.0.1,DE\n"},
+		{name: "Missing country", input: "10.0.0.0/8\n"},
+		{name: "Reversed decimal range", input: "167772169,167772161,DE\n"},
+		{name: "Decimal out of range", input: "0,340282366920938463463374607431768211456,DE\n"},
+		{name: "Invalid decimal end", input: "167772160,10.0.0.255,DE\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseGeoCSV(strings.NewReader(tt.input)); err == nil {
+				t.Errorf("parseGeoCSV() expected an error")
+			}
+		})
+	}
+}
+
+func TestParseCountryList(t *testing.T) {
+	got, err := parseCountryList("fr, de,")
+	if err != nil || strings.Join(got, ",") != "DE,FR" {
+		t.Errorf("parseCountryList() = %v, %v", got, err)
+	}
+	if _, err := parseCountryList("FRA"); err == nil {
+		t.Errorf("parseCountryList() accepted a three-letter code")
+	}
+	if _, err := parseCountryList(" , "); err == nil {
+		t.Errorf("parseCountryList() accepted an empty list")
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// snapshotDateRegex finds the date in the name of an export, e.g.
+// blocklist-2024-05-01.json or allow.20240501.txt.
+var snapshotDateRegex = regexp.MustCompile(`(\d{4})-?(\d{2})-?(\d{2})`)
+
+// historyPoint describes one export of a history and how it differs from
+// the previous one. Added and Removed count the addresses gained and lost,
+// so their sum is the churn of the run.
+type historyPoint struct {
+	Date      time.Time `json:"date"`
+	File      string    `json:"file"`
+	Blocks    int       `json:"blocks"`
+	Addresses *big.Int  `json:"addresses"`
+	Change    *big.Int  `json:"change"`
+	Added     *big.Int  `json:"added"`
+	Removed   *big.Int  `json:"removed"`
+	// NewSupernets are the blocks that appeared by widening earlier
+	// blocks, i.e. that strictly contain a block of the previous export.
+	NewSupernets []string `json:"newSupernets"`
+}
+
+// datedSnapshots returns the exports in dir whose names carry a date,
+// oldest first. Files without a date are returned separately.
+func datedSnapshots(dir string) (snapshots []snapshot, undated []string, err error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading directory: %v", err)
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		match := snapshotDateRegex.FindStringSubmatch(file.Name())
+		if match == nil {
+			undated = append(undated, path)
+			continue
+		}
+		date, err := time.Parse("20060102", match[1]+match[2]+match[3])
+		if err != nil {
+			undated = append(undated, path)
+			continue
+		}
+		snapshots = append(snapshots, snapshot{Time: date, File: path})
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+	return snapshots, undated, nil
+}
+
+// totalAddresses returns the number of addresses covered by cidrs, which
+// must not overlap.
+func totalAddresses(cidrs []*net.IPNet) *big.Int {
+	total := new(big.Int)
+	for _, cidr := range cidrs {
+		total.Add(total, cidrSize(cidr))
+	}
+	return total
+}
+
+// newSupernets returns the blocks of cidrs absent from previous that
+// strictly contain one of its blocks.
+func newSupernets(previous, cidrs []*net.IPNet) []string {
+	existing := map[string]bool{}
+	for _, cidr := range previous {
+		existing[cidr.String()] = true
+	}
+	supernets := []string{}
+	for _, cidr := range cidrs {
+		if existing[cidr.String()] {
+			continue
+		}
+		for _, old := range previous {
+			if cidrContains(cidr, old) {
+				supernets = append(supernets, cidr.String())
+				break
+			}
+		}
+	}
+	return supernets
+}
+
+// computeHistory reads the snapshots in order and describes each against
+// the one before it. The first one is compared against an empty set.
+func computeHistory(snapshots []snapshot) ([]historyPoint, error) {
+	var points []historyPoint
+	var previous []*net.IPNet
+	for _, s := range snapshots {
+		cidrs, err := readCIDRFile(s.File)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = collapseCIDRs(cidrs)
+		added, removed := compareCIDRSets(cidrs, previous)
+		point := historyPoint{
+			Date:         s.Time,
+			File:         s.File,
+			Blocks:       len(cidrs),
+			Addresses:    totalAddresses(cidrs),
+			Added:        totalAddresses(added),
+			Removed:      totalAddresses(removed),
+			NewSupernets: newSupernets(previous, cidrs),
+		}
+		point.Change = new(big.Int).Sub(point.Added, point.Removed)
+		points = append(points, point)
+		previous = cidrs
+	}
+	return points, nil
+}
+
+// renderHistory writes points as a table followed by the overall trend.
+func renderHistory(w io.Writer, points []historyPoint) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATE\tBLOCKS\tADDRESSES\tCHANGE\tADDED\tREMOVED\tNEW SUPERNETS")
+	for _, p := range points {
+		supernets := "-"
+		if len(p.NewSupernets) > 0 {
+			supernets = strings.Join(p.NewSupernets, ",")
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", p.Date.Format("2006-01-02"), p.Blocks, p.Addresses, signed(p.Change), p.Added, p.Removed, supernets)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(points) < 2 {
+		return nil
+	}
+
+	first, last := points[0], points[len(points)-1]
+	growth := new(big.Int).Sub(last.Addresses, first.Addresses)
+	churn := new(big.Int)
+	for _, p := range points[1:] {
+		churn.Add(churn, p.Added)
+		churn.Add(churn, p.Removed)
+	}
+	trend := signed(growth) + " addresses"
+	if first.Addresses.Sign() > 0 {
+		ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(growth), new(big.Float).SetInt(first.Addresses)).Float64()
+		trend += fmt.Sprintf(" (%+.1f%%)", ratio*100)
+	}
+	runs := big.NewInt(int64(len(points) - 1))
+	_, err := fmt.Fprintf(w, "\nFrom %s to %s: %s, average churn %s addresses per run\n",
+		first.Date.Format("2006-01-02"), last.Date.Format("2006-01-02"), trend, new(big.Int).Quo(churn, runs))
+	return err
+}
+
+// signed formats n with a leading sign.
+func signed(n *big.Int) string {
+	if n.Sign() > 0 {
+		return "+" + n.String()
+	}
+	return n.String()
+}
+
+// runHistory implements the "history" command.
+func runHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	format := fs.String("output-format", "text", "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: history [--output-format=text|json] <directory>")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown output format: %s", *format)
+	}
+	snapshots, undated, err := datedSnapshots(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, file := range undated {
+		fmt.Fprintf(os.Stderr, "Warning: skipping %s, which has no date in its name\n", file)
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no dated exports in %s", fs.Arg(0))
+	}
+	points, err := computeHistory(snapshots)
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(points)
+	}
+	return renderHistory(os.Stdout, points)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeHistory(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDatedSnapshots(t *testing.T) {
+	dir := writeHistory(t, map[string]string{
+		"blocklist-2024-03-01.txt": "",
+		"blocklist.20240101.txt":   "",
+		"2024-02-01.json":          "[]",
+		"README":                   "",
+		"blocklist-2024-13-01.txt": "",
+	})
+	snapshots, undated, err := datedSnapshots(dir)
+	if err != nil {
+		t.Fatalf("datedSnapshots() error = %v", err)
+	}
+	var got []string
+	for _, s := range snapshots {
+		got = append(got, s.Time.Format("2006-01-02")+"="+filepath.Base(s.File))
+	}
+	want := "2024-01-01=blocklist.20240101.txt,2024-02-01=2024-02-01.json,2024-03-01=blocklist-2024-03-01.txt"
+	if strings.Join(got, ",") != want {
+		t.Errorf("datedSnapshots() = %q, want %q", strings.Join(got, ","), want)
+	}
+	if len(undated) != 2 {
+		t.Errorf("undated = %v, want README and the invalid date", undated)
+	}
+}
+
+func TestComputeHistory(t *testing.T) {
+	dir := writeHistory(t, map[string]string{
+		"2024-01-01.txt": "10.0.0.0/25\n192.168.0.0/24\n",
+		"2024-02-01.txt": "10.0.0.0/24\n192.168.0.0/24\n",
+		"2024-03-01.txt": "10.0.0.0/24\n172.16.0.0/30\n",
+	})
+	snapshots, _, _ := datedSnapshots(dir)
+	points, err := computeHistory(snapshots)
+	if err != nil {
+		t.Fatalf("computeHistory() error = %v", err)
+	}
+
+	tests := []struct {
+		addresses, change, added, removed string
+		supernets                         string
+	}{
+		{addresses: "384", change: "384", added: "384", removed: "0"},
+		{addresses: "512", change: "128", added: "128", removed: "0", supernets: "10.0.0.0/24"},
+		{addresses: "260", change: "-252", added: "4", removed: "256"},
+	}
+	for i, tt := range tests {
+		p := points[i]
+		if p.Addresses.String() != tt.addresses || p.Change.String() != tt.change || p.Added.String() != tt.added || p.Removed.String() != tt.removed {
+			t.Errorf("point %d = %s %s +%s -%s, want %s %s +%s -%s", i, p.Addresses, p.Change, p.Added, p.Removed, tt.addresses, tt.change, tt.added, tt.removed)
+		}
+		if got := strings.Join(p.NewSupernets, ","); got != tt.supernets {
+			t.Errorf("point %d new supernets = %q, want %q", i, got, tt.supernets)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := renderHistory(&buf, points); err != nil {
+		t.Fatalf("renderHistory() error = %v", err)
+	}
+	want := "From 2024-01-01 to 2024-03-01: -124 addresses (-32.3%), average churn 194 addresses per run\n"
+	if !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("renderHistory() =\n%s\nwant it to end with\n%s", buf.String(), want)
+	}
+}
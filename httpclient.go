@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// httpConfig configures the HTTP client shared by every integration that
+// talks to a network service, such as the Consul and etcd stores and the
+// EC2 API. Proxies are taken from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables.
+type httpConfig struct {
+	// Timeout bounds every attempt of a request, up to reading the whole
+	// response. Zero means no timeout.
+	Timeout time.Duration
+	// Retries is the number of times a request failing with a network
+	// error or a 429 or 5xx response is retried.
+	Retries int
+	// Backoff is the delay before the first retry. It doubles with each
+	// further retry; a Retry-After header overrides it.
+	Backoff   time.Duration
+	UserAgent string
+}
+
+// defaultHTTPConfig is used when a command does not override it.
+var defaultHTTPConfig = httpConfig{
+	Timeout:   30 * time.Second,
+	Retries:   3,
+	Backoff:   500 * time.Millisecond,
+	UserAgent: "cidr-converter",
+}
+
+// addHTTPFlags registers the flags configuring the shared HTTP client on
+// fs, with the defaults of defaultHTTPConfig.
+func addHTTPFlags(fs *flag.FlagSet) *httpConfig {
+	config := defaultHTTPConfig
+	fs.DurationVar(&config.Timeout, "http-timeout", config.Timeout, "timeout of every HTTP request attempt, 0 for none")
+	fs.IntVar(&config.Retries, "http-retries", config.Retries, "number of retries of failed HTTP requests")
+	fs.DurationVar(&config.Backoff, "http-backoff", config.Backoff, "delay before the first retry, doubled for each further one")
+	fs.StringVar(&config.UserAgent, "user-agent", config.UserAgent, "User-Agent header of HTTP requests")
+	return &config
+}
+
+// client returns an HTTP client implementing the configuration.
+func (c httpConfig) client() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	return &http.Client{Transport: &retryTransport{config: c, next: transport}}
+}
+
+// noTimeoutKey marks request contexts exempt from the attempt timeout.
+type noTimeoutKey struct{}
+
+// withoutHTTPTimeout returns a context whose requests are not bound by the
+// attempt timeout, for long polls and streams like store watches.
+func withoutHTTPTimeout(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noTimeoutKey{}, true)
+}
+
+// retryTransport adds the timeouts, retries and User-Agent of an httpConfig
+// to another transport.
+type retryTransport struct {
+	config httpConfig
+	next   http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := t.config.Backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(req)
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable || attempt >= t.config.Retries || req.Context().Err() != nil ||
+			(req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+
+		delay := backoff
+		if resp != nil {
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+				delay = time.Duration(seconds) * time.Second
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// attempt sends req once, applying the timeout and User-Agent.
+func (t *retryTransport) attempt(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	cancel := context.CancelFunc(func() {})
+	if t.config.Timeout > 0 && ctx.Value(noTimeoutKey{}) == nil {
+		ctx, cancel = context.WithTimeout(ctx, t.config.Timeout)
+	}
+	attempt := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		attempt.Body = body
+	}
+	if attempt.Header.Get("User-Agent") == "" && t.config.UserAgent != "" {
+		attempt.Header.Set("User-Agent", t.config.UserAgent)
+	}
+
+	resp, err := t.next.RoundTrip(attempt)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout also covers reading the body, so it is only released
+	// when the body is closed.
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases a request context when the response body closes.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPClientRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int32
+		status   int
+		retries  int
+		want     int
+		attempts int32
+	}{
+		{name: "Succeeds first time", retries: 3, want: http.StatusOK, attempts: 1},
+		{name: "Retries server errors", failures: 2, status: http.StatusServiceUnavailable, retries: 3, want: http.StatusOK, attempts: 3},
+		{name: "Retries rate limiting", failures: 1, status: http.StatusTooManyRequests, retries: 3, want: http.StatusOK, attempts: 2},
+		{name: "Gives up", failures: 5, status: http.StatusBadGateway, retries: 2, want: http.StatusBadGateway, attempts: 3},
+		{name: "Does not retry client errors", failures: 5, status: http.StatusNotFound, retries: 3, want: http.StatusNotFound, attempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != "payload" || r.Header.Get("User-Agent") != "test-agent" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if atomic.AddInt32(&attempts, 1) <= tt.failures {
+					w.WriteHeader(tt.status)
+				}
+			}))
+			defer ts.Close()
+
+			config := httpConfig{Retries: tt.retries, Backoff: time.Millisecond, UserAgent: "test-agent"}
+			resp, err := config.client().Post(ts.URL, "text/plain", strings.NewReader("payload"))
+			if err != nil {
+				t.Fatalf("Post() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestHTTPClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	client := httpConfig{Timeout: 20 * time.Millisecond}.client()
+	if _, err := client.Get(ts.URL); err == nil {
+		t.Errorf("Get() expected a timeout error")
+	}
+
+	// Long polls are exempt from the attempt timeout.
+	ctx, cancel := context.WithTimeout(withoutHTTPTimeout(context.Background()), 200*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		release <- struct{}{}
+	}()
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+}
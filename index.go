@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// cidrIndex is a set of blocks indexed for queries. Blocks of the two
+// address families never match each other.
+type cidrIndex interface {
+	// Containing returns the blocks that contain ip.
+	Containing(ip net.IP) []*net.IPNet
+	// Overlapping returns the blocks that share addresses with cidr.
+	Overlapping(cidr *net.IPNet) []*net.IPNet
+}
+
+// cidrIndexes maps the names accepted by -index flags to constructors.
+var cidrIndexes = map[string]func(cidrs []*net.IPNet) cidrIndex{
+	"trie":     newTrieIndex,
+	"interval": newIntervalIndex,
+}
+
+// newCIDRIndex builds the index named kind over cidrs.
+func newCIDRIndex(kind string, cidrs []*net.IPNet) (cidrIndex, error) {
+	build, ok := cidrIndexes[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown index: %s", kind)
+	}
+	return build(cidrs), nil
+}
+
+// familyIP returns ip in 4-byte form for IPv4 and 16-byte form otherwise.
+func familyIP(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip.To16()
+}
+
+// familyCIDR returns an IPv4-mapped IPv6 block, such as
+// ::ffff:10.0.0.0/104, as the IPv4 block it stands for, so that indexes
+// file it and match it with the IPv4 blocks. Other blocks are returned as
+// they are.
+func familyCIDR(cidr *net.IPNet) *net.IPNet {
+	ones, bits := cidr.Mask.Size()
+	if bits != 8*net.IPv6len || ones < 96 || cidr.IP.To4() == nil {
+		return cidr
+	}
+	return &net.IPNet{IP: cidr.IP.To4(), Mask: net.CIDRMask(ones-96, 8*net.IPv4len)}
+}
+
+// trieNode is a node of a binary trie keyed by address bits.
+type trieNode struct {
+	children [2]*trieNode
+	cidrs    []*net.IPNet
+}
+
+// trieIndex stores every block at the node reached by its prefix bits,
+// which makes longest-prefix and containment queries proportional to the
+// address length.
+type trieIndex struct {
+	roots map[int]*trieNode
+}
+
+func newTrieIndex(cidrs []*net.IPNet) cidrIndex {
+	t := &trieIndex{roots: map[int]*trieNode{}}
+	for _, cidr := range cidrs {
+		key := familyCIDR(cidr)
+		ones, bits := key.Mask.Size()
+		node := t.roots[bits]
+		if node == nil {
+			node = &trieNode{}
+			t.roots[bits] = node
+		}
+		ip := familyIP(key.IP)
+		for i := 0; i < ones; i++ {
+			bit := ip[i/8] >> uint(7-i%8) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &trieNode{}
+			}
+			node = node.children[bit]
+		}
+		node.cidrs = append(node.cidrs, cidr)
+	}
+	return t
+}
+
+func (t *trieIndex) Containing(ip net.IP) []*net.IPNet {
+	ip = familyIP(ip)
+	if ip == nil {
+		return nil
+	}
+	return t.walk(ip, len(ip)*8, nil)
+}
+
+func (t *trieIndex) Overlapping(cidr *net.IPNet) []*net.IPNet {
+	cidr = familyCIDR(cidr)
+	ones, _ := cidr.Mask.Size()
+	var inside []*net.IPNet
+	var collect func(node *trieNode)
+	collect = func(node *trieNode) {
+		for _, child := range node.children {
+			if child != nil {
+				inside = append(inside, child.cidrs...)
+				collect(child)
+			}
+		}
+	}
+	// The blocks along the path contain cidr; every block below the node
+	// of cidr lies inside it.
+	found := t.walk(familyIP(cidr.IP), ones, collect)
+	return append(found, inside...)
+}
+
+// walk follows the first depth bits of ip from the root of its family and
+// returns the blocks stored along the way. When the full depth is reached,
+// below is called with the last node.
+func (t *trieIndex) walk(ip net.IP, depth int, below func(node *trieNode)) []*net.IPNet {
+	node := t.roots[len(ip)*8]
+	var found []*net.IPNet
+	for i := 0; node != nil; i++ {
+		found = append(found, node.cidrs...)
+		if i == depth {
+			if below != nil {
+				below(node)
+			}
+			break
+		}
+		node = node.children[ip[i/8]>>uint(7-i%8)&1]
+	}
+	return found
+}
+
+// interval is a block as its first and last address.
+type interval struct {
+	first, last net.IP
+	cidr        *net.IPNet
+}
+
+// intervalNode is a node of a balanced interval tree. maxLast is the
+// highest last address in the node's subtree.
+type intervalNode struct {
+	interval
+	maxLast     net.IP
+	left, right *intervalNode
+}
+
+// intervalIndex is an augmented interval tree per address family, built
+// balanced from the blocks sorted by first address. Overlap queries visit
+// only the subtrees that can hold a match.
+type intervalIndex struct {
+	roots map[int]*intervalNode
+}
+
+func newIntervalIndex(cidrs []*net.IPNet) cidrIndex {
+	families := map[int][]interval{}
+	for _, cidr := range cidrs {
+		key := familyCIDR(cidr)
+		_, bits := key.Mask.Size()
+		first, last := cidrBounds(key)
+		families[bits] = append(families[bits], interval{first: first, last: last, cidr: cidr})
+	}
+
+	t := &intervalIndex{roots: map[int]*intervalNode{}}
+	for bits, intervals := range families {
+		sort.Slice(intervals, func(i, j int) bool {
+			return bytes.Compare(intervals[i].first, intervals[j].first) < 0
+		})
+		t.roots[bits] = buildIntervalTree(intervals)
+	}
+	return t
+}
+
+// buildIntervalTree builds a balanced tree from intervals sorted by first
+// address.
+func buildIntervalTree(intervals []interval) *intervalNode {
+	if len(intervals) == 0 {
+		return nil
+	}
+	mid := len(intervals) / 2
+	node := &intervalNode{interval: intervals[mid], maxLast: intervals[mid].last}
+	node.left = buildIntervalTree(intervals[:mid])
+	node.right = buildIntervalTree(intervals[mid+1:])
+	for _, child := range []*intervalNode{node.left, node.right} {
+		if child != nil && bytes.Compare(child.maxLast, node.maxLast) > 0 {
+			node.maxLast = child.maxLast
+		}
+	}
+	return node
+}
+
+// cidrBounds returns the first and last address of cidr.
+func cidrBounds(cidr *net.IPNet) (net.IP, net.IP) {
+	first := familyIP(cidr.IP.Mask(cidr.Mask))
+	last := make(net.IP, len(first))
+	mask := cidr.Mask
+	if len(mask) != len(first) {
+		mask = mask[len(mask)-len(first):]
+	}
+	for i := range first {
+		last[i] = first[i] | ^mask[i]
+	}
+	return first, last
+}
+
+func (t *intervalIndex) Containing(ip net.IP) []*net.IPNet {
+	ip = familyIP(ip)
+	if ip == nil {
+		return nil
+	}
+	return t.query(ip, ip)
+}
+
+func (t *intervalIndex) Overlapping(cidr *net.IPNet) []*net.IPNet {
+	first, last := cidrBounds(familyCIDR(cidr))
+	return t.query(first, last)
+}
+
+// query returns the blocks overlapping the range from first to last.
+func (t *intervalIndex) query(first, last net.IP) []*net.IPNet {
+	var found []*net.IPNet
+	var visit func(node *intervalNode)
+	visit = func(node *intervalNode) {
+		if node == nil || bytes.Compare(node.maxLast, first) < 0 {
+			return
+		}
+		visit(node.left)
+		if bytes.Compare(node.first, last) > 0 {
+			// This node and everything to its right start after the range.
+			return
+		}
+		if bytes.Compare(node.last, first) >= 0 {
+			found = append(found, node.cidr)
+		}
+		visit(node.right)
+	}
+	visit(t.roots[len(first)*8])
+	return found
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// sortedCIDRStrings returns cidrs as sorted strings, so results of
+// different indexes can be compared.
+func sortedCIDRStrings(cidrs []*net.IPNet) string {
+	var s []string
+	for _, cidr := range cidrs {
+		s = append(s, cidr.String())
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func TestCIDRIndex(t *testing.T) {
+	cidrs, _ := parseCIDRList(strings.NewReader("10.0.0.0/8\n10.1.0.0/16\n10.1.2.0/24\n10.2.0.0/16\n192.168.0.0/16\n2001:db8::/32\n0.0.0.0/0\n"))
+
+	containing := []struct {
+		ip   string
+		want string
+	}{
+		{ip: "10.1.2.3", want: "0.0.0.0/0,10.0.0.0/8,10.1.0.0/16,10.1.2.0/24"},
+		{ip: "10.3.0.1", want: "0.0.0.0/0,10.0.0.0/8"},
+		{ip: "2001:db8::1", want: "2001:db8::/32"},
+		{ip: "2001:db9::1", want: ""},
+	}
+	overlapping := []struct {
+		cidr string
+		want string
+	}{
+		{cidr: "10.1.0.0/15", want: "0.0.0.0/0,10.0.0.0/8,10.1.0.0/16,10.1.2.0/24"},
+		{cidr: "10.1.2.128/25", want: "0.0.0.0/0,10.0.0.0/8,10.1.0.0/16,10.1.2.0/24"},
+		{cidr: "10.0.0.0/8", want: "0.0.0.0/0,10.0.0.0/8,10.1.0.0/16,10.1.2.0/24,10.2.0.0/16"},
+		{cidr: "2001::/16", want: "2001:db8::/32"},
+		{cidr: "fe80::/10", want: ""},
+	}
+
+	for name := range cidrIndexes {
+		index, err := newCIDRIndex(name, cidrs)
+		if err != nil {
+			t.Fatalf("newCIDRIndex() error = %v", err)
+		}
+		t.Run(name, func(t *testing.T) {
+			for _, tt := range containing {
+				if got := sortedCIDRStrings(index.Containing(net.ParseIP(tt.ip))); got != tt.want {
+					t.Errorf("Containing(%s) = %q, want %q", tt.ip, got, tt.want)
+				}
+			}
+			for _, tt := range overlapping {
+				cidr, _ := parseCIDR(tt.cidr)
+				if got := sortedCIDRStrings(index.Overlapping(cidr)); got != tt.want {
+					t.Errorf("Overlapping(%s) = %q, want %q", tt.cidr, got, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := newCIDRIndex("btree", cidrs); err == nil {
+		t.Errorf("newCIDRIndex() accepted an unknown index")
+	}
+}
+
+func TestCIDRIndexMappedBlocks(t *testing.T) {
+	_, mapped, err := net.ParseCIDR("::ffff:10.0.0.0/104")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, v6, _ := net.ParseCIDR("2001:db8::/32")
+	cidrs := []*net.IPNet{mapped, v6}
+	for name := range cidrIndexes {
+		index, _ := newCIDRIndex(name, cidrs)
+		t.Run(name, func(t *testing.T) {
+			if got := index.Containing(net.ParseIP("10.1.2.3")); len(got) != 1 || got[0] != mapped {
+				t.Errorf("Containing(10.1.2.3) = %v, want the mapped block", got)
+			}
+			if got := index.Containing(net.ParseIP("11.0.0.1")); len(got) != 0 {
+				t.Errorf("Containing(11.0.0.1) = %v, want none", got)
+			}
+			for _, query := range []string{"10.1.0.0/16", "::ffff:10.0.0.0/120", "0.0.0.0/0"} {
+				_, q, _ := net.ParseCIDR(query)
+				if got := index.Overlapping(q); len(got) != 1 || got[0] != mapped {
+					t.Errorf("Overlapping(%s) = %v, want the mapped block", query, got)
+				}
+			}
+			_, q, _ := net.ParseCIDR("2001:db8:1::/48")
+			if got := index.Overlapping(q); len(got) != 1 || got[0] != v6 {
+				t.Errorf("Overlapping(2001:db8:1::/48) = %v, want %s", got, v6)
+			}
+		})
+	}
+}
+
+func TestCIDRIndexesAgree(t *testing.T) {
+	cidrs := benchmarkCIDRs(2000)
+	queries := benchmarkCIDRs(500)
+	trie, interval := newTrieIndex(cidrs), newIntervalIndex(cidrs)
+	for _, q := range queries {
+		var matches []*net.IPNet
+		for _, c := range cidrs {
+			if cidrsOverlap(c, q) {
+				matches = append(matches, c)
+			}
+		}
+		want := sortedCIDRStrings(matches)
+		if got := sortedCIDRStrings(trie.Overlapping(q)); got != want {
+			t.Fatalf("trie Overlapping(%s) = %q, want %q", q, got, want)
+		}
+		if got := sortedCIDRStrings(interval.Overlapping(q)); got != want {
+			t.Fatalf("interval Overlapping(%s) = %q, want %q", q, got, want)
+		}
+	}
+}
+
+func BenchmarkCIDRIndexOverlapping(b *testing.B) {
+	for _, name := range []string{"interval", "trie"} {
+		newIndex := cidrIndexes[name]
+		for _, n := range benchmarkSizes {
+			index := newIndex(benchmarkCIDRs(n))
+			queries := benchmarkCIDRs(1000)
+			b.Run(fmt.Sprintf("%s/%d", name, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					index.Overlapping(queries[i%len(queries)])
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkCIDRIndexContaining(b *testing.B) {
+	for _, name := range []string{"interval", "trie"} {
+		newIndex := cidrIndexes[name]
+		for _, n := range benchmarkSizes {
+			index := newIndex(benchmarkCIDRs(n))
+			ip := net.ParseIP("10.20.30.40")
+			b.Run(fmt.Sprintf("%s/%d", name, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					index.Containing(ip)
+				}
+			})
+		}
+	}
+}
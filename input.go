@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// parseCIDRJSON reads blocks from a JSON document. It accepts the versioned
+// object written by saveToJSON, the plain string array written in compat
+// mode, and arrays mixing strings with objects carrying a "cidr" field and
+// optionally a "name", "tags" and a "draining" removal date.
+func parseCIDRJSON(r io.Reader) ([]*net.IPNet, error) {
+	return entryCIDRs(scanCIDRJSON(r))
+}
+
+// scanCIDRJSON is parseCIDRJSON keeping the position of every block in the
+// document's list.
+func scanCIDRJSON(r io.Reader) ([]inputEntry, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("error decoding JSON: %v", err)
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		var document struct {
+			CIDRs []json.RawMessage `json:"cidrs"`
+		}
+		if err := json.Unmarshal(raw, &document); err != nil || document.CIDRs == nil {
+			return nil, fmt.Errorf("error decoding JSON: expected an array or an object with a \"cidrs\" field")
+		}
+		items = document.CIDRs
+	}
+
+	var entries []inputEntry
+	for i, item := range items {
+		var object cidrObject
+		if err := json.Unmarshal(item, &object.CIDR); err != nil {
+			if err := json.Unmarshal(item, &object); err != nil || object.CIDR == "" {
+				return nil, fmt.Errorf("entry %d: expected a string or an object with a \"cidr\" field", i+1)
+			}
+		}
+		objectEntries, err := object.entries(i + 1)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %v", i+1, err)
+		}
+		entries = append(entries, objectEntries...)
+	}
+	return entries, nil
+}
+
+// cidrObject is an entry of the object form of JSON and YAML input: a block
+// with its optional metadata.
+type cidrObject struct {
+	CIDR     string   `json:"cidr"`
+	Name     string   `json:"name"`
+	Tags     []string `json:"tags"`
+	Draining string   `json:"draining"`
+}
+
+// entries expands the block of o, which may be a wildcard, into entries at
+// line carrying its metadata.
+func (o cidrObject) entries(line int) ([]inputEntry, error) {
+	ipnets, err := parseEntry(o.CIDR)
+	if err != nil {
+		return nil, err
+	}
+	var draining time.Time
+	if o.Draining != "" {
+		if draining, err = parseDrainingDate(o.Draining); err != nil {
+			return nil, err
+		}
+	}
+	var entries []inputEntry
+	for _, ipnet := range ipnets {
+		entries = append(entries, inputEntry{CIDR: ipnet, Line: line, Name: o.Name, Tags: o.Tags, Draining: draining})
+	}
+	return entries, nil
+}
+
+// yamlKeyRegex matches a "key: value" mapping line. Keys start with a
+// letter, so IPv6 blocks such as 2001:db8::/32 are not taken for keys.
+var yamlKeyRegex = regexp.MustCompile(`^([A-Za-z_][\w-]*):(?:\s+(.*))?$`)
+
+// yamlLine is a line of a YAML document holding content.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// isYAMLListItem reports whether text starts a block sequence item.
+func isYAMLListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// yamlScalar returns a scalar value without its quotes.
+func yamlScalar(value string) string {
+	return strings.Trim(strings.TrimSpace(value), `"'`)
+}
+
+// parseCIDRYAML reads blocks from the YAML equivalents of the documents
+// accepted by parseCIDRJSON. Only the block-style subset of YAML needed for
+// those documents is supported, with flow sequences for tags.
+func parseCIDRYAML(r io.Reader) ([]*net.IPNet, error) {
+	return entryCIDRs(scanCIDRYAML(r))
+}
+
+// scanCIDRYAML is parseCIDRYAML keeping the line of every block. Every item
+// of the top-level list, or of the list under a top-level "cidrs" key, is a
+// block or a mapping read like the objects of JSON input.
+func scanCIDRYAML(r io.Reader) ([]inputEntry, error) {
+	var lines []yamlLine
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		text := strings.TrimSpace(line)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		lines = append(lines, yamlLine{num: lineNum, indent: len(line) - len(strings.TrimLeft(line, " ")), text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading input: %v", err)
+	}
+
+	var entries []inputEntry
+	for _, item := range yamlEntryItems(lines) {
+		object, line, err := parseYAMLObject(item)
+		if err != nil {
+			return nil, err
+		}
+		objectEntries, err := object.entries(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		entries = append(entries, objectEntries...)
+	}
+	return entries, nil
+}
+
+// yamlEntryItems splits the list of entries of a document into its items,
+// each starting with its "-" line and holding the lines nested in it.
+func yamlEntryItems(lines []yamlLine) [][]yamlLine {
+	start := 0
+	if len(lines) > 0 && !isYAMLListItem(lines[0].text) {
+		start = -1
+		for i, line := range lines {
+			if line.indent == 0 && line.text == "cidrs:" {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return nil
+		}
+	}
+	if start >= len(lines) || !isYAMLListItem(lines[start].text) {
+		return nil
+	}
+	indent := lines[start].indent
+	var items [][]yamlLine
+	for _, line := range lines[start:] {
+		if line.indent < indent || (line.indent == indent && !isYAMLListItem(line.text)) {
+			break
+		}
+		if line.indent == indent {
+			items = append(items, []yamlLine{line})
+			continue
+		}
+		items[len(items)-1] = append(items[len(items)-1], line)
+	}
+	return items
+}
+
+// parseYAMLObject reads a list item as a block or a mapping with a "cidr"
+// key, returning the line to report it at.
+func parseYAMLObject(item []yamlLine) (cidrObject, int, error) {
+	first := item[0]
+	rest := strings.TrimPrefix(first.text, "-")
+	content := strings.TrimLeft(rest, " ")
+	if content != "" && !yamlKeyRegex.MatchString(content) {
+		return cidrObject{CIDR: yamlScalar(content)}, first.num, nil
+	}
+
+	// The keys of the mapping are aligned with the first one, on the item's
+	// line or the next; deeper lines, and list items aligned with the keys,
+	// belong to the key before them.
+	keys := []yamlLine{}
+	keyIndent := -1
+	if content != "" {
+		keyIndent = first.indent + len(first.text) - len(content)
+		keys = append(keys, yamlLine{num: first.num, indent: keyIndent, text: content})
+	}
+	var object cidrObject
+	line := first.num
+	children := map[int][]yamlLine{}
+	for _, l := range item[1:] {
+		if keyIndent < 0 {
+			keyIndent = l.indent
+		}
+		if l.indent < keyIndent || (l.indent == keyIndent && !isYAMLListItem(l.text)) {
+			keys = append(keys, l)
+			continue
+		}
+		if len(keys) > 0 {
+			children[len(keys)-1] = append(children[len(keys)-1], l)
+		}
+	}
+	for i, key := range keys {
+		match := yamlKeyRegex.FindStringSubmatch(key.text)
+		if match == nil {
+			return cidrObject{}, 0, fmt.Errorf("line %d: expected a \"key: value\" mapping entry", key.num)
+		}
+		value := yamlScalar(match[2])
+		switch match[1] {
+		case "cidr":
+			object.CIDR, line = value, key.num
+		case "name":
+			object.Name = value
+		case "draining":
+			object.Draining = value
+		case "tags":
+			object.Tags = yamlSequence(match[2], children[i])
+		}
+	}
+	if object.CIDR == "" {
+		return cidrObject{}, 0, fmt.Errorf("line %d: expected a block or a mapping with a \"cidr\" key", first.num)
+	}
+	return object, line, nil
+}
+
+// yamlSequence returns the scalars of a sequence given either in flow
+// style as value, "[a, b]", or as the block list items of children.
+func yamlSequence(value string, children []yamlLine) []string {
+	var values []string
+	if value = strings.TrimSpace(value); strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		for _, item := range strings.Split(value[1:len(value)-1], ",") {
+			if item = yamlScalar(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		return values
+	}
+	for _, child := range children {
+		if isYAMLListItem(child.text) {
+			values = append(values, yamlScalar(strings.TrimPrefix(child.text, "-")))
+		}
+	}
+	return values
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCIDRJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "Compat string array",
+			input: `["10.0.0.0/8", "192.168.*.*"]`,
+			want:  "10.0.0.0/8,192.168.0.0/16",
+		},
+		{
+			name:  "Versioned document",
+			input: `{"version": 1, "cidrs": [{"cidr": "10.0.0.0/8", "first": "10.0.0.0", "last": "10.255.255.255", "count": 16777216}]}`,
+			want:  "10.0.0.0/8",
+		},
+		{
+			name:  "Mixed array",
+			input: `["10.0.0.0/8", {"cidr": "172.16.0.0/12", "name": "corp"}]`,
+			want:  "10.0.0.0/8,172.16.0.0/12",
+		},
+		{
+			name:    "Document without cidrs",
+			input:   `{"version": 1}`,
+			wantErr: true,
+		},
+		{
+			name:    "Object without cidr",
+			input:   `[{"name": "corp"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid CIDR",
+			input:   `["10.0.0.0/33"]`,
+			wantErr: true,
+		},
+		{
+			name:    "Not JSON",
+			input:   `10.0.0.0/8`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCIDRJSON(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCIDRJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && joinCIDRs(got) != tt.want {
+				t.Errorf("parseCIDRJSON() = %q, want %q", joinCIDRs(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCIDRYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "Plain list",
+			input: "# allow list\n- 10.0.0.0/8\n- \"192.168.0.0/16\" # office\n",
+			want:  "10.0.0.0/8,192.168.0.0/16",
+		},
+		{
+			name: "Versioned document",
+			input: "version: 1\ncidrs:\n" +
+				"  - cidr: 10.0.0.0/8\n    first: 10.0.0.0\n    last: 10.255.255.255\n    count: 16777216\n" +
+				"  - name: corp\n    cidr: 172.16.0.0/12\n",
+			want: "10.0.0.0/8,172.16.0.0/12",
+		},
+		{
+			name: "Provenance sources are not entries",
+			input: "cidrs:\n- cidr: 10.0.0.0/23\n  mergedFrom:\n    - 10.0.0.0/24\n  sources:\n    - file: a.txt\n      cidr: 10.0.0.0/24\n" +
+				"processedAt: 2024-05-01T00:00:00Z\n",
+			want: "10.0.0.0/23",
+		},
+		{
+			name:    "Invalid CIDR",
+			input:   "- 10.0.0.0/33\n",
+			wantErr: true,
+		},
+		{
+			name:    "Mapping without cidr",
+			input:   "- name: corp\n  tags: [prod]\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCIDRYAML(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCIDRYAML() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && joinCIDRs(got) != tt.want {
+				t.Errorf("parseCIDRYAML() = %q, want %q", joinCIDRs(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestScanCIDRYAMLMetadata(t *testing.T) {
+	input := "cidrs:\n" +
+		"  - cidr: 10.0.0.0/24\n    name: web\n    tags:\n      - prod\n      - \"eu\"\n" +
+		"  - name: db\n    cidr: 10.0.1.0/24\n    tags: [staging, eu]\n    draining: 2024-06-01\n" +
+		"  - cidr: 2001:db8::/32\n    tags:\n    - lab\n" +
+		"  - 10.0.2.0/24\n"
+	entries, err := scanCIDRYAML(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("scanCIDRYAML() error = %v", err)
+	}
+
+	var got []string
+	for _, entry := range entries {
+		desc := fmt.Sprintf("%d:%s", entry.Line, entry.CIDR)
+		if metadata := describeMetadata(entry); metadata != "" {
+			desc += " (" + metadata + ")"
+		}
+		got = append(got, desc)
+	}
+	want := []string{
+		`2:10.0.0.0/24 (name "web", tags prod,eu)`,
+		`8:10.0.1.0/24 (name "db", tags staging,eu, draining 2024-06-01)`,
+		`11:2001:db8::/32 (tags lab)`,
+		`14:10.0.2.0/24`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scanCIDRYAML() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// addrsToCIDRs returns the networks of the given interface addresses.
+// Addresses that do not carry a netmask are skipped.
+func addrsToCIDRs(addrs []net.Addr) []*net.IPNet {
+	cidrs := []*net.IPNet{}
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipnet.IP
+		if v4 := ip.To4(); v4 != nil && len(ipnet.Mask) == net.IPv4len {
+			ip = v4
+		}
+		cidrs = append(cidrs, &net.IPNet{IP: ip.Mask(ipnet.Mask), Mask: ipnet.Mask})
+	}
+	return cidrs
+}
+
+// interfaceCIDRs returns the subnets configured on the host's network
+// interfaces.
+func interfaceCIDRs() ([]*net.IPNet, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("error listing interfaces: %v", err)
+	}
+	var addrs []net.Addr
+	for _, iface := range ifaces {
+		ifaceAddrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("error reading addresses of %s: %v", iface.Name, err)
+		}
+		addrs = append(addrs, ifaceAddrs...)
+	}
+	return deduplicateCIDRs(addrsToCIDRs(addrs)), nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAddrsToCIDRs(t *testing.T) {
+	_, v6, _ := net.ParseCIDR("2001:db8::/64")
+	addrs := []net.Addr{
+		&net.IPNet{IP: net.ParseIP("192.168.1.23"), Mask: net.CIDRMask(24, 32)},
+		&net.IPNet{IP: net.ParseIP("2001:db8::1"), Mask: v6.Mask},
+		&net.IPAddr{IP: net.ParseIP("10.0.0.1")},
+	}
+
+	got := joinCIDRs(addrsToCIDRs(addrs))
+	want := "192.168.1.0/24,2001:db8::/64"
+	if got != want {
+		t.Errorf("addrsToCIDRs() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,32 @@
+package ipfilter_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"D/Pratik/Code/cidr-converter/client"
+	"D/Pratik/Code/cidr-converter/ipfilter"
+)
+
+func Example() {
+	allow, err := client.ParseCIDRSet("10.0.0.0/8,192.168.0.0/16")
+	if err != nil {
+		panic(err)
+	}
+	filter := &ipfilter.Filter{Allow: allow, Forwarded: ipfilter.ForwardedLast}
+	handler := filter.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "hello")
+	}))
+
+	for _, clientIP := range []string{"10.1.2.3", "203.0.113.7"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Forwarded-For", clientIP)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		fmt.Println(clientIP, rec.Code)
+	}
+	// Output:
+	// 10.1.2.3 200
+	// 203.0.113.7 403
+}
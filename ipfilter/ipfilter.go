@@ -0,0 +1,113 @@
+// Package ipfilter provides net/http middleware that allows or denies
+// requests by client address against client.CIDRSet lists.
+package ipfilter
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"D/Pratik/Code/cidr-converter/client"
+)
+
+// ForwardedMode selects which address identifies the client.
+type ForwardedMode int
+
+const (
+	// RemoteAddr uses the address of the connection and ignores forwarding
+	// headers. It is the only safe choice when clients connect directly.
+	RemoteAddr ForwardedMode = iota
+	// ForwardedFirst uses the leftmost address of the forwarding header,
+	// the one reported by the first proxy. Clients can forge it.
+	ForwardedFirst
+	// ForwardedLast uses the rightmost address of the forwarding header,
+	// the one added by the proxy in front of the server.
+	ForwardedLast
+	// ForwardedTrusted walks the forwarding header back from the connection
+	// through the Filter's TrustedProxies and uses the first untrusted
+	// address, as client.CIDRSet.ClientIP does.
+	ForwardedTrusted
+)
+
+// Filter decides which requests reach the wrapped handler.
+type Filter struct {
+	// Allow, when not empty, lists the only addresses let through.
+	Allow client.CIDRSet
+	// Deny lists addresses rejected even when allowed.
+	Deny client.CIDRSet
+	// Forwarded selects the client address; RemoteAddr when zero.
+	Forwarded ForwardedMode
+	// TrustedProxies lists the proxies believed by ForwardedTrusted.
+	TrustedProxies client.CIDRSet
+	// Header is the forwarding header read by the forwarded modes;
+	// "X-Forwarded-For" when empty.
+	Header string
+	// Denied handles rejected requests; a plain 403 Forbidden when nil.
+	Denied http.Handler
+}
+
+// ClientIP returns the address identifying the client of r, or nil when it
+// cannot be determined.
+func (f *Filter) ClientIP(r *http.Request) net.IP {
+	header := f.Header
+	if header == "" {
+		header = "X-Forwarded-For"
+	}
+	switch f.Forwarded {
+	case ForwardedTrusted:
+		ip, err := f.TrustedProxies.ClientIP(r.RemoteAddr, r.Header.Values(header)...)
+		if err != nil {
+			return nil
+		}
+		return ip
+	case ForwardedFirst, ForwardedLast:
+		var hops []string
+		for _, value := range r.Header.Values(header) {
+			for _, hop := range strings.Split(value, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+		if len(hops) > 0 {
+			hop := hops[len(hops)-1]
+			if f.Forwarded == ForwardedFirst {
+				hop = hops[0]
+			}
+			return parseHost(hop)
+		}
+	}
+	return parseHost(r.RemoteAddr)
+}
+
+// Allowed reports whether requests from ip are let through. A nil ip is
+// never allowed.
+func (f *Filter) Allowed(ip net.IP) bool {
+	if ip == nil || f.Deny.Contains(ip) {
+		return false
+	}
+	return len(f.Allow) == 0 || f.Allow.Contains(ip)
+}
+
+// Wrap returns a handler passing allowed requests to next.
+func (f *Filter) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f.Allowed(f.ClientIP(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if f.Denied != nil {
+			f.Denied.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	})
+}
+
+// parseHost parses an address with or without a port.
+func parseHost(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(strings.Trim(addr, "[]"))
+}
@@ -0,0 +1,105 @@
+package ipfilter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"D/Pratik/Code/cidr-converter/client"
+)
+
+func TestFilterWrap(t *testing.T) {
+	allow, _ := client.ParseCIDRSet("10.0.0.0/8,2001:db8::/32")
+	deny, _ := client.ParseCIDRSet("10.1.0.0/16")
+	trusted, _ := client.ParseCIDRSet("192.0.2.0/24")
+
+	tests := []struct {
+		name       string
+		forwarded  ForwardedMode
+		remoteAddr string
+		xff        []string
+		want       int
+	}{
+		{name: "Allowed", remoteAddr: "10.2.3.4:1234", want: http.StatusOK},
+		{name: "Allowed IPv6", remoteAddr: "[2001:db8::1]:1234", want: http.StatusOK},
+		{name: "Not in the allow list", remoteAddr: "192.0.2.1:1234", want: http.StatusForbidden},
+		{name: "Denied", remoteAddr: "10.1.2.3:1234", want: http.StatusForbidden},
+		{name: "Header ignored", remoteAddr: "192.0.2.1:1234", xff: []string{"10.2.3.4"}, want: http.StatusForbidden},
+		{
+			name:       "First forwarded address",
+			forwarded:  ForwardedFirst,
+			remoteAddr: "192.0.2.1:1234",
+			xff:        []string{"10.2.3.4, 192.0.2.2"},
+			want:       http.StatusOK,
+		},
+		{
+			name:       "Last forwarded address",
+			forwarded:  ForwardedLast,
+			remoteAddr: "10.2.3.4:1234",
+			xff:        []string{"10.2.3.4", "192.0.2.2"},
+			want:       http.StatusForbidden,
+		},
+		{
+			name:       "Trusted proxy chain",
+			forwarded:  ForwardedTrusted,
+			remoteAddr: "192.0.2.1:1234",
+			xff:        []string{"192.0.2.9, 10.2.3.4, 192.0.2.2"},
+			want:       http.StatusOK,
+		},
+		{
+			name:       "Untrusted peer with a forged header",
+			forwarded:  ForwardedTrusted,
+			remoteAddr: "198.51.100.1:1234",
+			xff:        []string{"10.2.3.4"},
+			want:       http.StatusForbidden,
+		},
+		{
+			name:       "No header falls back to the connection",
+			forwarded:  ForwardedLast,
+			remoteAddr: "10.2.3.4:1234",
+			want:       http.StatusOK,
+		},
+		{
+			name:       "Invalid forwarded address",
+			forwarded:  ForwardedFirst,
+			remoteAddr: "10.2.3.4:1234",
+			xff:        []string{"unknown"},
+			want:       http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Filter{Allow: allow, Deny: deny, Forwarded: tt.forwarded, TrustedProxies: trusted}
+			handler := f.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.xff {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterDeniedHandler(t *testing.T) {
+	deny, _ := client.ParseCIDRSet("192.0.2.0/24")
+	f := &Filter{
+		Deny: deny,
+		Denied: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	f.Wrap(http.NotFoundHandler()).ServeHTTP(rec, req)
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusTeapot)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseProblem is a problem found while reading lenient input. Line and
+// Column are 1-based, Column counting characters; Offset is the byte offset
+// of the problem from the start of the input.
+type parseProblem struct {
+	File       string `json:"file,omitempty"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+	Offset     int    `json:"offset"`
+	Text       string `json:"text"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+	// Recovered is set when the line still produced a block despite the
+	// problem.
+	Recovered bool `json:"recovered"`
+}
+
+func (p parseProblem) String() string {
+	s := fmt.Sprintf("line %d, column %d: %s", p.Line, p.Column, p.Message)
+	if p.File != "" {
+		s = fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+	}
+	if p.Suggestion != "" {
+		s += fmt.Sprintf(" (did you mean %q?)", p.Suggestion)
+	}
+	return s
+}
+
+// parseReport collects the problems found in lenient input.
+type parseReport struct {
+	Problems []parseProblem `json:"problems"`
+}
+
+// strayPunctuation are characters commonly left around blocks copied from
+// spreadsheets, code or prose.
+const strayPunctuation = `,;"'()[]{}<>`
+
+// scanCIDRListLenient reads the same line format as scanCIDRList but
+// recovers from malformed lines instead of stopping. Byte order marks, CR
+// line endings, trailing comments and stray punctuation around entries are
+// stripped; every problem is reported with its position and, when one is
+// known, a suggested correction. Lines that cannot be recovered are
+// skipped. The returned error is only set when reading fails.
+func scanCIDRListLenient(r io.Reader) ([]inputEntry, parseReport, error) {
+	var entries []inputEntry
+	report := parseReport{Problems: []parseProblem{}}
+	reader := bufio.NewReader(r)
+	offset := 0
+	for lineNum := 1; ; lineNum++ {
+		raw, err := reader.ReadString('\n')
+		if raw == "" && err != nil {
+			if err != io.EOF {
+				return nil, report, fmt.Errorf("error reading input: %v", err)
+			}
+			break
+		}
+		lineStart := offset
+		offset += len(raw)
+
+		line := strings.TrimRight(raw, "\r\n")
+		if lineNum == 1 {
+			if trimmed := strings.TrimPrefix(line, "\uFEFF"); trimmed != line {
+				lineStart += len(line) - len(trimmed)
+				line = trimmed
+			}
+		}
+		lineEntries, problems := parseLenientLine(line)
+		for _, problem := range problems {
+			problem.Line = lineNum
+			problem.Offset += lineStart
+			report.Problems = append(report.Problems, problem)
+		}
+		for _, ipnet := range lineEntries {
+			entries = append(entries, inputEntry{CIDR: ipnet, Line: lineNum})
+		}
+		if err != nil {
+			break
+		}
+	}
+	return entries, report, nil
+}
+
+// parseLenientLine parses a single line of lenient input. The Column and
+// Offset of the returned problems are relative to the start of line.
+func parseLenientLine(line string) ([]*net.IPNet, []parseProblem) {
+	// Work on the byte range [start, end) of line so positions stay exact.
+	start, end := 0, len(line)
+	if i := strings.Index(line, "#"); i >= 0 {
+		end = i
+	}
+	for start < end && isSpace(line[start]) {
+		start++
+	}
+	for end > start && isSpace(line[end-1]) {
+		end--
+	}
+	if start == end {
+		return nil, nil
+	}
+
+	var problems []parseProblem
+	problem := func(pos int, text, message, suggestion string) {
+		problems = append(problems, parseProblem{
+			Column:     utf8.RuneCountInString(line[:pos]) + 1,
+			Offset:     pos,
+			Text:       text,
+			Message:    message,
+			Suggestion: suggestion,
+		})
+	}
+
+	// Strip stray punctuation from both ends.
+	lead := start
+	for start < end && (strings.IndexByte(strayPunctuation, line[start]) >= 0 || isSpace(line[start])) {
+		start++
+	}
+	if start > lead {
+		problem(lead, line[lead:start], "stray punctuation before the entry", "")
+	}
+	trail := end
+	for end > start && (strings.IndexByte(strayPunctuation+".", line[end-1]) >= 0 || isSpace(line[end-1])) {
+		end--
+	}
+	if end < trail {
+		problem(end, line[end:trail], "stray punctuation after the entry", "")
+	}
+	if start == end {
+		for i := range problems {
+			problems[i].Message = "line holds only punctuation"
+		}
+		return nil, problems
+	}
+
+	token := line[start:end]
+	if strings.ContainsAny(token, " \t") {
+		compact := strings.Join(strings.Fields(token), "")
+		problem(start+strings.IndexAny(token, " \t"), token, "whitespace inside the entry", compact)
+		token = compact
+	}
+
+	cidrs, err := parseEntry(token)
+	if err == nil {
+		if !strings.Contains(token, "*") {
+			if ip, ipnet, _ := net.ParseCIDR(token); !ip.Equal(ipnet.IP) {
+				problem(start, token, "host bits are set", ipnet.String())
+			}
+		}
+		for i := range problems {
+			problems[i].Recovered = true
+		}
+		return cidrs, problems
+	}
+
+	before := len(problems)
+	for _, p := range diagnoseEntry(token) {
+		problem(start+p.Offset, p.Text, p.Message, p.Suggestion)
+	}
+	if len(problems) == before {
+		problem(start, token, err.Error(), "")
+	}
+	return nil, problems
+}
+
+// diagnoseEntry lists what is wrong with an entry that failed to parse. The
+// Offset of every problem is relative to the start of entry; the other
+// position fields are left unset.
+func diagnoseEntry(entry string) []parseProblem {
+	var problems []parseProblem
+	add := func(offset int, text, message, suggestion string) {
+		problems = append(problems, parseProblem{Offset: offset, Text: text, Message: message, Suggestion: suggestion})
+	}
+
+	addr, prefix, hasPrefix := strings.Cut(entry, "/")
+	wildcard := strings.Contains(addr, "*")
+	bits := 32
+	if strings.Contains(addr, ":") {
+		bits = 128
+		if net.ParseIP(addr) == nil {
+			add(0, addr, "invalid IPv6 address", "")
+		}
+	} else {
+		problems = append(problems, diagnoseIPv4(addr)...)
+	}
+
+	switch {
+	case !hasPrefix && !wildcard:
+		suggestion := ""
+		if len(problems) == 0 {
+			suggestion = fmt.Sprintf("%s/%d", addr, bits)
+		}
+		add(len(entry), "", "missing prefix length", suggestion)
+	case hasPrefix && wildcard:
+		add(len(addr), "/"+prefix, "wildcard notation cannot carry a prefix length", addr)
+	case hasPrefix:
+		offset := len(addr) + 1
+		if i := strings.Index(prefix, "/"); i >= 0 {
+			add(offset+i, prefix[i:], "unexpected '/'", "")
+			prefix = prefix[:i]
+		}
+		n, err := strconv.Atoi(prefix)
+		switch {
+		case prefix == "":
+			add(offset, "", "empty prefix length", "")
+		case err != nil || prefix[0] == '+' || prefix[0] == '-':
+			add(offset, prefix, "prefix length is not a number", "")
+		case n > bits:
+			add(offset, prefix, fmt.Sprintf("prefix length %d exceeds %d", n, bits), strconv.Itoa(bits))
+		}
+	}
+	return problems
+}
+
+// diagnoseIPv4 lists what is wrong with the octets of an IPv4 address or
+// wildcard pattern, with offsets relative to the start of addr.
+func diagnoseIPv4(addr string) []parseProblem {
+	var problems []parseProblem
+	octets := strings.Split(addr, ".")
+	if len(octets) != 4 {
+		problems = append(problems, parseProblem{Text: addr, Message: fmt.Sprintf("expected 4 octets, found %d", len(octets))})
+	}
+	offset := 0
+	for _, octet := range octets {
+		problem := parseProblem{Offset: offset, Text: octet}
+		offset += len(octet) + 1
+		if octet == "*" {
+			continue
+		}
+		if octet == "" {
+			problem.Message = "empty octet"
+			problems = append(problems, problem)
+			continue
+		}
+		if fixed := strings.NewReplacer("O", "0", "o", "0", "l", "1", "I", "1").Replace(octet); fixed != octet {
+			if _, err := strconv.Atoi(fixed); err == nil {
+				problem.Message = "letter in place of a digit"
+				problem.Suggestion = fixed
+				problems = append(problems, problem)
+				continue
+			}
+		}
+		value, err := strconv.Atoi(octet)
+		switch {
+		case err != nil || octet[0] == '+' || octet[0] == '-':
+			problem.Message = fmt.Sprintf("octet %q is not a number", octet)
+		case value > 255:
+			problem.Message = fmt.Sprintf("octet %d is out of range 0-255", value)
+		case len(octet) > 1 && octet[0] == '0':
+			problem.Message = "leading zero in octet"
+			problem.Suggestion = strconv.Itoa(value)
+		default:
+			continue
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// isSpace reports whether b is an ASCII space or tab.
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t'
+}
+
+// readCIDRFileEntriesLenient is readCIDRFileEntries reading plain lists
+// with scanCIDRListLenient. JSON and YAML documents, RIR delegation files and
+// zone files are read strictly.
+func readCIDRFileEntriesLenient(filename string) ([]inputEntry, []parseProblem, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json", ".yaml", ".yml":
+		entries, err := readCIDRFileEntries(filename)
+		return entries, nil, err
+	}
+	if isDelegatedFile(filename) || isZoneFile(filename) {
+		entries, err := readCIDRFileEntries(filename)
+		return entries, nil, err
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+
+	entries, report, err := scanCIDRListLenient(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	for i := range entries {
+		entries[i].File = filename
+	}
+	for i := range report.Problems {
+		report.Problems[i].File = filename
+	}
+	return entries, report.Problems, nil
+}
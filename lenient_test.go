@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanCIDRListLenient(t *testing.T) {
+	input := "\uFEFF10.0.0.0/8\r\n" +
+		"\"192.168.1.0/24\",\r\n" +
+		"10.0.0.300/33\n" +
+		"# comment\n" +
+		"172.16.0.0 / 12 # trailing comment\n" +
+		"10.1.1.1/16\n" +
+		"1O.0.0.0\n"
+
+	entries, report, err := scanCIDRListLenient(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("scanCIDRListLenient() error = %v", err)
+	}
+
+	var cidrs []string
+	for _, entry := range entries {
+		cidrs = append(cidrs, entry.CIDR.String())
+	}
+	if got, want := strings.Join(cidrs, ","), "10.0.0.0/8,192.168.1.0/24,172.16.0.0/12,10.1.0.0/16"; got != want {
+		t.Errorf("scanCIDRListLenient() blocks = %q, want %q", got, want)
+	}
+
+	want := []parseProblem{
+		{Line: 2, Column: 1, Offset: 15, Text: `"`, Message: "stray punctuation before the entry", Recovered: true},
+		{Line: 2, Column: 16, Offset: 30, Text: `",`, Message: "stray punctuation after the entry", Recovered: true},
+		{Line: 3, Column: 8, Offset: 41, Text: "300", Message: "octet 300 is out of range 0-255"},
+		{Line: 3, Column: 12, Offset: 45, Text: "33", Message: "prefix length 33 exceeds 32", Suggestion: "32"},
+		{Line: 5, Column: 11, Offset: 68, Text: "172.16.0.0 / 12", Message: "whitespace inside the entry", Suggestion: "172.16.0.0/12", Recovered: true},
+		{Line: 6, Column: 1, Offset: 93, Text: "10.1.1.1/16", Message: "host bits are set", Suggestion: "10.1.0.0/16", Recovered: true},
+		{Line: 7, Column: 1, Offset: 105, Text: "1O", Message: "letter in place of a digit", Suggestion: "10"},
+		{Line: 7, Column: 9, Offset: 113, Text: "", Message: "missing prefix length"},
+	}
+	if len(report.Problems) != len(want) {
+		t.Fatalf("scanCIDRListLenient() found %d problems, want %d: %+v", len(report.Problems), len(want), report.Problems)
+	}
+	for i, got := range report.Problems {
+		if got != want[i] {
+			t.Errorf("problem %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestDiagnoseEntry(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Missing prefix", input: "10.1.2.3", want: "missing prefix length -> 10.1.2.3/32"},
+		{name: "Missing IPv6 prefix", input: "2001:db8::1", want: "missing prefix length -> 2001:db8::1/128"},
+		{name: "Leading zero", input: "10.01.0.0/16", want: "leading zero in octet -> 1"},
+		{name: "Too few octets", input: "10.0.0/8", want: "expected 4 octets, found 3"},
+		{name: "Empty octet", input: "10..0.0/8", want: "empty octet"},
+		{name: "Invalid IPv6", input: "2001:db8::zz/32", want: "invalid IPv6 address"},
+		{name: "Wildcard with prefix", input: "10.*.*.*/8", want: "wildcard notation cannot carry a prefix length -> 10.*.*.*"},
+		{name: "Prefix not a number", input: "10.0.0.0/x", want: "prefix length is not a number"},
+		{name: "Several problems", input: "10.0.0.300/33", want: "octet 300 is out of range 0-255; prefix length 33 exceeds 32 -> 32"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range diagnoseEntry(tt.input) {
+				s := p.Message
+				if p.Suggestion != "" {
+					s += " -> " + p.Suggestion
+				}
+				got = append(got, s)
+			}
+			if strings.Join(got, "; ") != tt.want {
+				t.Errorf("diagnoseEntry() = %q, want %q", strings.Join(got, "; "), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProblemString(t *testing.T) {
+	p := parseProblem{File: "a.txt", Line: 3, Column: 12, Message: "prefix length 33 exceeds 32", Suggestion: "32"}
+	if got, want := p.String(), `a.txt:3:12: prefix length 33 exceeds 32 (did you mean "32"?)`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestReadCIDRFileEntriesLenientFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"db.example.com", zoneSample, "192.0.2.1/32,192.0.2.2/32,192.0.2.3/32,2001:db8::3/128,192.0.2.4/32"},
+		{"delegated-ripencc-extended-latest", delegatedSample, "193.0.0.0/21,194.0.0.0/23,194.0.2.0/24,2001:678::/29"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(filename, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			entries, problems, err := readCIDRFileEntriesLenient(filename)
+			if err != nil || len(problems) > 0 {
+				t.Fatalf("readCIDRFileEntriesLenient() = %v, %v", problems, err)
+			}
+			cidrs, _ := entryCIDRs(entries, nil)
+			if got := joinCIDRs(cidrs); got != tt.want {
+				t.Errorf("readCIDRFileEntriesLenient() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
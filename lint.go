@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// Lint rule types.
+const (
+	ruleMaxSize   = "max-size"
+	ruleMinSize   = "min-size"
+	ruleWithin    = "within"
+	ruleNotWithin = "not-within"
+	ruleNoOverlap = "no-overlap"
+)
+
+// lintConfig is the rules file given to "lint":
+//
+//	{
+//	  "rules": [
+//	    {"id": "no-huge-blocks", "type": "max-size", "prefix": 20},
+//	    {"id": "private-only", "type": "within", "cidrs": ["10.0.0.0/8"]},
+//	    {"id": "env-isolation", "type": "no-overlap", "tags": ["prod", "staging"]}
+//	  ]
+//	}
+type lintConfig struct {
+	Rules []lintRule `json:"rules"`
+}
+
+// lintRule is one rule of a lintConfig. Prefix and Prefix6 are the limits
+// of max-size and min-size for IPv4 and IPv6 blocks, zero leaving a family
+// unchecked. CIDRs are the allowed or forbidden space of within and
+// not-within. Tags make no-overlap only report overlaps between entries
+// tagged with different ones of them, e.g. different environments.
+type lintRule struct {
+	ID       string   `json:"id"`
+	Type     string   `json:"type"`
+	Severity string   `json:"severity,omitempty"`
+	Message  string   `json:"message,omitempty"`
+	Prefix   int      `json:"prefix,omitempty"`
+	Prefix6  int      `json:"prefix6,omitempty"`
+	CIDRs    []string `json:"cidrs,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+
+	blocks []*net.IPNet
+}
+
+// lintFinding is a violation of a rule by an input entry.
+type lintFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	CIDR     string `json:"cidr"`
+	Message  string `json:"message"`
+}
+
+func (f lintFinding) String() string {
+	return fmt.Sprintf("%s: %s [%s] %s: %s", entryPosition(inputEntry{File: f.File, Line: f.Line}), f.Severity, f.Rule, f.CIDR, f.Message)
+}
+
+// loadLintConfig reads and validates a rules file.
+func loadLintConfig(filename string) ([]lintRule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading rules: %v", err)
+	}
+	var config lintConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: error decoding JSON: %v", filename, err)
+	}
+	if err := prepareLintRules(config.Rules); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return config.Rules, nil
+}
+
+// prepareLintRules validates rules in place, filling in the default
+// severity and parsing their blocks.
+func prepareLintRules(rules []lintRule) error {
+	if len(rules) == 0 {
+		return fmt.Errorf("no rules defined")
+	}
+	ids := map[string]bool{}
+	for i := range rules {
+		rule := &rules[i]
+		if rule.ID == "" {
+			return fmt.Errorf("rule %d has no id", i+1)
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("rule %s defined twice", rule.ID)
+		}
+		ids[rule.ID] = true
+		switch rule.Severity {
+		case "":
+			rule.Severity = "error"
+		case "error", "warning":
+		default:
+			return fmt.Errorf("rule %s: unknown severity %q, expected error or warning", rule.ID, rule.Severity)
+		}
+
+		switch rule.Type {
+		case ruleMaxSize, ruleMinSize:
+			if rule.Prefix < 0 || rule.Prefix > 32 || rule.Prefix6 < 0 || rule.Prefix6 > 128 || rule.Prefix+rule.Prefix6 == 0 {
+				return fmt.Errorf("rule %s: %s needs a prefix of 1-32 or a prefix6 of 1-128", rule.ID, rule.Type)
+			}
+		case ruleWithin, ruleNotWithin:
+			if len(rule.CIDRs) == 0 {
+				return fmt.Errorf("rule %s: %s needs cidrs", rule.ID, rule.Type)
+			}
+			for _, cidr := range rule.CIDRs {
+				block, err := parseCIDR(cidr)
+				if err != nil {
+					return fmt.Errorf("rule %s: %v", rule.ID, err)
+				}
+				rule.blocks = append(rule.blocks, block)
+			}
+		case ruleNoOverlap:
+		default:
+			return fmt.Errorf("rule %s: unknown type %q", rule.ID, rule.Type)
+		}
+	}
+	return nil
+}
+
+// prefixLimit returns the limit of a size rule for the family of cidr, or
+// 0 when the family is not checked.
+func (r lintRule) prefixLimit(cidr *net.IPNet) int {
+	if cidr.IP.To4() != nil {
+		return r.Prefix
+	}
+	return r.Prefix6
+}
+
+// checkEntry returns the message of the violation of r by a single entry,
+// or "" when it complies. no-overlap is checked by checkOverlaps instead.
+func (r lintRule) checkEntry(entry inputEntry) string {
+	ones, _ := entry.CIDR.Mask.Size()
+	switch r.Type {
+	case ruleMaxSize:
+		if limit := r.prefixLimit(entry.CIDR); limit > 0 && ones < limit {
+			return fmt.Sprintf("block is larger than /%d", limit)
+		}
+	case ruleMinSize:
+		if limit := r.prefixLimit(entry.CIDR); limit > 0 && ones > limit {
+			return fmt.Sprintf("block is smaller than /%d", limit)
+		}
+	case ruleWithin:
+		if outside := subtractCIDRs([]*net.IPNet{entry.CIDR}, r.blocks); len(outside) > 0 {
+			return fmt.Sprintf("block is not within %s", strings.Join(r.CIDRs, ", "))
+		}
+	case ruleNotWithin:
+		if inside := intersectCIDRs([]*net.IPNet{entry.CIDR}, r.blocks); len(inside) > 0 {
+			return fmt.Sprintf("block overlaps forbidden space %s", joinCIDRList(inside))
+		}
+	}
+	return ""
+}
+
+// joinCIDRList returns cidrs as a comma-separated list.
+func joinCIDRList(cidrs []*net.IPNet) string {
+	var parts []string
+	for _, cidr := range cidrs {
+		parts = append(parts, cidr.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ruleTags returns the tags of entry named by the rule.
+func (r lintRule) ruleTags(entry inputEntry) map[string]bool {
+	tags := map[string]bool{}
+	for _, tag := range entry.Tags {
+		for _, ruleTag := range r.Tags {
+			if tag == ruleTag {
+				tags[tag] = true
+			}
+		}
+	}
+	return tags
+}
+
+// checkOverlaps returns the findings of a no-overlap rule, reported at the
+// later entry of every overlapping pair. With tags, only entries carrying
+// different ones of them and no common one conflict.
+func (r lintRule) checkOverlaps(entries []inputEntry) []lintFinding {
+	var findings []lintFinding
+	for j, later := range entries {
+		for _, earlier := range entries[:j] {
+			if (earlier.File == later.File && earlier.Line == later.Line) || !cidrsOverlap(earlier.CIDR, later.CIDR) {
+				continue
+			}
+			message := fmt.Sprintf("block overlaps %s (%s)", earlier.CIDR, entryPosition(earlier))
+			if len(r.Tags) > 0 {
+				earlierTags, laterTags := r.ruleTags(earlier), r.ruleTags(later)
+				if len(earlierTags) == 0 || len(laterTags) == 0 || sharesTag(earlierTags, laterTags) {
+					continue
+				}
+				message = fmt.Sprintf("block tagged %s overlaps %s (%s) tagged %s",
+					strings.Join(later.Tags, ","), earlier.CIDR, entryPosition(earlier), strings.Join(earlier.Tags, ","))
+			}
+			findings = append(findings, r.finding(later, message))
+		}
+	}
+	return findings
+}
+
+// sharesTag reports whether a and b have a tag in common.
+func sharesTag(a, b map[string]bool) bool {
+	for tag := range a {
+		if b[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// finding returns a finding of r for entry, using the rule's own message
+// when it has one.
+func (r lintRule) finding(entry inputEntry, message string) lintFinding {
+	if r.Message != "" {
+		message = r.Message
+	}
+	return lintFinding{Rule: r.ID, Severity: r.Severity, File: entry.File, Line: entry.Line, CIDR: entry.CIDR.String(), Message: message}
+}
+
+// lintEntries checks entries against rules, returning the findings rule by
+// rule in input order.
+func lintEntries(rules []lintRule, entries []inputEntry) []lintFinding {
+	findings := []lintFinding{}
+	for _, rule := range rules {
+		if rule.Type == ruleNoOverlap {
+			findings = append(findings, rule.checkOverlaps(entries)...)
+			continue
+		}
+		for _, entry := range entries {
+			if message := rule.checkEntry(entry); message != "" {
+				findings = append(findings, rule.finding(entry, message))
+			}
+		}
+	}
+	return findings
+}
+
+// renderLintFindings writes findings one per line followed by a summary,
+// and returns the number of errors among them.
+func renderLintFindings(w io.Writer, findings []lintFinding) int {
+	errors := 0
+	for _, f := range findings {
+		fmt.Fprintln(w, f)
+		if f.Severity == "error" {
+			errors++
+		}
+	}
+	fmt.Fprintf(w, "errors: %d, warnings: %d\n", errors, len(findings)-errors)
+	return errors
+}
+
+// runLint implements the "lint" command.
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	rulesFile := fs.String("rules", "", "JSON file of the rules to check")
+	format := fs.String("output-format", "text", "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *rulesFile == "" || fs.NArg() == 0 {
+		return fmt.Errorf("usage: lint -rules <file> [--output-format=text|json] <file>...")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown output format: %s", *format)
+	}
+	rules, err := loadLintConfig(*rulesFile)
+	if err != nil {
+		return err
+	}
+	var entries []inputEntry
+	for _, filename := range fs.Args() {
+		fileEntries, err := readCIDRFileEntries(filename)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	findings := lintEntries(rules, entries)
+	errors := 0
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(findings); err != nil {
+			return err
+		}
+		for _, f := range findings {
+			if f.Severity == "error" {
+				errors++
+			}
+		}
+	} else {
+		errors = renderLintFindings(os.Stdout, findings)
+	}
+	if errors > 0 {
+		return fmt.Errorf("lint found %d errors", errors)
+	}
+	return nil
+}
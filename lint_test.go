@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const lintInput = `[
+  {"cidr": "10.0.0.0/16", "name": "prod-a", "tags": ["prod"]},
+  {"cidr": "10.0.128.0/20", "name": "staging-a", "tags": ["staging"]},
+  {"cidr": "10.0.1.0/24", "name": "prod-b", "tags": ["prod"]},
+  {"cidr": "192.168.0.0/30", "tags": ["lab"]},
+  {"cidr": "8.8.8.0/24"}
+]`
+
+func TestLintEntries(t *testing.T) {
+	entries, err := scanCIDRJSON(strings.NewReader(lintInput))
+	if err != nil {
+		t.Fatalf("scanCIDRJSON() error = %v", err)
+	}
+	for i := range entries {
+		entries[i].File = "plan.json"
+	}
+
+	tests := []struct {
+		name string
+		rule lintRule
+		want []string
+	}{
+		{
+			name: "Max size",
+			rule: lintRule{ID: "max", Type: ruleMaxSize, Prefix: 20},
+			want: []string{"plan.json:1: error [max] 10.0.0.0/16: block is larger than /20"},
+		},
+		{
+			name: "Min size",
+			rule: lintRule{ID: "min", Type: ruleMinSize, Prefix: 29, Severity: "warning"},
+			want: []string{"plan.json:4: warning [min] 192.168.0.0/30: block is smaller than /29"},
+		},
+		{
+			name: "Within",
+			rule: lintRule{ID: "private", Type: ruleWithin, CIDRs: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+			want: []string{"plan.json:5: error [private] 8.8.8.0/24: block is not within 10.0.0.0/8, 192.168.0.0/16"},
+		},
+		{
+			name: "Not within",
+			rule: lintRule{ID: "reserved", Type: ruleNotWithin, CIDRs: []string{"10.0.1.128/25"}, Message: "reserved for routers"},
+			want: []string{
+				"plan.json:1: error [reserved] 10.0.0.0/16: reserved for routers",
+				"plan.json:3: error [reserved] 10.0.1.0/24: reserved for routers",
+			},
+		},
+		{
+			name: "Any overlap",
+			rule: lintRule{ID: "overlap", Type: ruleNoOverlap},
+			want: []string{
+				"plan.json:2: error [overlap] 10.0.128.0/20: block overlaps 10.0.0.0/16 (plan.json:1)",
+				"plan.json:3: error [overlap] 10.0.1.0/24: block overlaps 10.0.0.0/16 (plan.json:1)",
+			},
+		},
+		{
+			name: "Overlap across environments",
+			rule: lintRule{ID: "env", Type: ruleNoOverlap, Tags: []string{"prod", "staging"}},
+			want: []string{"plan.json:2: error [env] 10.0.128.0/20: block tagged staging overlaps 10.0.0.0/16 (plan.json:1) tagged prod"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := []lintRule{tt.rule}
+			if err := prepareLintRules(rules); err != nil {
+				t.Fatalf("prepareLintRules() error = %v", err)
+			}
+			var got []string
+			for _, f := range lintEntries(rules, entries) {
+				got = append(got, f.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("lintEntries() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestPrepareLintRulesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []lintRule
+	}{
+		{name: "No rules"},
+		{name: "No id", rules: []lintRule{{Type: ruleNoOverlap}}},
+		{name: "Duplicate id", rules: []lintRule{{ID: "a", Type: ruleNoOverlap}, {ID: "a", Type: ruleNoOverlap}}},
+		{name: "Unknown type", rules: []lintRule{{ID: "a", Type: "max-hosts"}}},
+		{name: "Unknown severity", rules: []lintRule{{ID: "a", Type: ruleNoOverlap, Severity: "fatal"}}},
+		{name: "Size without prefix", rules: []lintRule{{ID: "a", Type: ruleMaxSize}}},
+		{name: "Prefix out of range", rules: []lintRule{{ID: "a", Type: ruleMaxSize, Prefix: 33}}},
+		{name: "Within without cidrs", rules: []lintRule{{ID: "a", Type: ruleWithin}}},
+		{name: "Invalid cidr", rules: []lintRule{{ID: "a", Type: ruleWithin, CIDRs: []string{"10.0.0.0/33"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := prepareLintRules(tt.rules); err == nil {
+				t.Errorf("prepareLintRules() expected an error")
+			}
+		})
+	}
+}
+
+func TestRenderLintFindings(t *testing.T) {
+	var buf bytes.Buffer
+	errors := renderLintFindings(&buf, []lintFinding{
+		{Rule: "max", Severity: "error", File: "a.txt", Line: 1, CIDR: "10.0.0.0/8", Message: "block is larger than /20"},
+		{Rule: "min", Severity: "warning", File: "a.txt", Line: 2, CIDR: "10.1.0.0/30", Message: "block is smaller than /29"},
+	})
+	if errors != 1 || !strings.HasSuffix(buf.String(), "errors: 1, warnings: 1\n") {
+		t.Errorf("renderLintFindings() = %d,\n%s", errors, buf.String())
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"container/list"
+	"net"
+	"sync"
+)
+
+// lookupCache is a least recently used cache of /v1/lookup results, for
+// workloads where the same addresses are looked up again and again. It is
+// safe for concurrent use.
+type lookupCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // of *cachedLookup, most recently used first
+	entries  map[string]*list.Element
+	stats    cacheStats
+}
+
+// cachedLookup is the result of looking up an address.
+type cachedLookup struct {
+	key     string
+	matches []*net.IPNet
+	sets    []string
+}
+
+// cacheStats is the response of the /v1/cache endpoint.
+type cacheStats struct {
+	Capacity  int    `json:"capacity"`
+	Size      int    `json:"size"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	// Invalidations counts the times the cache was emptied because the
+	// served set changed.
+	Invalidations uint64 `json:"invalidations"`
+}
+
+// newLookupCache returns a cache holding up to capacity results, or nil,
+// which caches nothing, when capacity is not positive.
+func newLookupCache(capacity int) *lookupCache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &lookupCache{capacity: capacity, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// get returns the cached result for key, counting a hit or a miss.
+func (c *lookupCache) get(key string) (*cachedLookup, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*cachedLookup), true
+}
+
+// add caches a result, evicting the least recently used one when full.
+func (c *lookupCache) add(result *cachedLookup) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[result.key]; ok {
+		element.Value = result
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[result.key] = c.order.PushFront(result)
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedLookup).key)
+		c.stats.Evictions++
+	}
+}
+
+// purge empties the cache.
+func (c *lookupCache) purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = map[string]*list.Element{}
+	c.stats.Invalidations++
+}
+
+// snapshot returns the current statistics of the cache.
+func (c *lookupCache) snapshot() cacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Capacity, stats.Size = c.capacity, c.order.Len()
+	return stats
+}
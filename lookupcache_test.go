@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookupCache(t *testing.T) {
+	c := newLookupCache(2)
+	c.add(&cachedLookup{key: "a"})
+	c.add(&cachedLookup{key: "b"})
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("get(a) missed")
+	}
+	// b is now the least recently used entry.
+	c.add(&cachedLookup{key: "c"})
+	if _, ok := c.get("b"); ok {
+		t.Error("get(b) hit after eviction")
+	}
+	if _, ok := c.get("c"); !ok {
+		t.Error("get(c) missed")
+	}
+
+	want := cacheStats{Capacity: 2, Size: 2, Hits: 2, Misses: 1, Evictions: 1}
+	if got := c.snapshot(); got != want {
+		t.Errorf("snapshot() = %+v, want %+v", got, want)
+	}
+	c.purge()
+	if got := c.snapshot(); got.Size != 0 || got.Invalidations != 1 {
+		t.Errorf("snapshot() after purge = %+v", got)
+	}
+	if _, ok := c.get("a"); ok {
+		t.Error("get(a) hit after purge")
+	}
+
+	if newLookupCache(0) != nil {
+		t.Error("newLookupCache(0) != nil")
+	}
+}
+
+func TestServerLookupCache(t *testing.T) {
+	cidrs, _ := parseCIDRList(strings.NewReader("10.0.0.0/8\n"))
+	s := newServer(cidrs)
+	s.cache = newLookupCache(16)
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	get := func(path string) string {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	stats := func() cacheStats {
+		var stats cacheStats
+		if err := json.Unmarshal([]byte(get("/v1/cache")), &stats); err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+
+	get("/v1/lookup?ip=10.1.1.1")
+	if body := get("/v1/lookup?ip=10.1.1.1"); !strings.Contains(body, `"ip":"10.1.1.1","match":true`) {
+		t.Errorf("cached lookup = %s", body)
+	}
+	get("/v1/lookup?ip=bogus")
+	if got := stats(); got.Hits != 1 || got.Misses != 1 || got.Size != 1 {
+		t.Errorf("stats after lookups = %+v, want 1 hit and 1 miss", got)
+	}
+
+	reloaded, _ := parseCIDRList(strings.NewReader("192.168.0.0/16\n"))
+	s.setCIDRs(reloaded)
+	if body := get("/v1/lookup?ip=10.1.1.1"); !strings.Contains(body, `"match":false`) {
+		t.Errorf("lookup after reload = %s", body)
+	}
+	if got := stats(); got.Invalidations != 1 || got.Misses != 2 {
+		t.Errorf("stats after reload = %+v, want 1 invalidation and 2 misses", got)
+	}
+}
+
+func TestServerLookupCacheSchedule(t *testing.T) {
+	base, _ := parseCIDRList(strings.NewReader("10.0.0.0/8\n"))
+	extra, _ := parseCIDRList(strings.NewReader("192.168.0.0/16\n"))
+	s := newScheduledServer([]scheduledSet{
+		{Name: "base", CIDRs: base},
+		{Name: "extra", CIDRs: extra, From: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+	})
+	s.cache = newLookupCache(16)
+
+	s.now = func() time.Time { return time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC) }
+	if found, _ := s.lookup("192.168.1.1"); len(found.matches) != 0 {
+		t.Errorf("lookup before the set starts = %v", found.matches)
+	}
+	s.now = func() time.Time { return time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC) }
+	if found, _ := s.lookup("192.168.1.1"); len(found.matches) != 1 || strings.Join(found.sets, ",") != "extra" {
+		t.Errorf("lookup once the set started = %v, %v", found.matches, found.sets)
+	}
+	if found, _ := s.lookup("10.1.1.1"); len(found.matches) != 1 || strings.Join(found.sets, ",") != "base" {
+		t.Errorf("lookup of the base set = %v, %v", found.matches, found.sets)
+	}
+	s.lookup("192.168.1.1")
+	if got := s.cache.snapshot(); got.Hits != 1 || got.Misses != 3 {
+		t.Errorf("stats = %+v, want 1 hit and 3 misses", got)
+	}
+	if len(s.unions) != 2 {
+		t.Errorf("built %d union tries, want one per schedule generation", len(s.unions))
+	}
+}
+
+func TestServerLookupCacheDisabled(t *testing.T) {
+	ts := httptest.NewServer(newServer(nil).handler())
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/v1/cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /v1/cache status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
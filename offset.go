@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// nthAddress returns the address at index n within cidr, where 0 is the
+// network address. Negative indexes count back from the last address, so -1
+// is the broadcast address of an IPv4 block.
+func nthAddress(cidr *net.IPNet, n *big.Int) (net.IP, error) {
+	size := cidrSize(cidr)
+	index := new(big.Int).Set(n)
+	if index.Sign() < 0 {
+		index.Add(index, size)
+	}
+	if index.Sign() < 0 || index.Cmp(size) >= 0 {
+		return nil, fmt.Errorf("offset %s is outside %s", n, cidr)
+	}
+	base := cidr.IP.Mask(cidr.Mask)
+	return intToIP(index.Add(index, ipToInt(base)), len(base)), nil
+}
+
+// addressIndex returns the index of ip within cidr, where 0 is the network
+// address.
+func addressIndex(cidr *net.IPNet, ip net.IP) (*big.Int, error) {
+	if !cidr.Contains(ip) {
+		return nil, fmt.Errorf("%s is not within %s", ip, cidr)
+	}
+	base := cidr.IP.Mask(cidr.Mask)
+	return new(big.Int).Sub(ipToInt(ip), ipToInt(base)), nil
+}
+
+// runOffset implements the "offset" command. Given an integer it prints the
+// address at that offset; given an IP it prints the IP's offset.
+func runOffset(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: offset <cidr> <index|ip>")
+	}
+	cidr, err := parseCIDR(args[0])
+	if err != nil {
+		return err
+	}
+
+	if n, ok := new(big.Int).SetString(args[1], 10); ok {
+		ip, err := nthAddress(cidr, n)
+		if err != nil {
+			return err
+		}
+		fmt.Println(ip)
+		return nil
+	}
+
+	ip := net.ParseIP(args[1])
+	if ip == nil {
+		return fmt.Errorf("invalid offset or IP address: %s", args[1])
+	}
+	index, err := addressIndex(cidr, ip)
+	if err != nil {
+		return err
+	}
+	fmt.Println(index)
+	return nil
+}
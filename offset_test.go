@@ -0,0 +1,66 @@
+package main
+
+import (
+	"math/big"
+	"net"
+	"testing"
+)
+
+func TestNthAddress(t *testing.T) {
+	_, cidr, _ := net.ParseCIDR("192.168.1.0/24")
+
+	tests := []struct {
+		name    string
+		n       int64
+		want    string
+		wantErr bool
+	}{
+		{name: "Network address", n: 0, want: "192.168.1.0"},
+		{name: "Gateway", n: 1, want: "192.168.1.1"},
+		{name: "Last address", n: 255, want: "192.168.1.255"},
+		{name: "Negative offset", n: -2, want: "192.168.1.254"},
+		{name: "Past the end", n: 256, wantErr: true},
+		{name: "Before the start", n: -257, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nthAddress(cidr, big.NewInt(tt.n))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("nthAddress() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("nthAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddressIndex(t *testing.T) {
+	_, cidr, _ := net.ParseCIDR("10.0.0.0/16")
+
+	tests := []struct {
+		name    string
+		ip      string
+		want    int64
+		wantErr bool
+	}{
+		{name: "Network address", ip: "10.0.0.0", want: 0},
+		{name: "Inside block", ip: "10.0.1.2", want: 258},
+		{name: "Outside block", ip: "10.1.0.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := addressIndex(cidr, net.ParseIP(tt.ip))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("addressIndex() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got.Int64() != tt.want {
+				t.Errorf("addressIndex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// overlapEntry is a pair of blocks from two different files that share
+// addresses.
+type overlapEntry struct {
+	FileA   string `json:"fileA"`
+	LineA   int    `json:"lineA,omitempty"`
+	CIDRA   string `json:"cidrA"`
+	FileB   string `json:"fileB"`
+	LineB   int    `json:"lineB,omitempty"`
+	CIDRB   string `json:"cidrB"`
+	Overlap string `json:"overlap"`
+}
+
+// overlapReport lists the cross-file overlaps among a set of files.
+// Matrix[i][j] is the number of overlapping block pairs between Files[i]
+// and Files[j]; the diagonal is always zero.
+type overlapReport struct {
+	Files    []string       `json:"files"`
+	Matrix   [][]int        `json:"matrix"`
+	Overlaps []overlapEntry `json:"overlaps"`
+}
+
+// findOverlaps compares every pair of files and records every pair of
+// blocks that overlap across them. Overlaps within a single file are not
+// reported. The blocks of each file are looked up through an index built by
+// newIndex.
+func findOverlaps(files []string, entries [][]inputEntry, newIndex func(cidrs []*net.IPNet) cidrIndex) overlapReport {
+	report := overlapReport{Files: files, Matrix: make([][]int, len(files)), Overlaps: []overlapEntry{}}
+	for i := range files {
+		report.Matrix[i] = make([]int, len(files))
+	}
+	indexes := make([]cidrIndex, len(files))
+	positions := make([]map[*net.IPNet]int, len(files))
+	for j := range files {
+		cidrs := make([]*net.IPNet, len(entries[j]))
+		positions[j] = map[*net.IPNet]int{}
+		for k, entry := range entries[j] {
+			cidrs[k] = entry.CIDR
+			positions[j][entry.CIDR] = k
+		}
+		indexes[j] = newIndex(cidrs)
+	}
+
+	for i := range files {
+		for j := i + 1; j < len(files); j++ {
+			for _, a := range entries[i] {
+				var matches []int
+				for _, cidr := range indexes[j].Overlapping(a.CIDR) {
+					matches = append(matches, positions[j][cidr])
+				}
+				sort.Ints(matches)
+				for _, k := range matches {
+					b := entries[j][k]
+					overlap := a.CIDR
+					if cidrContains(a.CIDR, b.CIDR) {
+						overlap = b.CIDR
+					}
+					report.Matrix[i][j]++
+					report.Matrix[j][i]++
+					report.Overlaps = append(report.Overlaps, overlapEntry{
+						FileA: files[i], LineA: a.Line, CIDRA: a.CIDR.String(),
+						FileB: files[j], LineB: b.Line, CIDRB: b.CIDR.String(),
+						Overlap: overlap.String(),
+					})
+				}
+			}
+		}
+	}
+	return report
+}
+
+// renderOverlapMatrix writes the report as a matrix of overlap counts
+// followed by the list of overlapping blocks.
+func renderOverlapMatrix(w io.Writer, report overlapReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, file := range report.Files {
+		fmt.Fprintf(tw, "\t%s", file)
+	}
+	fmt.Fprintln(tw)
+	for i, file := range report.Files {
+		fmt.Fprint(tw, file)
+		for j := range report.Files {
+			if i == j {
+				fmt.Fprint(tw, "\t-")
+			} else {
+				fmt.Fprintf(tw, "\t%d", report.Matrix[i][j])
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, overlap := range report.Overlaps {
+		fmt.Fprintf(w, "\n%s (%s) overlaps %s (%s): %s", overlap.CIDRA, location(overlap.FileA, overlap.LineA),
+			overlap.CIDRB, location(overlap.FileB, overlap.LineB), overlap.Overlap)
+	}
+	if len(report.Overlaps) > 0 {
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// location formats a file name and an optional line number.
+func location(file string, line int) string {
+	return entryLocation(inputEntry{File: file, Line: line})
+}
+
+// runOverlaps implements the "overlaps" command.
+func runOverlaps(args []string) error {
+	flags := flag.NewFlagSet("overlaps", flag.ContinueOnError)
+	format := flags.String("output-format", "text", "output format: text or json")
+	indexKind := flags.String("index", "interval", "index used for overlap queries: interval or trie")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		return fmt.Errorf("usage: overlaps [--output-format=text|json] [--index=interval|trie] <file> <file>...")
+	}
+	newIndex, ok := cidrIndexes[*indexKind]
+	if !ok {
+		return fmt.Errorf("unknown index: %s", *indexKind)
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown output format: %s", *format)
+	}
+
+	files := flags.Args()
+	entries := make([][]inputEntry, len(files))
+	for i, file := range files {
+		fileEntries, err := readCIDRFileEntries(file)
+		if err != nil {
+			return err
+		}
+		entries[i] = fileEntries
+	}
+	report := findOverlaps(files, entries, newIndex)
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return fmt.Errorf("error encoding JSON: %v", err)
+		}
+	} else if err := renderOverlapMatrix(os.Stdout, report); err != nil {
+		return err
+	}
+	if len(report.Overlaps) > 0 {
+		return fmt.Errorf("found %d overlapping block pairs", len(report.Overlaps))
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFindOverlaps(t *testing.T) {
+	inputs := []string{
+		"10.0.0.0/16\n172.16.0.0/12\n",
+		"10.0.5.0/24\n192.168.0.0/16\n",
+		"192.168.1.0/24\n10.0.0.0/8\n",
+	}
+	files := []string{"a.txt", "b.txt", "c.txt"}
+	entries := make([][]inputEntry, len(inputs))
+	for i, input := range inputs {
+		entries[i], _ = scanCIDRList(strings.NewReader(input))
+	}
+
+	for name, newIndex := range cidrIndexes {
+		t.Run(name, func(t *testing.T) {
+			testFindOverlaps(t, findOverlaps(files, entries, newIndex))
+		})
+	}
+}
+
+func testFindOverlaps(t *testing.T, report overlapReport) {
+
+	wantMatrix := [][]int{{0, 1, 1}, {1, 0, 2}, {1, 2, 0}}
+	for i := range wantMatrix {
+		for j := range wantMatrix[i] {
+			if report.Matrix[i][j] != wantMatrix[i][j] {
+				t.Errorf("Matrix[%d][%d] = %d, want %d", i, j, report.Matrix[i][j], wantMatrix[i][j])
+			}
+		}
+	}
+
+	var got []string
+	for _, o := range report.Overlaps {
+		got = append(got, o.FileA+":"+o.CIDRA+"~"+o.FileB+":"+o.CIDRB+"="+o.Overlap)
+	}
+	want := "a.txt:10.0.0.0/16~b.txt:10.0.5.0/24=10.0.5.0/24," +
+		"a.txt:10.0.0.0/16~c.txt:10.0.0.0/8=10.0.0.0/16," +
+		"b.txt:10.0.5.0/24~c.txt:10.0.0.0/8=10.0.5.0/24," +
+		"b.txt:192.168.0.0/16~c.txt:192.168.1.0/24=192.168.1.0/24"
+	if strings.Join(got, ",") != want {
+		t.Errorf("Overlaps = %v, want %v", strings.Join(got, ","), want)
+	}
+}
+
+func TestRenderOverlapMatrix(t *testing.T) {
+	a, _ := scanCIDRList(strings.NewReader("10.0.0.0/16\n"))
+	b, _ := scanCIDRList(strings.NewReader("10.0.5.0/24\n"))
+	report := findOverlaps([]string{"a.txt", "b.txt"}, [][]inputEntry{a, b}, newIntervalIndex)
+
+	var buf bytes.Buffer
+	if err := renderOverlapMatrix(&buf, report); err != nil {
+		t.Fatalf("renderOverlapMatrix() error = %v", err)
+	}
+	want := "       a.txt  b.txt\n" +
+		"a.txt  -      1\n" +
+		"b.txt  1      -\n" +
+		"\n10.0.0.0/16 (a.txt:1) overlaps 10.0.5.0/24 (b.txt:1): 10.0.5.0/24\n"
+	if buf.String() != want {
+		t.Errorf("renderOverlapMatrix() = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// Protobuf wire types used by the messages in proto/cidrs.proto.
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// appendVarint appends v in protobuf base-128 varint encoding.
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+// appendTag appends the key of a protobuf field.
+func appendTag(buf []byte, field int, wireType int) []byte {
+	return appendVarint(buf, uint64(field)<<3|uint64(wireType))
+}
+
+// appendBytesField appends a length-delimited protobuf field. Empty values
+// are omitted, as proto3 does for default values.
+func appendBytesField(buf []byte, field int, value []byte) []byte {
+	if len(value) == 0 {
+		return buf
+	}
+	buf = appendTag(buf, field, wireBytes)
+	buf = appendVarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+// marshalCIDRInfo encodes a CIDRInfo message.
+func marshalCIDRInfo(info cidrInfo) []byte {
+	var buf []byte
+	buf = appendBytesField(buf, 1, []byte(info.CIDR))
+	buf = appendBytesField(buf, 2, []byte(info.First))
+	buf = appendBytesField(buf, 3, []byte(info.Last))
+	buf = appendBytesField(buf, 4, []byte(info.Count.String()))
+	return buf
+}
+
+// marshalCIDROutput encodes a CIDROutput message.
+func marshalCIDROutput(output cidrOutput) []byte {
+	var buf []byte
+	if output.Version != 0 {
+		buf = appendTag(buf, 1, wireVarint)
+		buf = appendVarint(buf, uint64(output.Version))
+	}
+	for _, info := range output.CIDRs {
+		buf = appendTag(buf, 2, wireBytes)
+		msg := marshalCIDRInfo(info)
+		buf = appendVarint(buf, uint64(len(msg)))
+		buf = append(buf, msg...)
+	}
+	return buf
+}
+
+// saveToProto saves CIDRs to a file as a binary CIDROutput message.
+func saveToProto(filename string, cidrs []*net.IPNet) error {
+	if err := os.WriteFile(filename, marshalCIDROutput(newCIDROutput(cidrs)), 0o644); err != nil {
+		return fmt.Errorf("error writing file: %v", err)
+	}
+	return nil
+}
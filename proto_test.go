@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestAppendVarint(t *testing.T) {
+	tests := []struct {
+		name  string
+		input uint64
+		want  []byte
+	}{
+		{name: "Single byte", input: 1, want: []byte{0x01}},
+		{name: "Two bytes", input: 300, want: []byte{0xac, 0x02}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appendVarint(nil, tt.input); !bytes.Equal(got, tt.want) {
+				t.Errorf("appendVarint() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalCIDROutput(t *testing.T) {
+	_, cidr, _ := net.ParseCIDR("10.0.0.0/31")
+
+	got := marshalCIDROutput(newCIDROutput([]*net.IPNet{cidr}))
+	info := []byte{}
+	info = append(info, 0x0a, 11)
+	info = append(info, "10.0.0.0/31"...)
+	info = append(info, 0x12, 8)
+	info = append(info, "10.0.0.0"...)
+	info = append(info, 0x1a, 8)
+	info = append(info, "10.0.0.1"...)
+	info = append(info, 0x22, 1, '2')
+	want := append([]byte{0x08, 0x01, 0x12, byte(len(info))}, info...)
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("marshalCIDROutput() = %x, want %x", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net"
+	"time"
+)
+
+// cidrSource identifies an input block that contributed to an output block.
+type cidrSource struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line,omitempty"`
+	CIDR     string   `json:"cidr"`
+	Name     string   `json:"name,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Draining string   `json:"draining,omitempty"`
+}
+
+// addProvenance records, for every block of output, the input entries that
+// ended up in it and the processing time. MergedFrom lists the distinct
+// input blocks that differ from the output block, i.e. the ones that were
+// merged or swallowed into it.
+func addProvenance(output *cidrOutput, entries []inputEntry, processedAt time.Time) {
+	output.ProcessedAt = processedAt.UTC().Format(time.RFC3339)
+	for i := range output.CIDRs {
+		info := &output.CIDRs[i]
+		_, block, err := net.ParseCIDR(info.CIDR)
+		if err != nil {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, entry := range entries {
+			if !cidrsOverlap(block, entry.CIDR) {
+				continue
+			}
+			cidr := entry.CIDR.String()
+			source := cidrSource{File: entry.File, Line: entry.Line, CIDR: cidr, Name: entry.Name, Tags: entry.Tags}
+			if !entry.Draining.IsZero() {
+				source.Draining = entry.Draining.Format(drainingLayout)
+			}
+			info.Sources = append(info.Sources, source)
+			if cidr != info.CIDR && !seen[cidr] {
+				seen[cidr] = true
+				info.MergedFrom = append(info.MergedFrom, cidr)
+			}
+		}
+	}
+}
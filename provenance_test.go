@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAddProvenance(t *testing.T) {
+	entries, _ := scanCIDRList(strings.NewReader("10.0.0.0/25\n10.0.0.128/25\n# comment\n192.168.0.0/16\n10.0.0.0/25\n"))
+	for i := range entries {
+		entries[i].File = "input.txt"
+	}
+	_, merged, _ := net.ParseCIDR("10.0.0.0/24")
+	_, other, _ := net.ParseCIDR("192.168.0.0/16")
+
+	output := newCIDROutput([]*net.IPNet{merged, other})
+	addProvenance(&output, entries, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	if output.ProcessedAt != "2024-01-02T03:04:05Z" {
+		t.Errorf("ProcessedAt = %q", output.ProcessedAt)
+	}
+
+	first := output.CIDRs[0]
+	if len(first.Sources) != 3 {
+		t.Fatalf("first block has %d sources, want 3", len(first.Sources))
+	}
+	if !reflect.DeepEqual(first.Sources[1], cidrSource{File: "input.txt", Line: 2, CIDR: "10.0.0.128/25"}) {
+		t.Errorf("first block source = %+v", first.Sources[1])
+	}
+	if strings.Join(first.MergedFrom, ",") != "10.0.0.0/25,10.0.0.128/25" {
+		t.Errorf("first block mergedFrom = %v", first.MergedFrom)
+	}
+
+	second := output.CIDRs[1]
+	if len(second.Sources) != 1 || second.Sources[0].Line != 4 {
+		t.Errorf("second block sources = %+v", second.Sources)
+	}
+	if len(second.MergedFrom) != 0 {
+		t.Errorf("unchanged block has mergedFrom = %v", second.MergedFrom)
+	}
+}
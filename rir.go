@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// delegation is an IPv4 or IPv6 record of an RIR delegated or
+// delegated-extended statistics file:
+//
+//	registry|cc|type|start|value|date|status[|opaque-id[|extensions...]]
+//
+// For IPv4 the value is the number of addresses, which need not be a power
+// of two; for IPv6 it is the prefix length.
+type delegation struct {
+	Registry string
+	Country  string
+	Date     string
+	Status   string
+	// OpaqueID identifies the holder in delegated-extended files; every
+	// record of the same organisation shares it.
+	OpaqueID string
+	CIDRs    []*net.IPNet
+	Line     int
+}
+
+// isDelegatedFile reports whether filename follows the RIR naming scheme,
+// e.g. delegated-ripencc-extended-latest.
+func isDelegatedFile(filename string) bool {
+	return strings.HasPrefix(filepath.Base(filename), "delegated-")
+}
+
+// parseDelegated reads the IPv4 and IPv6 records of a delegated statistics
+// file. The version line, summary lines, comments and ASN records are
+// skipped.
+func parseDelegated(r io.Reader) ([]delegation, error) {
+	var delegations []delegation
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		if _, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			continue // version line
+		}
+		if len(fields) >= 6 && fields[5] == "summary" {
+			continue
+		}
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("line %d: expected at least 7 fields, got %d", lineNum, len(fields))
+		}
+		family := fields[2]
+		if family != "ipv4" && family != "ipv6" {
+			continue
+		}
+		cidrs, err := delegationCIDRs(family, fields[3], fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		d := delegation{
+			Registry: fields[0],
+			Country:  strings.ToUpper(fields[1]),
+			Date:     fields[5],
+			Status:   strings.ToLower(fields[6]),
+			CIDRs:    cidrs,
+			Line:     lineNum,
+		}
+		if len(fields) > 7 {
+			d.OpaqueID = fields[7]
+		}
+		delegations = append(delegations, d)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading input: %v", err)
+	}
+	return delegations, nil
+}
+
+// delegationCIDRs converts the start and value fields of a record to
+// blocks.
+func delegationCIDRs(family, start, value string) ([]*net.IPNet, error) {
+	ip := net.ParseIP(start)
+	if ip == nil || (ip.To4() != nil) != (family == "ipv4") {
+		return nil, fmt.Errorf("invalid %s start address: %q", family, start)
+	}
+	if family == "ipv6" {
+		prefix, err := strconv.Atoi(value)
+		if err != nil || prefix < 0 || prefix > 128 {
+			return nil, fmt.Errorf("invalid IPv6 prefix length: %q", value)
+		}
+		return []*net.IPNet{{IP: ip.Mask(net.CIDRMask(prefix, 128)), Mask: net.CIDRMask(prefix, 128)}}, nil
+	}
+	count, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || count == 0 {
+		return nil, fmt.Errorf("invalid IPv4 address count: %q", value)
+	}
+	last := new(big.Int).Add(ipToInt(ip.To4()), new(big.Int).SetUint64(count-1))
+	lastIP := intToIP(last, net.IPv4len)
+	if lastIP == nil {
+		return nil, fmt.Errorf("range of %d addresses from %s leaves the address space", count, start)
+	}
+	return rangeToCIDRs(ip, lastIP)
+}
+
+// scanDelegated reads the blocks allocated or assigned in a delegated
+// statistics file, for use as merge input.
+func scanDelegated(r io.Reader) ([]inputEntry, error) {
+	delegations, err := parseDelegated(r)
+	if err != nil {
+		return nil, err
+	}
+	var entries []inputEntry
+	for _, d := range delegations {
+		if d.Status != "allocated" && d.Status != "assigned" {
+			continue
+		}
+		for _, cidr := range d.CIDRs {
+			entries = append(entries, inputEntry{CIDR: cidr, Line: d.Line})
+		}
+	}
+	return entries, nil
+}
+
+// delegationFilter selects records of delegated statistics files. Empty
+// fields match every record.
+type delegationFilter struct {
+	Countries map[string]bool
+	Statuses  map[string]bool
+	OpaqueIDs map[string]bool
+	Registry  string
+}
+
+func (f delegationFilter) matches(d delegation) bool {
+	return (len(f.Countries) == 0 || f.Countries[d.Country]) &&
+		(len(f.Statuses) == 0 || f.Statuses[d.Status]) &&
+		(len(f.OpaqueIDs) == 0 || f.OpaqueIDs[d.OpaqueID]) &&
+		(f.Registry == "" || f.Registry == d.Registry)
+}
+
+// splitSet returns the comma-separated values of list as a set, converted
+// with normalize.
+func splitSet(list string, normalize func(string) string) map[string]bool {
+	set := map[string]bool{}
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			set[normalize(value)] = true
+		}
+	}
+	return set
+}
+
+// writeDelegated writes cidrs as delegated statistics records with the
+// given registry, country, date and status. Adjacent IPv4 blocks are
+// written as one record, since IPv4 records count addresses.
+func writeDelegated(w io.Writer, registry, country, date, status string, cidrs []*net.IPNet) error {
+	var ipv4, ipv6 []*net.IPNet
+	for _, cidr := range collapseCIDRs(cidrs) {
+		if cidr.IP.To4() != nil {
+			ipv4 = append(ipv4, cidr)
+		} else {
+			ipv6 = append(ipv6, cidr)
+		}
+	}
+	sortCIDRs(ipv4)
+	sortCIDRs(ipv6)
+	one := big.NewInt(1)
+	var start, end *big.Int
+	flush := func() error {
+		if start == nil {
+			return nil
+		}
+		count := new(big.Int).Sub(end, start)
+		_, err := fmt.Fprintf(w, "%s|%s|ipv4|%s|%s|%s|%s\n", registry, country, intToIP(start, net.IPv4len), count.Add(count, one), date, status)
+		start = nil
+		return err
+	}
+	for _, cidr := range ipv4 {
+		first := ipToInt(cidr.IP.To4())
+		last := new(big.Int).Add(first, cidrSize(cidr))
+		last.Sub(last, one)
+		if start != nil && new(big.Int).Add(end, one).Cmp(first) == 0 {
+			end = last
+			continue
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		start, end = first, last
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	for _, cidr := range ipv6 {
+		ones, _ := cidr.Mask.Size()
+		if _, err := fmt.Fprintf(w, "%s|%s|ipv6|%s|%d|%s|%s\n", registry, country, cidr.IP, ones, date, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runRIR implements the "rir" command.
+func runRIR(args []string) error {
+	usage := fmt.Errorf("usage: rir import [-country cc,...] [-org id,...] [-status s,...] [-registry name] <file>... | rir export -registry name -country cc [-status s] [-date yyyymmdd] <file>...")
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "import":
+		return runRIRImport(args[1:])
+	case "export":
+		return runRIRExport(args[1:])
+	}
+	return usage
+}
+
+// runRIRImport implements "rir import".
+func runRIRImport(args []string) error {
+	fs := flag.NewFlagSet("rir import", flag.ContinueOnError)
+	countries := fs.String("country", "", "comma-separated country codes to keep")
+	orgs := fs.String("org", "", "comma-separated opaque IDs of delegated-extended files to keep")
+	statuses := fs.String("status", "allocated,assigned", "comma-separated statuses to keep, empty for all")
+	registry := fs.String("registry", "", "registry to keep, e.g. ripencc or arin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: rir import [-country cc,...] [-org id,...] [-status s,...] [-registry name] <file>...")
+	}
+	filter := delegationFilter{
+		Countries: splitSet(*countries, strings.ToUpper),
+		Statuses:  splitSet(*statuses, strings.ToLower),
+		OpaqueIDs: splitSet(*orgs, func(s string) string { return s }),
+		Registry:  *registry,
+	}
+
+	var cidrs []*net.IPNet
+	for _, filename := range fs.Args() {
+		file, err := os.Open(filename)
+		if err != nil {
+			return fmt.Errorf("error opening file: %v", err)
+		}
+		delegations, err := parseDelegated(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		for _, d := range delegations {
+			if filter.matches(d) {
+				cidrs = append(cidrs, d.CIDRs...)
+			}
+		}
+	}
+	for _, cidr := range collapseCIDRs(cidrs) {
+		fmt.Println(cidr)
+	}
+	return nil
+}
+
+// runRIRExport implements "rir export".
+func runRIRExport(args []string) error {
+	fs := flag.NewFlagSet("rir export", flag.ContinueOnError)
+	registry := fs.String("registry", "", "registry field of the records")
+	country := fs.String("country", "", "country code field of the records")
+	status := fs.String("status", "assigned", "status field of the records")
+	date := fs.String("date", time.Now().UTC().Format("20060102"), "date field of the records")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *registry == "" || *country == "" || fs.NArg() == 0 {
+		return fmt.Errorf("usage: rir export -registry name -country cc [-status s] [-date yyyymmdd] <file>...")
+	}
+	var cidrs []*net.IPNet
+	for _, filename := range fs.Args() {
+		fileCIDRs, err := readCIDRFile(filename)
+		if err != nil {
+			return err
+		}
+		cidrs = append(cidrs, fileCIDRs...)
+	}
+	return writeDelegated(os.Stdout, *registry, strings.ToUpper(*country), *date, *status, cidrs)
+}
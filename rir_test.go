@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const delegatedSample = `2|ripencc|1714608000|4|19830705|20240501|+0200
+ripencc|*|ipv4|*|3|summary
+ripencc|*|ipv6|*|1|summary
+# a comment
+ripencc|NL|ipv4|193.0.0.0|2048|19930901|allocated|org-1
+ripencc|DE|ipv4|194.0.0.0|768|19940101|assigned|org-2
+ripencc||ipv4|195.0.0.0|256||available
+ripencc|NL|ipv6|2001:678::|29|20040101|allocated|org-1
+ripencc|NL|asn|3333|1|19930901|allocated|org-1
+`
+
+func TestParseDelegated(t *testing.T) {
+	delegations, err := parseDelegated(strings.NewReader(delegatedSample))
+	if err != nil {
+		t.Fatalf("parseDelegated() error = %v", err)
+	}
+	var got []string
+	for _, d := range delegations {
+		got = append(got, d.Country+" "+d.Status+" "+d.OpaqueID+" "+joinCIDRs(d.CIDRs))
+	}
+	want := []string{
+		"NL allocated org-1 193.0.0.0/21",
+		"DE assigned org-2 194.0.0.0/23,194.0.2.0/24",
+		" available  195.0.0.0/24",
+		"NL allocated org-1 2001:678::/29",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("parseDelegated() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseDelegatedErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "Too few fields", input: "ripencc|NL|ipv4|193.0.0.0|256\n"},
+		{name: "Bad count", input: "ripencc|NL|ipv4|193.0.0.0|lots|19930901|allocated\n"},
+		{name: "Past the address space", input: "ripencc|NL|ipv4|255.255.255.0|512|19930901|allocated\n"},
+		{name: "Family mismatch", input: "ripencc|NL|ipv6|193.0.0.0|32|19930901|allocated\n"},
+		{name: "Bad prefix length", input: "ripencc|NL|ipv6|2001:678::|129|20040101|allocated\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseDelegated(strings.NewReader(tt.input)); err == nil {
+				t.Errorf("parseDelegated() expected an error")
+			}
+		})
+	}
+}
+
+func TestDelegationFilter(t *testing.T) {
+	delegations, _ := parseDelegated(strings.NewReader(delegatedSample))
+	tests := []struct {
+		name   string
+		filter delegationFilter
+		want   string
+	}{
+		{name: "Country", filter: delegationFilter{Countries: splitSet("nl", strings.ToUpper)}, want: "193.0.0.0/21,2001:678::/29"},
+		{name: "Organisation", filter: delegationFilter{OpaqueIDs: map[string]bool{"org-2": true}}, want: "194.0.0.0/23,194.0.2.0/24"},
+		{name: "Status", filter: delegationFilter{Statuses: splitSet("available", strings.ToLower)}, want: "195.0.0.0/24"},
+		{name: "Registry", filter: delegationFilter{Registry: "arin"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cidrs []string
+			for _, d := range delegations {
+				if tt.filter.matches(d) {
+					cidrs = append(cidrs, joinCIDRs(d.CIDRs))
+				}
+			}
+			if got := strings.Join(cidrs, ","); got != tt.want {
+				t.Errorf("matches() kept %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDelegated(t *testing.T) {
+	cidrs, _ := parseCIDRList(strings.NewReader("194.0.0.0/23\n194.0.2.0/24\n10.0.0.0/24\n2001:db8::/32\n"))
+	var buf bytes.Buffer
+	if err := writeDelegated(&buf, "ripencc", "DE", "20240501", "assigned", cidrs); err != nil {
+		t.Fatalf("writeDelegated() error = %v", err)
+	}
+	want := "ripencc|DE|ipv4|10.0.0.0|256|20240501|assigned\n" +
+		"ripencc|DE|ipv4|194.0.0.0|768|20240501|assigned\n" +
+		"ripencc|DE|ipv6|2001:db8::|32|20240501|assigned\n"
+	if buf.String() != want {
+		t.Errorf("writeDelegated() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// The written records read back as the same blocks.
+	delegations, err := parseDelegated(&buf)
+	if err != nil {
+		t.Fatalf("parseDelegated() error = %v", err)
+	}
+	var back []string
+	for _, d := range delegations {
+		back = append(back, joinCIDRs(d.CIDRs))
+	}
+	if got := strings.Join(back, ","); got != "10.0.0.0/24,194.0.0.0/23,194.0.2.0/24,2001:db8::/32" {
+		t.Errorf("round trip = %q", got)
+	}
+}
+
+func TestReadDelegatedFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "delegated-ripencc-extended-latest")
+	if err := os.WriteFile(filename, []byte(delegatedSample), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cidrs, err := readCIDRFile(filename)
+	if err != nil {
+		t.Fatalf("readCIDRFile() error = %v", err)
+	}
+	if got, want := joinCIDRs(cidrs), "193.0.0.0/21,194.0.0.0/23,194.0.2.0/24,2001:678::/29"; got != want {
+		t.Errorf("readCIDRFile() = %q, want %q", got, want)
+	}
+}
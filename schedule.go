@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// scheduleConfig is the file given to "serve -config". Each set is served
+// only while it is active:
+//
+//	{
+//	  "sets": [
+//	    {"name": "base", "files": ["allow.txt"]},
+//	    {"name": "maintenance", "files": ["maint.txt"],
+//	     "windows": [{"start": "02:00", "end": "04:00"}]}
+//	  ]
+//	}
+type scheduleConfig struct {
+	Sets []scheduledSetConfig `json:"sets"`
+}
+
+// scheduledSetConfig describes one set of a scheduleConfig. A set without
+// windows is active all day. From and Until, in RFC 3339 form, optionally
+// bound the period in which the set is used at all.
+type scheduledSetConfig struct {
+	Name    string         `json:"name"`
+	Files   []string       `json:"files"`
+	Windows []windowConfig `json:"windows,omitempty"`
+	From    string         `json:"from,omitempty"`
+	Until   string         `json:"until,omitempty"`
+}
+
+// windowConfig is a daily window in UTC as "HH:MM" times. A window whose
+// end is before its start spans midnight.
+type windowConfig struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// dailyWindow is a parsed windowConfig, in minutes since midnight UTC.
+type dailyWindow struct {
+	Start, End int
+}
+
+// contains reports whether t falls in the window.
+func (w dailyWindow) contains(t time.Time) bool {
+	t = t.UTC()
+	minute := t.Hour()*60 + t.Minute()
+	if w.Start <= w.End {
+		return minute >= w.Start && minute < w.End
+	}
+	return minute >= w.Start || minute < w.End
+}
+
+// scheduledSet is a named set of blocks with its validity windows.
+type scheduledSet struct {
+	Name    string
+	CIDRs   []*net.IPNet
+	Windows []dailyWindow
+	From    time.Time
+	Until   time.Time
+}
+
+// activeAt reports whether the set is in use at t.
+func (s scheduledSet) activeAt(t time.Time) bool {
+	if (!s.From.IsZero() && t.Before(s.From)) || (!s.Until.IsZero() && !t.Before(s.Until)) {
+		return false
+	}
+	if len(s.Windows) == 0 {
+		return true
+	}
+	for _, w := range s.Windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextTransition returns the earliest time after now at which one of sets
+// may become active or inactive: the next minute, as windows open and close
+// on minute boundaries, or a from or until time before it.
+func nextTransition(sets []scheduledSet, now time.Time) time.Time {
+	next := now.Truncate(time.Minute).Add(time.Minute)
+	for _, set := range sets {
+		for _, bound := range []time.Time{set.From, set.Until} {
+			if !bound.IsZero() && bound.After(now) && bound.Before(next) {
+				next = bound
+			}
+		}
+	}
+	return next
+}
+
+// parseClock parses an "HH:MM" time into minutes since midnight.
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseScheduledSet validates config without reading its files.
+func parseScheduledSet(config scheduledSetConfig) (scheduledSet, error) {
+	set := scheduledSet{Name: config.Name}
+	if config.Name == "" {
+		return set, fmt.Errorf("set without a name")
+	}
+	for _, w := range config.Windows {
+		start, err := parseClock(w.Start)
+		if err != nil {
+			return set, fmt.Errorf("set %s: %v", config.Name, err)
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			return set, fmt.Errorf("set %s: %v", config.Name, err)
+		}
+		if start == end {
+			return set, fmt.Errorf("set %s: window %s-%s is empty", config.Name, w.Start, w.End)
+		}
+		set.Windows = append(set.Windows, dailyWindow{Start: start, End: end})
+	}
+	for _, bound := range []struct {
+		value string
+		t     *time.Time
+	}{{config.From, &set.From}, {config.Until, &set.Until}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return set, fmt.Errorf("set %s: invalid time %q, expected RFC 3339", config.Name, bound.value)
+		}
+		*bound.t = t
+	}
+	return set, nil
+}
+
+// loadSchedule reads a scheduleConfig and the files of its sets.
+func loadSchedule(filename string) ([]scheduledSet, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %v", err)
+	}
+	var config scheduleConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: error decoding JSON: %v", filename, err)
+	}
+	if len(config.Sets) == 0 {
+		return nil, fmt.Errorf("%s: no sets defined", filename)
+	}
+
+	sets := []scheduledSet{}
+	seen := map[string]bool{}
+	for _, setConfig := range config.Sets {
+		set, err := parseScheduledSet(setConfig)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		if seen[set.Name] {
+			return nil, fmt.Errorf("%s: set %s defined twice", filename, set.Name)
+		}
+		seen[set.Name] = true
+		for _, file := range setConfig.Files {
+			cidrs, err := readCIDRFile(file)
+			if err != nil {
+				return nil, err
+			}
+			set.CIDRs = append(set.CIDRs, cidrs...)
+		}
+		set.CIDRs = collapseCIDRs(set.CIDRs)
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
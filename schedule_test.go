@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduledSetActiveAt(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2026, 3, 1, hour, minute, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		config scheduledSetConfig
+		at     time.Time
+		want   bool
+	}{
+		{name: "No windows", config: scheduledSetConfig{Name: "base"}, at: at(12, 0), want: true},
+		{name: "Inside a window", config: scheduledSetConfig{Name: "m", Windows: []windowConfig{{"02:00", "04:00"}}}, at: at(3, 0), want: true},
+		{name: "At the end of a window", config: scheduledSetConfig{Name: "m", Windows: []windowConfig{{"02:00", "04:00"}}}, at: at(4, 0), want: false},
+		{name: "Window over midnight", config: scheduledSetConfig{Name: "m", Windows: []windowConfig{{"23:00", "01:00"}}}, at: at(0, 30), want: true},
+		{name: "Outside a window over midnight", config: scheduledSetConfig{Name: "m", Windows: []windowConfig{{"23:00", "01:00"}}}, at: at(12, 0), want: false},
+		{name: "Before from", config: scheduledSetConfig{Name: "m", From: "2026-03-02T00:00:00Z"}, at: at(12, 0), want: false},
+		{name: "After until", config: scheduledSetConfig{Name: "m", Until: "2026-03-01T12:00:00Z"}, at: at(12, 0), want: false},
+		{name: "Within from and until", config: scheduledSetConfig{Name: "m", From: "2026-03-01T00:00:00Z", Until: "2026-03-02T00:00:00Z"}, at: at(12, 0), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := parseScheduledSet(tt.config)
+			if err != nil {
+				t.Fatalf("parseScheduledSet() error = %v", err)
+			}
+			if got := set.activeAt(tt.at); got != tt.want {
+				t.Errorf("activeAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScheduledSetErrors(t *testing.T) {
+	for _, config := range []scheduledSetConfig{
+		{},
+		{Name: "m", Windows: []windowConfig{{"2am", "04:00"}}},
+		{Name: "m", Windows: []windowConfig{{"02:00", "25:00"}}},
+		{Name: "m", Windows: []windowConfig{{"02:00", "02:00"}}},
+		{Name: "m", From: "tomorrow"},
+	} {
+		if _, err := parseScheduledSet(config); err == nil {
+			t.Errorf("parseScheduledSet(%+v) expected an error", config)
+		}
+	}
+}
+
+func TestScheduledServer(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("base.txt", "10.0.0.0/8\n")
+	maint := write("maint.txt", "192.168.0.0/16\n")
+	config := write("schedule.json", `{"sets": [
+		{"name": "base", "files": ["`+base+`"]},
+		{"name": "maintenance", "files": ["`+maint+`"], "windows": [{"start": "02:00", "end": "04:00"}]}
+	]}`)
+
+	sets, err := loadSchedule(config)
+	if err != nil {
+		t.Fatalf("loadSchedule() error = %v", err)
+	}
+	s := newScheduledServer(sets)
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	get := func(path string) string {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	s.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	if body := get("/v1/lookup?ip=192.168.1.1"); !strings.Contains(body, `"match":false`) {
+		t.Errorf("lookup outside the window = %s", body)
+	}
+	if body := get("/v1/lookup?ip=10.1.1.1"); !strings.Contains(body, `"sets":["base"]`) {
+		t.Errorf("lookup of the base set = %s", body)
+	}
+
+	s.now = func() time.Time { return time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC) }
+	if body := get("/v1/lookup?ip=192.168.1.1"); !strings.Contains(body, `"match":true`) || !strings.Contains(body, `"sets":["maintenance"]`) {
+		t.Errorf("lookup inside the window = %s", body)
+	}
+	if body := get("/v1/cidrs"); !strings.Contains(body, `"cidr":"192.168.0.0/16"`) {
+		t.Errorf("cidrs inside the window = %s", body)
+	}
+}
+
+func TestNextTransition(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 30, 15, 0, time.UTC)
+	tests := []struct {
+		name string
+		sets []scheduledSet
+		want time.Time
+	}{
+		{name: "Next minute", sets: []scheduledSet{{Windows: []dailyWindow{{Start: 600, End: 900}}}}, want: time.Date(2024, 5, 1, 12, 31, 0, 0, time.UTC)},
+		{name: "Earlier bound", sets: []scheduledSet{{Until: now.Add(10 * time.Second)}}, want: now.Add(10 * time.Second)},
+		{name: "Past bound", sets: []scheduledSet{{From: now.Add(-time.Hour)}}, want: time.Date(2024, 5, 1, 12, 31, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextTransition(tt.sets, now); !got.Equal(tt.want) {
+				t.Errorf("nextTransition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerWatchSchedule(t *testing.T) {
+	cidrs, _ := parseCIDRList(strings.NewReader("10.0.0.0/8\n"))
+	start := time.Now().Add(50 * time.Millisecond)
+	s := newScheduledServer([]scheduledSet{{Name: "maintenance", CIDRs: cidrs, From: start, Until: start.Add(50 * time.Millisecond)}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var changes []string
+	s.watchSchedule(ctx, func(previous, cidrs []*net.IPNet) {
+		changes = append(changes, joinCIDRs(previous)+" -> "+joinCIDRs(cidrs))
+		if len(changes) == 2 {
+			cancel()
+		}
+	})
+	if got := strings.Join(changes, "; "); got != " -> 10.0.0.0/8; 10.0.0.0/8 -> " {
+		t.Errorf("watchSchedule() reported %q", got)
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"D/Pratik/Code/cidr-converter/client"
+)
+
+// lookupResult is the response of the /v1/lookup endpoint.
+type lookupResult struct {
+	IP    string     `json:"ip"`
+	Match bool       `json:"match"`
+	CIDRs []cidrInfo `json:"cidrs"`
+	// Sets names the matching scheduled sets when the server runs with a
+	// schedule.
+	Sets []string `json:"sets,omitempty"`
+}
+
+// pickResult is the response of the /v1/pick endpoint.
+type pickResult struct {
+	Key    string `json:"key"`
+	IP     string `json:"ip"`
+	Method string `json:"method"`
+}
+
+// apiError is the body of every error response from the server.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// server serves a merged CIDR set over HTTP. The endpoints are described in
+// api/openapi.yaml. A server created with newScheduledServer serves the
+// union of its currently active sets instead.
+type server struct {
+	mu    sync.RWMutex
+	cidrs []*net.IPNet
+	sets  []scheduledSet
+	now   func() time.Time
+	// onChange, when set, is called with the previous and new set after
+	// setCIDRs replaces it.
+	onChange func(previous, cidrs []*net.IPNet)
+	// cache, when set, holds recent lookups. It is emptied by setCIDRs.
+	cache *lookupCache
+	// index is the trie over cidrs answering lookups, rebuilt by setCIDRs.
+	index cidrIndex
+	// setIndexes are the tries over the blocks of each scheduled set.
+	// unions are those over the union of every combination of active sets
+	// seen so far, keyed by scheduleGeneration and guarded by unionsMu.
+	setIndexes []cidrIndex
+	unionsMu   sync.Mutex
+	unions     map[string]cidrIndex
+}
+
+// newServer returns a server for the collapsed form of cidrs.
+func newServer(cidrs []*net.IPNet) *server {
+	collapsed := collapseCIDRs(cidrs)
+	return &server{cidrs: collapsed, index: newTrieIndex(collapsed)}
+}
+
+// newScheduledServer returns a server for the given scheduled sets.
+func newScheduledServer(sets []scheduledSet) *server {
+	s := &server{sets: sets, now: time.Now, unions: map[string]cidrIndex{}}
+	for _, set := range sets {
+		s.setIndexes = append(s.setIndexes, newTrieIndex(set.CIDRs))
+	}
+	return s
+}
+
+// watchSchedule calls onChange with the previous and the new served set
+// every time scheduled sets become active or inactive, until ctx is
+// cancelled.
+func (s *server) watchSchedule(ctx context.Context, onChange func(previous, cidrs []*net.IPNet)) error {
+	_, generation := s.scheduleGeneration()
+	previous := s.servedCIDRs()
+	for {
+		now := s.now()
+		if err := sleepContext(ctx, nextTransition(s.sets, now).Sub(now)); err != nil {
+			return err
+		}
+		_, next := s.scheduleGeneration()
+		if next == generation {
+			continue
+		}
+		cidrs := s.servedCIDRs()
+		onChange(previous, cidrs)
+		generation, previous = next, cidrs
+	}
+}
+
+// scheduleGeneration returns the positions of the scheduled sets active
+// now and a key identifying that combination.
+func (s *server) scheduleGeneration() ([]int, string) {
+	var active []int
+	var key []string
+	now := s.now()
+	for i, set := range s.sets {
+		if set.activeAt(now) {
+			active = append(active, i)
+			key = append(key, strconv.Itoa(i))
+		}
+	}
+	return active, strings.Join(key, ",")
+}
+
+// unionIndex returns the trie over the union of the active sets of a
+// generation, building it on first use.
+func (s *server) unionIndex(active []int, generation string) cidrIndex {
+	s.unionsMu.Lock()
+	defer s.unionsMu.Unlock()
+	if index, ok := s.unions[generation]; ok {
+		return index
+	}
+	var sets []scheduledSet
+	for _, i := range active {
+		sets = append(sets, s.sets[i])
+	}
+	index := newTrieIndex(unionOfSets(sets))
+	s.unions[generation] = index
+	return index
+}
+
+// activeSets returns the scheduled sets active now.
+func (s *server) activeSets() []scheduledSet {
+	var active []scheduledSet
+	now := s.now()
+	for _, set := range s.sets {
+		if set.activeAt(now) {
+			active = append(active, set)
+		}
+	}
+	return active
+}
+
+// servedCIDRs returns the set currently served: the fixed set, or the
+// union of the active scheduled sets.
+func (s *server) servedCIDRs() []*net.IPNet {
+	if s.sets == nil {
+		return s.cidrs
+	}
+	return unionOfSets(s.activeSets())
+}
+
+// unionOfSets returns the collapsed union of the blocks of sets.
+func unionOfSets(sets []scheduledSet) []*net.IPNet {
+	var cidrs []*net.IPNet
+	for _, set := range sets {
+		cidrs = append(cidrs, set.CIDRs...)
+	}
+	return collapseCIDRs(cidrs)
+}
+
+// setCIDRs replaces the served set with the collapsed form of cidrs.
+func (s *server) setCIDRs(cidrs []*net.IPNet) {
+	collapsed := collapseCIDRs(cidrs)
+	s.mu.Lock()
+	previous := s.cidrs
+	s.cidrs = collapsed
+	s.index = newTrieIndex(collapsed)
+	s.cache.purge()
+	onChange := s.onChange
+	s.mu.Unlock()
+	if onChange != nil {
+		onChange(previous, collapsed)
+	}
+}
+
+// handler returns the HTTP handler exposing the server's endpoints.
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/cache", s.handleCache)
+	mux.HandleFunc("/v1/cidrs", s.handleCIDRs)
+	mux.HandleFunc("/v1/lookup", s.handleLookup)
+	mux.HandleFunc("/v1/merge", s.handleMerge)
+	mux.HandleFunc("/v1/pick", s.handlePick)
+	return mux
+}
+
+// writeJSON writes v as the JSON response body with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("error encoding response: %v", err)
+	}
+}
+
+// writeError writes an apiError response.
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, apiError{Error: fmt.Sprintf(format, args...)})
+}
+
+// handleCIDRs returns the whole merged set.
+func (s *server) handleCIDRs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, newCIDROutput(s.servedCIDRs()))
+}
+
+// handleLookup reports which blocks of the set contain the "ip" query
+// parameter.
+func (s *server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	ipStr := r.URL.Query().Get("ip")
+
+	s.mu.RLock()
+	found, err := s.lookup(ipStr)
+	s.mu.RUnlock()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	result := lookupResult{IP: ipStr, Match: len(found.matches) > 0, CIDRs: []cidrInfo{}, Sets: found.sets}
+	for _, cidr := range found.matches {
+		result.CIDRs = append(result.CIDRs, newCIDRInfo(cidr))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// lookup returns the blocks and scheduled sets containing ipStr, from the
+// cache when there is one and from the tries otherwise. Results are cached
+// by address and schedule generation, so a schedule moving on does not serve
+// stale results, and a hit costs no more than the cache lookup. The caller
+// must hold s.mu.
+func (s *server) lookup(ipStr string) (*cachedLookup, error) {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", ipStr)
+	}
+	key := ip.String()
+	var active []int
+	var generation string
+	if s.sets != nil {
+		active, generation = s.scheduleGeneration()
+		key += "\x00" + generation
+	}
+	if cached, ok := s.cache.get(key); ok {
+		return cached, nil
+	}
+
+	index := s.index
+	if s.sets != nil {
+		index = s.unionIndex(active, generation)
+	}
+	result := &cachedLookup{key: key, matches: index.Containing(ip)}
+	for _, i := range active {
+		if len(s.setIndexes[i].Containing(ip)) > 0 {
+			result.sets = append(result.sets, s.sets[i].Name)
+		}
+	}
+	s.cache.add(result)
+	return result, nil
+}
+
+// handleCache reports the hit and miss counts of the lookup cache.
+func (s *server) handleCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	if s.cache == nil {
+		writeError(w, http.StatusNotFound, "lookup cache not enabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.cache.snapshot())
+}
+
+// handlePick assigns the "key" query parameter an address of the served
+// set, with the hash or consistent "method" (hash by default).
+func (s *server) handlePick(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "missing key parameter")
+		return
+	}
+	methodName := r.URL.Query().Get("method")
+	if methodName == "" {
+		methodName = client.HashMod.String()
+	}
+	method, err := client.ParsePickMethod(methodName)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "unknown method %q, expected hash or consistent", methodName)
+		return
+	}
+
+	s.mu.RLock()
+	picker, err := client.NewPicker(client.CIDRSet(s.servedCIDRs()), method)
+	s.mu.RUnlock()
+	if err != nil {
+		writeError(w, http.StatusConflict, "cannot pick from the served set: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pickResult{Key: key, IP: picker.Pick(key).String(), Method: method.String()})
+}
+
+// handleMerge merges the blocks posted in the request body, in any of the
+// JSON shapes accepted by parseCIDRJSON, without changing the served set.
+func (s *server) handleMerge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	cidrs, err := parseCIDRJSON(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newCIDROutput(collapseCIDRs(cidrs)))
+}
+
+// runServe implements the "serve" command.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	storeURL := fs.String("store", "", "serve the set kept in this consul:// or etcd:// store and follow its changes")
+	configFile := fs.String("config", "", "serve the scheduled sets defined in this JSON file")
+	tenantsFile := fs.String("tenants", "", "serve the sets of the tenants defined in this JSON file")
+	cacheSize := fs.Int("lookup-cache", 0, "cache the results of up to this many lookups, emptied whenever the set changes")
+	httpFlags := addHTTPFlags(fs)
+	webhookFlags := addWebhookFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	sources := 0
+	for _, given := range []bool{fs.NArg() > 0, *storeURL != "", *configFile != "", *tenantsFile != ""} {
+		if given {
+			sources++
+		}
+	}
+	if *cacheSize < 0 {
+		return fmt.Errorf("invalid lookup cache size: %d", *cacheSize)
+	}
+	if sources != 1 {
+		return fmt.Errorf("usage: serve [-addr host:port] [-webhook url]... (-store url | -config file | -tenants file | <file>...)")
+	}
+	if fs.NArg() > 0 && len(webhookFlags.URLs) > 0 {
+		return fmt.Errorf("-webhook needs -store, -config or -tenants: a set read from files never changes")
+	}
+	hooks := webhookFlags.notifier(httpFlags.client())
+	if *tenantsFile != "" {
+		configs, err := loadTenantsConfig(*tenantsFile)
+		if err != nil {
+			return err
+		}
+		client := httpFlags.client()
+		router, err := newTenantRouter(configs, func(rawURL string) (cidrStore, error) {
+			return openStore(rawURL, client)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %v", *tenantsFile, err)
+		}
+		for name, t := range router.tenants {
+			t.server.cache = newLookupCache(*cacheSize)
+			if err := t.reload(context.Background()); err != nil {
+				return fmt.Errorf("tenant %s: %v", name, err)
+			}
+			name := name
+			t.server.onChange = func(previous, cidrs []*net.IPNet) {
+				hooks.notify(name, previous, cidrs)
+			}
+		}
+		router.watchStores(context.Background())
+		log.Printf("serving %d tenants on %s", len(router.tenants), *addr)
+		return http.ListenAndServe(*addr, router)
+	}
+	if *configFile != "" {
+		sets, err := loadSchedule(*configFile)
+		if err != nil {
+			return err
+		}
+		s := newScheduledServer(sets)
+		s.cache = newLookupCache(*cacheSize)
+		if hooks != nil {
+			go s.watchSchedule(context.Background(), func(previous, cidrs []*net.IPNet) {
+				hooks.notify(*configFile, previous, cidrs)
+			})
+		}
+		log.Printf("serving %d scheduled sets on %s", len(sets), *addr)
+		return http.ListenAndServe(*addr, s.handler())
+	}
+
+	var cidrs []*net.IPNet
+	for _, filename := range fs.Args() {
+		fileCIDRs, err := readCIDRFile(filename)
+		if err != nil {
+			return err
+		}
+		cidrs = append(cidrs, fileCIDRs...)
+	}
+
+	s := newServer(cidrs)
+	s.cache = newLookupCache(*cacheSize)
+	if *storeURL != "" {
+		store, err := openStore(*storeURL, httpFlags.client())
+		if err != nil {
+			return err
+		}
+		stored, err := store.Load(context.Background())
+		if err != nil {
+			return err
+		}
+		s.setCIDRs(stored)
+		s.onChange = func(previous, cidrs []*net.IPNet) {
+			hooks.notify(*storeURL, previous, cidrs)
+		}
+		go func() {
+			err := store.Watch(context.Background(), func(cidrs []*net.IPNet) {
+				s.setCIDRs(cidrs)
+				log.Printf("reloaded %d blocks from %s", len(cidrs), *storeURL)
+			})
+			log.Printf("store watch stopped: %v", err)
+		}()
+	}
+
+	s.mu.RLock()
+	log.Printf("serving %d blocks on %s", len(s.cidrs), *addr)
+	s.mu.RUnlock()
+	return http.ListenAndServe(*addr, s.handler())
+}
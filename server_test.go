@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"D/Pratik/Code/cidr-converter/client"
+)
+
+func TestServerEndpoints(t *testing.T) {
+	cidrs, _ := parseCIDRList(strings.NewReader("10.0.0.0/25\n10.0.0.128/25\n192.168.0.0/16\n"))
+	ts := httptest.NewServer(newServer(cidrs).handler())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		want       string
+	}{
+		{
+			name:       "List CIDRs",
+			method:     http.MethodGet,
+			path:       "/v1/cidrs",
+			wantStatus: http.StatusOK,
+			want:       `"cidr":"10.0.0.0/24"`,
+		},
+		{
+			name:       "Lookup match",
+			method:     http.MethodGet,
+			path:       "/v1/lookup?ip=192.168.1.1",
+			wantStatus: http.StatusOK,
+			want:       `"match":true`,
+		},
+		{
+			name:       "Lookup miss",
+			method:     http.MethodGet,
+			path:       "/v1/lookup?ip=172.16.0.1",
+			wantStatus: http.StatusOK,
+			want:       `"match":false,"cidrs":[]`,
+		},
+		{
+			name:       "Lookup invalid IP",
+			method:     http.MethodGet,
+			path:       "/v1/lookup?ip=bogus",
+			wantStatus: http.StatusBadRequest,
+			want:       `"error":"invalid IP address: bogus"`,
+		},
+		{
+			name:       "Merge",
+			method:     http.MethodPost,
+			path:       "/v1/merge",
+			body:       `["172.16.0.0/13", "172.24.0.0/13"]`,
+			wantStatus: http.StatusOK,
+			want:       `"cidr":"172.16.0.0/12"`,
+		},
+		{
+			name:       "Merge invalid body",
+			method:     http.MethodPost,
+			path:       "/v1/merge",
+			body:       `{"bogus": true}`,
+			wantStatus: http.StatusBadRequest,
+			want:       `"error"`,
+		},
+		{
+			name:       "Pick",
+			method:     http.MethodGet,
+			path:       "/v1/pick?key=web-1&method=consistent",
+			wantStatus: http.StatusOK,
+			want:       `"key":"web-1","ip":"`,
+		},
+		{
+			name:       "Pick without key",
+			method:     http.MethodGet,
+			path:       "/v1/pick",
+			wantStatus: http.StatusBadRequest,
+			want:       `"error":"missing key parameter"`,
+		},
+		{
+			name:       "Pick unknown method",
+			method:     http.MethodGet,
+			path:       "/v1/pick?key=web-1&method=random",
+			wantStatus: http.StatusBadRequest,
+			want:       `"error"`,
+		},
+		{
+			name:       "Wrong method",
+			method:     http.MethodPost,
+			path:       "/v1/cidrs",
+			wantStatus: http.StatusMethodNotAllowed,
+			want:       `"error"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader(tt.body))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request error = %v", err)
+			}
+			defer resp.Body.Close()
+			var body json.RawMessage
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("invalid JSON response: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if !strings.Contains(string(body), tt.want) {
+				t.Errorf("body = %s, want it to contain %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerWithClient(t *testing.T) {
+	cidrs, _ := parseCIDRList(strings.NewReader("10.0.0.0/8\n"))
+	ts := httptest.NewServer(newServer(cidrs).handler())
+	defer ts.Close()
+
+	result, err := client.New(ts.URL).Lookup(context.Background(), "10.1.2.3")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if !result.Match || len(result.CIDRs) != 1 || result.CIDRs[0].Last != "10.255.255.255" {
+		t.Errorf("Lookup() = %+v", result)
+	}
+
+	// The server picks the address a local Picker for the set picks.
+	picker, _ := client.NewPicker(client.CIDRSet(cidrs), client.Consistent)
+	picked, err := client.New(ts.URL).Pick(context.Background(), "web-1", client.Consistent)
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if want := picker.Pick("web-1").String(); picked.IP != want || picked.Method != "consistent" {
+		t.Errorf("Pick() = %+v, want %s", picked, want)
+	}
+}
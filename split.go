@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"text/template"
+
+	"D/Pratik/Code/cidr-converter/client"
+)
+
+// subnetName holds the fields available to -name templates.
+type subnetName struct {
+	Env     string
+	Index   int
+	Label   string
+	CIDR    string
+	Network string
+	Prefix  int
+}
+
+// namedSubnet is a subnet of split output with its rendered label and name.
+type namedSubnet struct {
+	Label string `json:"label,omitempty"`
+	Name  string `json:"name,omitempty"`
+	CIDR  string `json:"cidr"`
+}
+
+// Label styles of split output.
+const (
+	labelIndex = "index"
+	labelHex   = "hex"
+	labelVLAN  = "vlan"
+)
+
+// maxVLAN is the highest usable VLAN ID; 0 and 4095 are reserved.
+const maxVLAN = 4094
+
+// subnetLabeler numbers the subnets of a split starting at base: index
+// labels count them, hex labels give the address offset of each subnet
+// within the split block (plus base) in hexadecimal, and vlan labels are
+// VLAN IDs, which must stay within 1-4094.
+type subnetLabeler struct {
+	style   string
+	base    int
+	network *big.Int
+	digits  int
+}
+
+// defaultLabelBase returns the base of style when -base is not given: VLAN
+// IDs start at 1, the others at 0.
+func defaultLabelBase(style string) int {
+	if style == labelVLAN {
+		return 1
+	}
+	return 0
+}
+
+// newSubnetLabeler returns a labeler for the subnets of cidr.
+func newSubnetLabeler(style string, base int, cidr *net.IPNet) (*subnetLabeler, error) {
+	switch style {
+	case labelIndex, labelHex:
+		if base < 0 {
+			return nil, fmt.Errorf("invalid label base: %d", base)
+		}
+	case labelVLAN:
+		if base < 1 || base > maxVLAN {
+			return nil, fmt.Errorf("invalid VLAN base %d, expected 1-%d", base, maxVLAN)
+		}
+	default:
+		return nil, fmt.Errorf("unknown label style %q, expected index, hex or vlan", style)
+	}
+	ones, bits := cidr.Mask.Size()
+	return &subnetLabeler{
+		style:   style,
+		base:    base,
+		network: new(big.Int).SetBytes(cidr.IP.Mask(cidr.Mask)),
+		digits:  (bits - ones + 3) / 4,
+	}, nil
+}
+
+// label returns the label of the index-th subnet.
+func (l *subnetLabeler) label(index int, subnet *net.IPNet) (string, error) {
+	switch l.style {
+	case labelHex:
+		offset := new(big.Int).Sub(new(big.Int).SetBytes(subnet.IP.Mask(subnet.Mask)), l.network)
+		offset.Add(offset, big.NewInt(int64(l.base)))
+		return fmt.Sprintf("0x%0*x", l.digits, offset), nil
+	case labelVLAN:
+		if id := l.base + index; id > maxVLAN {
+			return "", fmt.Errorf("subnet %s would get VLAN %d, beyond %d", subnet, id, maxVLAN)
+		}
+	}
+	return fmt.Sprint(l.base + index), nil
+}
+
+// parseNameTemplate parses a -name template such as
+// "{{.Env}}-{{.Index}}-{{.CIDR}}". Referencing an unknown field is an
+// error at execution time.
+func parseNameTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// nameSubnet renders the name of the index-th subnet with tmpl.
+func nameSubnet(tmpl *template.Template, env string, index int, label string, subnet *net.IPNet) (string, error) {
+	ones, _ := subnet.Mask.Size()
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, subnetName{
+		Env:     env,
+		Index:   index,
+		Label:   label,
+		CIDR:    subnet.String(),
+		Network: subnet.IP.String(),
+		Prefix:  ones,
+	})
+	if err != nil {
+		return "", fmt.Errorf("invalid name template: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// splitSubnets splits cidr into blocks of prefix length newPrefix,
+// labeling each with labels and naming it with tmpl when they are not nil,
+// and calls emit for each one in order.
+func splitSubnets(cidr *net.IPNet, newPrefix int, labels *subnetLabeler, tmpl *template.Template, env string, emit func(namedSubnet) error) error {
+	index := 0
+	var emitErr error
+	err := client.Subnets(cidr, newPrefix, func(subnet *net.IPNet) bool {
+		named := namedSubnet{CIDR: subnet.String()}
+		if labels != nil {
+			if named.Label, emitErr = labels.label(index, subnet); emitErr != nil {
+				return false
+			}
+		}
+		if tmpl != nil {
+			if named.Name, emitErr = nameSubnet(tmpl, env, index, named.Label, subnet); emitErr != nil {
+				return false
+			}
+		}
+		index++
+		emitErr = emit(named)
+		return emitErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return emitErr
+}
+
+// runSplit implements the "split" command.
+func runSplit(args []string) error {
+	fs := flag.NewFlagSet("split", flag.ContinueOnError)
+	prefix := fs.Int("prefix", 0, "prefix length of the subnets")
+	name := fs.String("name", "", "template naming every subnet, e.g. \"{{.Env}}-{{.Index}}-{{.CIDR}}\"")
+	env := fs.String("env", "", "value of {{.Env}} in the name template")
+	labelStyle := fs.String("label", "", "label every subnet with its number: index, hex (address offset) or vlan")
+	base := fs.Int("base", 0, "first label number; defaults to 1 for vlan and 0 otherwise")
+	format := fs.String("output-format", "text", "output format: text, json or csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *prefix == 0 {
+		return fmt.Errorf("usage: split -prefix <len> [-label index|hex|vlan [-base <n>]] [-name <template>] [-env <env>] [--output-format=text|json|csv] <cidr>")
+	}
+	if *format != "text" && *format != "json" && *format != "csv" {
+		return fmt.Errorf("unknown output format: %s", *format)
+	}
+	cidr, err := parseCIDR(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var labels *subnetLabeler
+	if *labelStyle != "" {
+		baseSet := false
+		fs.Visit(func(f *flag.Flag) { baseSet = baseSet || f.Name == "base" })
+		if !baseSet {
+			*base = defaultLabelBase(*labelStyle)
+		}
+		if labels, err = newSubnetLabeler(*labelStyle, *base, cidr); err != nil {
+			return err
+		}
+	}
+	var tmpl *template.Template
+	if *name != "" {
+		if tmpl, err = parseNameTemplate(*name); err != nil {
+			return err
+		}
+	}
+
+	if *format == "json" {
+		named := []namedSubnet{}
+		if err := splitSubnets(cidr, *prefix, labels, tmpl, *env, func(n namedSubnet) error {
+			named = append(named, n)
+			return nil
+		}); err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(named)
+	}
+	if *format == "csv" {
+		return writeSubnetsCSV(os.Stdout, labels != nil, tmpl != nil, func(emit func(namedSubnet) error) error {
+			return splitSubnets(cidr, *prefix, labels, tmpl, *env, emit)
+		})
+	}
+	return splitSubnets(cidr, *prefix, labels, tmpl, *env, func(n namedSubnet) error {
+		return writeNamedSubnet(os.Stdout, n)
+	})
+}
+
+// writeNamedSubnet writes a subnet of text output: the label and name, if
+// any, followed by the block.
+func writeNamedSubnet(w io.Writer, n namedSubnet) error {
+	var err error
+	switch {
+	case n.Label != "" && n.Name != "":
+		_, err = fmt.Fprintf(w, "%s %s %s\n", n.Label, n.Name, n.CIDR)
+	case n.Label != "":
+		_, err = fmt.Fprintf(w, "%s %s\n", n.Label, n.CIDR)
+	case n.Name != "":
+		_, err = fmt.Fprintf(w, "%s %s\n", n.Name, n.CIDR)
+	default:
+		_, err = fmt.Fprintln(w, n.CIDR)
+	}
+	return err
+}
+
+// writeSubnetsCSV writes the subnets produced by split as CSV rows under a
+// header, with label and name columns when they are used.
+func writeSubnetsCSV(w io.Writer, labeled, named bool, split func(emit func(namedSubnet) error) error) error {
+	cw := csv.NewWriter(w)
+	row := func(label, name, cidr string) []string {
+		var fields []string
+		if labeled {
+			fields = append(fields, label)
+		}
+		if named {
+			fields = append(fields, name)
+		}
+		return append(fields, cidr)
+	}
+	if err := cw.Write(row("label", "name", "cidr")); err != nil {
+		return err
+	}
+	if err := split(func(n namedSubnet) error {
+		return cw.Write(row(n.Label, n.Name, n.CIDR))
+	}); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
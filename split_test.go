@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSplitSubnets(t *testing.T) {
+	tests := []struct {
+		name     string
+		cidr     string
+		prefix   int
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:   "Without names",
+			cidr:   "10.0.0.0/23",
+			prefix: 24,
+			want:   "10.0.0.0/24\n10.0.1.0/24\n",
+		},
+		{
+			name:     "Named",
+			cidr:     "10.0.0.0/23",
+			prefix:   24,
+			template: "{{.Env}}-{{.Index}}-{{.CIDR}}",
+			want:     "prod-0-10.0.0.0/24 10.0.0.0/24\nprod-1-10.0.1.0/24 10.0.1.0/24\n",
+		},
+		{
+			name:     "Network and prefix fields",
+			cidr:     "10.0.0.0/24",
+			prefix:   25,
+			template: "subnet_{{.Network}}_{{.Prefix}}",
+			want:     "subnet_10.0.0.0_25 10.0.0.0/25\nsubnet_10.0.0.128_25 10.0.0.128/25\n",
+		},
+		{
+			name:     "Unknown field",
+			cidr:     "10.0.0.0/24",
+			prefix:   25,
+			template: "{{.Region}}",
+			wantErr:  true,
+		},
+		{
+			name:    "Prefix too short",
+			cidr:    "10.0.0.0/24",
+			prefix:  16,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cidr, _ := parseCIDR(tt.cidr)
+			tmpl, err := parseNameTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("parseNameTemplate() error = %v", err)
+			}
+			if tt.template == "" {
+				tmpl = nil
+			}
+			var buf bytes.Buffer
+			err = splitSubnets(cidr, tt.prefix, nil, tmpl, "prod", func(n namedSubnet) error {
+				return writeNamedSubnet(&buf, n)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("splitSubnets() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && buf.String() != tt.want {
+				t.Errorf("splitSubnets() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNameTemplate(t *testing.T) {
+	if _, err := parseNameTemplate("{{.Env"); err == nil || !strings.Contains(err.Error(), "invalid name template") {
+		t.Errorf("parseNameTemplate() error = %v", err)
+	}
+}
+
+func TestSplitSubnetsLabeled(t *testing.T) {
+	tests := []struct {
+		name     string
+		cidr     string
+		prefix   int
+		style    string
+		base     int
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:   "Index",
+			cidr:   "10.0.0.0/23",
+			prefix: 24,
+			style:  labelIndex,
+			base:   1,
+			want:   "1 10.0.0.0/24\n2 10.0.1.0/24\n",
+		},
+		{
+			name:   "Hex offset",
+			cidr:   "10.0.0.0/22",
+			prefix: 24,
+			style:  labelHex,
+			want:   "0x000 10.0.0.0/24\n0x100 10.0.1.0/24\n0x200 10.0.2.0/24\n0x300 10.0.3.0/24\n",
+		},
+		{
+			name:   "IPv6 hex offset",
+			cidr:   "2001:db8::/47",
+			prefix: 48,
+			style:  labelHex,
+			want:   "0x000000000000000000000 2001:db8::/48\n0x100000000000000000000 2001:db8:1::/48\n",
+		},
+		{
+			name:     "VLAN with name",
+			cidr:     "10.0.0.0/23",
+			prefix:   24,
+			style:    labelVLAN,
+			base:     100,
+			template: "vlan{{.Label}}",
+			want:     "100 vlan100 10.0.0.0/24\n101 vlan101 10.0.1.0/24\n",
+		},
+		{
+			name:    "VLAN beyond 4094",
+			cidr:    "10.0.0.0/23",
+			prefix:  24,
+			style:   labelVLAN,
+			base:    4094,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cidr, _ := parseCIDR(tt.cidr)
+			labels, err := newSubnetLabeler(tt.style, tt.base, cidr)
+			if err != nil {
+				t.Fatalf("newSubnetLabeler() error = %v", err)
+			}
+			tmpl, _ := parseNameTemplate(tt.template)
+			if tt.template == "" {
+				tmpl = nil
+			}
+			var buf bytes.Buffer
+			err = splitSubnets(cidr, tt.prefix, labels, tmpl, "", func(n namedSubnet) error {
+				return writeNamedSubnet(&buf, n)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("splitSubnets() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && buf.String() != tt.want {
+				t.Errorf("splitSubnets() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSubnetLabelerErrors(t *testing.T) {
+	cidr, _ := parseCIDR("10.0.0.0/16")
+	for _, tt := range []struct {
+		style string
+		base  int
+	}{
+		{"octal", 0},
+		{labelIndex, -1},
+		{labelVLAN, 0},
+		{labelVLAN, 4095},
+	} {
+		if _, err := newSubnetLabeler(tt.style, tt.base, cidr); err == nil {
+			t.Errorf("newSubnetLabeler(%q, %d) succeeded", tt.style, tt.base)
+		}
+	}
+}
+
+func TestWriteSubnetsCSV(t *testing.T) {
+	cidr, _ := parseCIDR("10.0.0.0/23")
+	labels, _ := newSubnetLabeler(labelVLAN, 10, cidr)
+	var buf bytes.Buffer
+	err := writeSubnetsCSV(&buf, true, false, func(emit func(namedSubnet) error) error {
+		return splitSubnets(cidr, 24, labels, nil, "", emit)
+	})
+	if err != nil {
+		t.Fatalf("writeSubnetsCSV() error = %v", err)
+	}
+	if want := "label,cidr\n10,10.0.0.0/24\n11,10.0.1.0/24\n"; buf.String() != want {
+		t.Errorf("writeSubnetsCSV() = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cidrStore is a shared backend holding the canonical merged set, encoded
+// as the versioned JSON document.
+type cidrStore interface {
+	// Load returns the stored set, or an empty set when nothing is stored.
+	Load(ctx context.Context) ([]*net.IPNet, error)
+	// Save replaces the stored set.
+	Save(ctx context.Context, cidrs []*net.IPNet) error
+	// Watch calls onChange with the new set every time it changes, until
+	// ctx is cancelled.
+	Watch(ctx context.Context, onChange func([]*net.IPNet)) error
+}
+
+// storeRetryDelay is how long watchers wait before reconnecting after an
+// error.
+var storeRetryDelay = time.Second
+
+// openStore returns the backend for a URL of the form
+// consul://host:port/key/path or etcd://host:port/key/path, talking to it
+// with client.
+func openStore(rawURL string, client *http.Client) (cidrStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store URL: %v", err)
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return nil, fmt.Errorf("invalid store URL, expected scheme://host:port/key: %s", rawURL)
+	}
+	base := "http://" + u.Host
+	switch u.Scheme {
+	case "consul":
+		return &consulStore{baseURL: base, key: key, client: client}, nil
+	case "etcd":
+		return &etcdStore{baseURL: base, key: key, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown store type: %s", u.Scheme)
+	}
+}
+
+// encodeStoreValue encodes a set the way it is kept in a store.
+func encodeStoreValue(cidrs []*net.IPNet) ([]byte, error) {
+	return json.Marshal(newCIDROutput(cidrs))
+}
+
+// decodeStoreValue decodes a stored value. An empty value is an empty set.
+func decodeStoreValue(value []byte) ([]*net.IPNet, error) {
+	if len(bytes.TrimSpace(value)) == 0 {
+		return []*net.IPNet{}, nil
+	}
+	return parseCIDRJSON(bytes.NewReader(value))
+}
+
+// sleepContext waits for d or until ctx is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// consulStore keeps the set in a Consul KV key.
+type consulStore struct {
+	baseURL string
+	key     string
+	client  *http.Client
+}
+
+// get fetches the key, blocking until its index moves past index when index
+// is non-zero.
+func (s *consulStore) get(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	u := fmt.Sprintf("%s/v1/kv/%s?raw", s.baseURL, s.key)
+	if index > 0 {
+		u += fmt.Sprintf("&index=%d&wait=5m", index)
+		ctx = withoutHTTPTimeout(ctx)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading from consul: %v", err)
+	}
+	defer resp.Body.Close()
+
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		value, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error reading from consul: %v", err)
+		}
+		return value, newIndex, nil
+	case http.StatusNotFound:
+		return nil, newIndex, nil
+	default:
+		return nil, 0, fmt.Errorf("error reading from consul: %s", resp.Status)
+	}
+}
+
+func (s *consulStore) Load(ctx context.Context) ([]*net.IPNet, error) {
+	value, _, err := s.get(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStoreValue(value)
+}
+
+func (s *consulStore) Save(ctx context.Context, cidrs []*net.IPNet) error {
+	value, err := encodeStoreValue(cidrs)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, fmt.Sprintf("%s/v1/kv/%s", s.baseURL, s.key), bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error writing to consul: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error writing to consul: %s", resp.Status)
+	}
+	return nil
+}
+
+// Watch uses Consul blocking queries to wait for changes of the key.
+func (s *consulStore) Watch(ctx context.Context, onChange func([]*net.IPNet)) error {
+	_, index, err := s.get(ctx, 0)
+	for {
+		if err != nil {
+			log.Printf("consul watch: %v", err)
+			if err := sleepContext(ctx, storeRetryDelay); err != nil {
+				return err
+			}
+		}
+		var value []byte
+		var newIndex uint64
+		value, newIndex, err = s.get(ctx, index)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil || newIndex == index {
+			continue
+		}
+		// Consul may reset the index; start over from the new one.
+		index = newIndex
+		cidrs, decodeErr := decodeStoreValue(value)
+		if decodeErr != nil {
+			log.Printf("consul watch: %v", decodeErr)
+			continue
+		}
+		onChange(cidrs)
+	}
+}
+
+// etcdStore keeps the set in an etcd key, using the v3 JSON gateway.
+type etcdStore struct {
+	baseURL string
+	key     string
+	client  *http.Client
+}
+
+// etcdKV is a key-value pair as returned by the gateway, base64 encoded.
+type etcdKV struct {
+	Value       string `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+// post sends a JSON request to a gateway endpoint.
+func (s *etcdStore) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error contacting etcd: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error contacting etcd: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// get returns the value of the key and the store revision it was read at.
+func (s *etcdStore) get(ctx context.Context) ([]byte, int64, error) {
+	resp, err := s.post(ctx, "/v3/kv/range", map[string]string{
+		"key": base64.StdEncoding.EncodeToString([]byte(s.key)),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Header struct {
+			Revision string `json:"revision"`
+		} `json:"header"`
+		KVs []etcdKV `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, 0, fmt.Errorf("error decoding etcd response: %v", err)
+	}
+	revision, _ := strconv.ParseInt(result.Header.Revision, 10, 64)
+	if len(result.KVs) == 0 {
+		return nil, revision, nil
+	}
+	value, err := base64.StdEncoding.DecodeString(result.KVs[0].Value)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error decoding etcd value: %v", err)
+	}
+	return value, revision, nil
+}
+
+func (s *etcdStore) Load(ctx context.Context) ([]*net.IPNet, error) {
+	value, _, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStoreValue(value)
+}
+
+func (s *etcdStore) Save(ctx context.Context, cidrs []*net.IPNet) error {
+	value, err := encodeStoreValue(cidrs)
+	if err != nil {
+		return err
+	}
+	resp, err := s.post(ctx, "/v3/kv/put", map[string]string{
+		"key":   base64.StdEncoding.EncodeToString([]byte(s.key)),
+		"value": base64.StdEncoding.EncodeToString(value),
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Watch streams etcd watch events for the key, reconnecting from the last
+// seen revision when the stream ends. It waits storeRetryDelay before every
+// reconnection, also after a stream ended cleanly, so a gateway that keeps
+// closing streams does not cause a tight loop.
+func (s *etcdStore) Watch(ctx context.Context, onChange func([]*net.IPNet)) error {
+	_, revision, err := s.get(ctx)
+	if err != nil {
+		log.Printf("etcd watch: %v", err)
+		if err := sleepContext(ctx, storeRetryDelay); err != nil {
+			return err
+		}
+	}
+	for {
+		revision, err = s.watchStream(ctx, revision, onChange)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("etcd watch: %v", err)
+		}
+		if err := sleepContext(ctx, storeRetryDelay); err != nil {
+			return err
+		}
+	}
+}
+
+// watchStream consumes one watch stream starting after revision and returns
+// the last revision seen.
+func (s *etcdStore) watchStream(ctx context.Context, revision int64, onChange func([]*net.IPNet)) (int64, error) {
+	resp, err := s.post(withoutHTTPTimeout(ctx), "/v3/watch", map[string]interface{}{
+		"create_request": map[string]string{
+			"key":            base64.StdEncoding.EncodeToString([]byte(s.key)),
+			"start_revision": strconv.FormatInt(revision+1, 10),
+		},
+	})
+	if err != nil {
+		return revision, err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Result struct {
+				Events []struct {
+					Type string `json:"type"`
+					KV   etcdKV `json:"kv"`
+				} `json:"events"`
+			} `json:"result"`
+		}
+		if err := decoder.Decode(&message); err != nil {
+			if err == io.EOF {
+				return revision, nil
+			}
+			return revision, fmt.Errorf("error reading etcd watch stream: %v", err)
+		}
+		for _, event := range message.Result.Events {
+			if rev, err := strconv.ParseInt(event.KV.ModRevision, 10, 64); err == nil && rev > revision {
+				revision = rev
+			}
+			var value []byte
+			if event.Type != "DELETE" {
+				value, err = base64.StdEncoding.DecodeString(event.KV.Value)
+				if err != nil {
+					log.Printf("etcd watch: error decoding value: %v", err)
+					continue
+				}
+			}
+			cidrs, err := decodeStoreValue(value)
+			if err != nil {
+				log.Printf("etcd watch: %v", err)
+				continue
+			}
+			onChange(cidrs)
+		}
+	}
+}
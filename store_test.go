@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpenStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "Consul", input: "consul://127.0.0.1:8500/cidr/allow"},
+		{name: "Etcd", input: "etcd://127.0.0.1:2379/cidr/allow"},
+		{name: "Missing key", input: "consul://127.0.0.1:8500/", wantErr: true},
+		{name: "Unknown scheme", input: "redis://127.0.0.1:6379/cidrs", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openStore(tt.input, http.DefaultClient)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("openStore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeConsul implements the parts of the Consul KV API used by consulStore,
+// including blocking queries.
+type fakeConsul struct {
+	mu      sync.Mutex
+	value   []byte
+	index   uint64
+	changed chan struct{}
+}
+
+func newFakeConsul() *fakeConsul {
+	return &fakeConsul{index: 1, changed: make(chan struct{})}
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		body, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.value = body
+		f.index++
+		close(f.changed)
+		f.changed = make(chan struct{})
+		f.mu.Unlock()
+		io.WriteString(w, "true")
+		return
+	}
+
+	f.mu.Lock()
+	if index := r.URL.Query().Get("index"); index == fmt.Sprint(f.index) {
+		changed := f.changed
+		f.mu.Unlock()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		f.mu.Lock()
+	}
+	defer f.mu.Unlock()
+	w.Header().Set("X-Consul-Index", fmt.Sprint(f.index))
+	if f.value == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write(f.value)
+}
+
+// fakeEtcd implements the parts of the etcd v3 JSON gateway used by
+// etcdStore. Watch streams deliver a single event for the next put.
+type fakeEtcd struct {
+	mu       sync.Mutex
+	value    []byte
+	revision int64
+	changed  chan struct{}
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{revision: 1, changed: make(chan struct{})}
+}
+
+func (f *fakeEtcd) kv() map[string]string {
+	return map[string]string{
+		"value":        base64.StdEncoding.EncodeToString(f.value),
+		"mod_revision": fmt.Sprint(f.revision),
+	}
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&req)
+	switch r.URL.Path {
+	case "/v3/kv/put":
+		value, _ := base64.StdEncoding.DecodeString(req["value"].(string))
+		f.mu.Lock()
+		f.value = value
+		f.revision++
+		close(f.changed)
+		f.changed = make(chan struct{})
+		f.mu.Unlock()
+		io.WriteString(w, `{}`)
+	case "/v3/kv/range":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		resp := map[string]interface{}{"header": map[string]string{"revision": fmt.Sprint(f.revision)}}
+		if f.value != nil {
+			resp["kvs"] = []map[string]string{f.kv()}
+		}
+		json.NewEncoder(w).Encode(resp)
+	case "/v3/watch":
+		f.mu.Lock()
+		changed := f.changed
+		f.mu.Unlock()
+		io.WriteString(w, `{"result":{"created":true}}`+"\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		f.mu.Lock()
+		event := map[string]interface{}{"result": map[string]interface{}{
+			"events": []map[string]interface{}{{"kv": f.kv()}},
+		}}
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(event)
+	}
+}
+
+func TestEtcdWatchWaitsAfterStreamEnd(t *testing.T) {
+	var mu sync.Mutex
+	streams := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			io.WriteString(w, `{"header":{"revision":"1"}}`)
+		case "/v3/watch":
+			// End every stream at once.
+			mu.Lock()
+			streams++
+			mu.Unlock()
+		}
+	}))
+	defer ts.Close()
+	defer func(delay time.Duration) { storeRetryDelay = delay }(storeRetryDelay)
+	storeRetryDelay = 50 * time.Millisecond
+
+	store, err := openStore("etcd://"+strings.TrimPrefix(ts.URL, "http://")+"/cidr/allow", http.DefaultClient)
+	if err != nil {
+		t.Fatalf("openStore() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 220*time.Millisecond)
+	defer cancel()
+	store.Watch(ctx, func([]*net.IPNet) {})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if streams < 2 || streams > 6 {
+		t.Errorf("Watch() opened %d streams in 220ms with a 50ms retry delay", streams)
+	}
+}
+
+func TestStores(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.Handler
+		scheme  string
+	}{
+		{name: "Consul", handler: newFakeConsul(), scheme: "consul"},
+		{name: "Etcd", handler: newFakeEtcd(), scheme: "etcd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(tt.handler)
+			defer ts.Close()
+			store, err := openStore(tt.scheme+"://"+strings.TrimPrefix(ts.URL, "http://")+"/cidr/allow", http.DefaultClient)
+			if err != nil {
+				t.Fatalf("openStore() error = %v", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			empty, err := store.Load(ctx)
+			if err != nil || len(empty) != 0 {
+				t.Fatalf("Load() of missing key = %v, %v", empty, err)
+			}
+
+			cidrs, _ := parseCIDRList(strings.NewReader("10.0.0.0/8\n"))
+			if err := store.Save(ctx, cidrs); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			loaded, err := store.Load(ctx)
+			if err != nil || joinCIDRs(loaded) != "10.0.0.0/8" {
+				t.Fatalf("Load() = %v, %v", loaded, err)
+			}
+
+			changes := make(chan string, 1)
+			watchCtx, stopWatch := context.WithCancel(ctx)
+			defer stopWatch()
+			go store.Watch(watchCtx, func(cidrs []*net.IPNet) {
+				changes <- joinCIDRs(cidrs)
+			})
+			// Give the watcher time to start its blocking request.
+			time.Sleep(100 * time.Millisecond)
+
+			updated, _ := parseCIDRList(strings.NewReader("10.0.0.0/8\n192.168.0.0/16\n"))
+			if err := store.Save(ctx, updated); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			select {
+			case got := <-changes:
+				if got != "10.0.0.0/8,192.168.0.0/16" {
+					t.Errorf("Watch() reported %q", got)
+				}
+			case <-ctx.Done():
+				t.Fatalf("Watch() did not report the change")
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"D/Pratik/Code/cidr-converter/client"
+)
+
+// probeFunc reports whether the host at ip answered within timeout.
+type probeFunc func(ip net.IP, timeout time.Duration) bool
+
+// tcpProbe returns a probe that attempts a TCP connection to port. A refused
+// connection still proves the host is up, so it counts as alive.
+func tcpProbe(port int) probeFunc {
+	return func(ip net.IP, timeout time.Duration) bool {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip.String(), strconv.Itoa(port)), timeout)
+		if err != nil {
+			return errors.Is(err, syscall.ECONNREFUSED)
+		}
+		conn.Close()
+		return true
+	}
+}
+
+// icmpProbe sends a single ICMP echo request and waits for the reply. It
+// needs a raw socket, which usually requires elevated privileges, and only
+// supports IPv4.
+func icmpProbe(ip net.IP, timeout time.Duration) bool {
+	conn, err := net.DialTimeout("ip4:icmp", ip.String(), timeout)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	// Echo request: type 8, code 0, checksum, identifier, sequence 1.
+	id := os.Getpid() & 0xffff
+	msg := []byte{8, 0, 0, 0, byte(id >> 8), byte(id), 0, 1}
+	sum := 0
+	for i := 0; i < len(msg); i += 2 {
+		sum += int(msg[i])<<8 | int(msg[i+1])
+	}
+	sum = (sum >> 16) + (sum & 0xffff)
+	sum = ^(sum + (sum >> 16)) & 0xffff
+	msg[2], msg[3] = byte(sum>>8), byte(sum)
+	if _, err := conn.Write(msg); err != nil {
+		return false
+	}
+
+	reply := make([]byte, 1500)
+	for {
+		n, err := conn.Read(reply)
+		if err != nil {
+			return false
+		}
+		// Echo reply with our identifier.
+		if n >= 8 && reply[0] == 0 && int(reply[4])<<8|int(reply[5]) == id {
+			return true
+		}
+	}
+}
+
+// hostAddresses lists the addresses of cidr to probe. The network and
+// broadcast addresses of IPv4 blocks larger than /31 are skipped. It fails
+// when the block holds more than maxHosts addresses.
+func hostAddresses(cidr *net.IPNet, maxHosts int64) ([]net.IP, error) {
+	size := cidrSize(cidr)
+	if size.Cmp(big.NewInt(maxHosts)) > 0 {
+		return nil, fmt.Errorf("%s has %s addresses, more than the limit of %d", cidr, size, maxHosts)
+	}
+	hosts := []net.IP{}
+	client.IPNetAddrs(cidr, func(ip net.IP) bool {
+		hosts = append(hosts, ip)
+		return true
+	})
+	ones, bits := cidr.Mask.Size()
+	if bits == 32 && bits-ones > 1 {
+		hosts = hosts[1 : len(hosts)-1]
+	}
+	return hosts, nil
+}
+
+// sweepHosts probes hosts using up to concurrency workers and returns the
+// addresses that answered, sorted.
+func sweepHosts(hosts []net.IP, probe probeFunc, concurrency int, timeout time.Duration) []net.IP {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	jobs := make(chan net.IP)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	alive := []net.IP{}
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range jobs {
+				if probe(ip, timeout) {
+					mu.Lock()
+					alive = append(alive, ip)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, ip := range hosts {
+		jobs <- ip
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(alive, func(i, j int) bool {
+		return ipToInt(alive[i]).Cmp(ipToInt(alive[j])) < 0
+	})
+	return alive
+}
+
+// runSweep implements the "sweep" command.
+func runSweep(args []string) error {
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	method := fs.String("method", "tcp", "probe method: tcp or icmp")
+	port := fs.Int("port", 80, "port for TCP probes")
+	concurrency := fs.Int("concurrency", 64, "number of concurrent probes")
+	timeout := fs.Duration("timeout", time.Second, "timeout per probe")
+	maxHosts := fs.Int64("max-hosts", 65536, "refuse to sweep blocks with more addresses than this")
+	summarize := fs.Bool("summarize", false, "summarize live hosts into CIDR blocks")
+	resolve := fs.Bool("resolve", false, "look up the reverse DNS name of every live host")
+	cacheFile := fs.String("cache", "", "file caching reverse DNS results; the user cache directory when empty")
+	cacheTTL := fs.Duration("cache-ttl", 24*time.Hour, "how long cached reverse DNS results are used, 0 to disable the cache")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: sweep [flags] <cidr>")
+	}
+	cidr, err := parseCIDR(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var probe probeFunc
+	switch *method {
+	case "tcp":
+		probe = tcpProbe(*port)
+	case "icmp":
+		if cidr.IP.To4() == nil {
+			return fmt.Errorf("-method icmp only supports IPv4 blocks, use -method tcp for %s", cidr)
+		}
+		probe = icmpProbe
+	default:
+		return fmt.Errorf("unknown probe method: %s", *method)
+	}
+
+	hosts, err := hostAddresses(cidr, *maxHosts)
+	if err != nil {
+		return err
+	}
+	alive := sweepHosts(hosts, probe, *concurrency, *timeout)
+
+	var names map[string]string
+	if *resolve {
+		var cache *enrichCache
+		if *cacheTTL > 0 {
+			path := *cacheFile
+			if path == "" {
+				if path, err = defaultCachePath(); err != nil {
+					return err
+				}
+			}
+			if cache, err = openEnrichCache(path); err != nil {
+				return err
+			}
+		}
+		names = reverseNames(alive, cache, *cacheTTL, net.LookupAddr)
+		if cache != nil {
+			if err := cache.save(); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Printf("%d of %d hosts alive:\n", len(alive), len(hosts))
+	for _, ip := range alive {
+		if name := names[ip.String()]; name != "" {
+			fmt.Println(ip, name)
+		} else {
+			fmt.Println(ip)
+		}
+	}
+	if *summarize {
+		var hostCIDRs []*net.IPNet
+		for _, ip := range alive {
+			hostCIDRs = append(hostCIDRs, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		}
+		fmt.Println("\nSummarized live hosts:")
+		for _, c := range collapseCIDRs(hostCIDRs) {
+			fmt.Println(c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHostAddresses(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "Skips network and broadcast", input: "192.168.0.0/30", want: []string{"192.168.0.1", "192.168.0.2"}},
+		{name: "Point-to-point /31", input: "192.168.0.0/31", want: []string{"192.168.0.0", "192.168.0.1"}},
+		{name: "Too large", input: "10.0.0.0/8", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cidr, _ := net.ParseCIDR(tt.input)
+			got, err := hostAddresses(cidr, 65536)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("hostAddresses() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("hostAddresses() returned %d hosts, want %d", len(got), len(tt.want))
+			}
+			for i, ip := range got {
+				if ip.String() != tt.want[i] {
+					t.Errorf("hostAddresses()[%d] = %v, want %v", i, ip, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSweepHosts(t *testing.T) {
+	_, cidr, _ := net.ParseCIDR("10.0.0.0/28")
+	hosts, _ := hostAddresses(cidr, 16)
+	probe := func(ip net.IP, timeout time.Duration) bool {
+		return ip[len(ip)-1]%4 == 0
+	}
+
+	alive := sweepHosts(hosts, probe, 4, time.Millisecond)
+	want := []string{"10.0.0.4", "10.0.0.8", "10.0.0.12"}
+	if len(alive) != len(want) {
+		t.Fatalf("sweepHosts() returned %d hosts, want %d", len(alive), len(want))
+	}
+	for i, ip := range alive {
+		if ip.String() != want[i] {
+			t.Errorf("sweepHosts()[%d] = %v, want %v", i, ip, want[i])
+		}
+	}
+}
+
+func TestTCPProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen on loopback: %v", err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	if !tcpProbe(port)(net.ParseIP("127.0.0.1"), time.Second) {
+		t.Errorf("tcpProbe() reported listening host as down")
+	}
+}
+
+func TestRunSweepRejectsICMPv6(t *testing.T) {
+	err := runSweep([]string{"-method", "icmp", "2001:db8::/126"})
+	if err == nil || !strings.Contains(err.Error(), "only supports IPv4") {
+		t.Errorf("runSweep() error = %v, want an IPv4-only error", err)
+	}
+}
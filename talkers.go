@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// flowColumns are the header names recognized for the source and
+// destination addresses of flow log and pcap-derived CSV rows: VPC flow
+// logs use srcaddr and dstaddr, tshark and most CSV exports one of the
+// others.
+var flowColumns = map[string][]string{
+	"src": {"srcaddr", "src", "src_ip", "srcip", "src_addr", "source", "ip.src"},
+	"dst": {"dstaddr", "dst", "dst_ip", "dstip", "dst_addr", "destination", "ip.dst"},
+}
+
+// talker is a block and the number of flow records with an endpoint in it.
+type talker struct {
+	CIDR *net.IPNet
+	Hits int
+}
+
+// flowEndpointColumns returns the indexes of the endpoint columns of
+// header for the given direction: "src", "dst" or "both".
+func flowEndpointColumns(header []string, direction string) ([]int, error) {
+	var columns []int
+	for _, side := range []string{"src", "dst"} {
+		if direction != "both" && direction != side {
+			continue
+		}
+		found := false
+		for i, name := range header {
+			name = strings.ToLower(strings.Trim(strings.TrimSpace(name), `"`))
+			for _, want := range flowColumns[side] {
+				if name == want && !found {
+					columns = append(columns, i)
+					found = true
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no %s address column in header", side)
+		}
+	}
+	return columns, nil
+}
+
+// countEndpoints counts the flow records of r per endpoint address. The
+// input starts with a header line and is comma-separated if the header
+// contains a comma, whitespace-separated otherwise like VPC flow logs.
+// Fields that are not addresses, such as the "-" of NODATA records, are
+// skipped and counted.
+func countEndpoints(r io.Reader, direction string) (map[string]int, int, error) {
+	br := bufio.NewReader(r)
+	headerLine, err := br.ReadString('\n')
+	if err != nil && headerLine == "" {
+		if err == io.EOF {
+			return nil, 0, fmt.Errorf("empty flow log")
+		}
+		return nil, 0, err
+	}
+	headerLine = strings.TrimRight(headerLine, "\r\n")
+
+	var next func() ([]string, error)
+	var header []string
+	if strings.Contains(headerLine, ",") {
+		header, err = csv.NewReader(strings.NewReader(headerLine)).Read()
+		if err != nil {
+			return nil, 0, fmt.Errorf("error reading header: %v", err)
+		}
+		reader := csv.NewReader(br)
+		reader.FieldsPerRecord = -1
+		next = reader.Read
+	} else {
+		header = strings.Fields(headerLine)
+		scanner := bufio.NewScanner(br)
+		next = func() ([]string, error) {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return nil, err
+				}
+				return nil, io.EOF
+			}
+			return strings.Fields(scanner.Text()), nil
+		}
+	}
+	columns, err := flowEndpointColumns(header, direction)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	counts := make(map[string]int)
+	skipped := 0
+	for {
+		fields, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("error reading flow log: %v", err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		for _, column := range columns {
+			if column >= len(fields) {
+				skipped++
+				continue
+			}
+			ip := net.ParseIP(strings.TrimSpace(fields[column]))
+			if ip == nil {
+				skipped++
+				continue
+			}
+			counts[ip.String()]++
+		}
+	}
+	return counts, skipped, nil
+}
+
+// summarizeTalkers groups the endpoint counts into blocks of prefix4 bits
+// for IPv4 and prefix6 bits for IPv6, and returns the blocks with at least
+// minHits records, busiest first.
+func summarizeTalkers(counts map[string]int, prefix4, prefix6, minHits int) []talker {
+	blocks := make(map[string]*talker)
+	for addr, hits := range counts {
+		ip := net.ParseIP(addr)
+		bits, prefix := 128, prefix6
+		if ip.To4() != nil {
+			ip, bits, prefix = ip.To4(), 32, prefix4
+		}
+		mask := net.CIDRMask(prefix, bits)
+		block := &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+		key := block.String()
+		if blocks[key] == nil {
+			blocks[key] = &talker{CIDR: block}
+		}
+		blocks[key].Hits += hits
+	}
+
+	var talkers []talker
+	for _, t := range blocks {
+		if t.Hits >= minHits {
+			talkers = append(talkers, *t)
+		}
+	}
+	sort.Slice(talkers, func(i, j int) bool {
+		if talkers[i].Hits != talkers[j].Hits {
+			return talkers[i].Hits > talkers[j].Hits
+		}
+		return ipToInt(talkers[i].CIDR.IP).Cmp(ipToInt(talkers[j].CIDR.IP)) < 0
+	})
+	return talkers
+}
+
+// runTalkers implements the "talkers" command.
+func runTalkers(args []string) error {
+	fs := flag.NewFlagSet("talkers", flag.ContinueOnError)
+	direction := fs.String("direction", "both", "endpoints to count: src, dst or both")
+	prefix4 := fs.Int("prefix4", 24, "prefix length IPv4 endpoints are grouped into")
+	prefix6 := fs.Int("prefix6", 64, "prefix length IPv6 endpoints are grouped into")
+	minHits := fs.Int("min-hits", 1, "only report blocks with at least this many records")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *direction != "src" && *direction != "dst" && *direction != "both" {
+		return fmt.Errorf("unknown direction: %s", *direction)
+	}
+	if *prefix4 < 0 || *prefix4 > 32 || *prefix6 < 0 || *prefix6 > 128 {
+		return fmt.Errorf("invalid prefix length")
+	}
+
+	readers := []io.Reader{os.Stdin}
+	if fs.NArg() > 0 {
+		readers = nil
+		for _, filename := range fs.Args() {
+			file, err := os.Open(filename)
+			if err != nil {
+				return fmt.Errorf("error opening file: %v", err)
+			}
+			defer file.Close()
+			readers = append(readers, file)
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, r := range readers {
+		fileCounts, skipped, err := countEndpoints(r, *direction)
+		if err != nil {
+			return err
+		}
+		if skipped > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: skipped %d fields that are not addresses\n", skipped)
+		}
+		for addr, hits := range fileCounts {
+			counts[addr] += hits
+		}
+	}
+
+	for _, t := range summarizeTalkers(counts, *prefix4, *prefix6, *minHits) {
+		fmt.Printf("%s %d\n", t.CIDR, t.Hits)
+	}
+	return nil
+}
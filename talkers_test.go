@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCountEndpoints(t *testing.T) {
+	vpc := "version account-id interface-id srcaddr dstaddr srcport dstport protocol packets bytes start end action log-status\n" +
+		"2 123456789010 eni-1235b8ca 10.0.1.5 198.51.100.7 49761 443 6 20 4249 1418530010 1418530070 ACCEPT OK\n" +
+		"2 123456789010 eni-1235b8ca 10.0.1.6 198.51.100.7 49762 443 6 20 4249 1418530010 1418530070 ACCEPT OK\n" +
+		"2 123456789010 eni-1235b8ca - - - - - - - 1431280876 1431280934 - NODATA\n"
+	pcap := "No.,Time,ip.src,ip.dst,Protocol\n" +
+		"1,0.000,\"10.0.1.5\",\"2001:db8::1\",TCP\n" +
+		"2,0.001,10.0.1.5,2001:db8::1,TCP\n"
+
+	tests := []struct {
+		name      string
+		input     string
+		direction string
+		want      string
+		skipped   int
+	}{
+		{name: "VPC flow log", input: vpc, direction: "both", want: "10.0.1.5=1 10.0.1.6=1 198.51.100.7=2", skipped: 2},
+		{name: "VPC flow log sources", input: vpc, direction: "src", want: "10.0.1.5=1 10.0.1.6=1", skipped: 1},
+		{name: "CSV", input: pcap, direction: "dst", want: "2001:db8::1=2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts, skipped, err := countEndpoints(strings.NewReader(tt.input), tt.direction)
+			if err != nil {
+				t.Fatalf("countEndpoints() error = %v", err)
+			}
+			var got []string
+			for addr, hits := range counts {
+				got = append(got, fmt.Sprintf("%s=%d", addr, hits))
+			}
+			sort.Strings(got)
+			if joined := strings.Join(got, " "); joined != tt.want {
+				t.Errorf("countEndpoints() = %q, want %q", joined, tt.want)
+			}
+			if skipped != tt.skipped {
+				t.Errorf("countEndpoints() skipped = %d, want %d", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestCountEndpointsHeaderErrors(t *testing.T) {
+	for _, input := range []string{"", "time,bytes\n1,2\n", "a b c\n"} {
+		if _, _, err := countEndpoints(strings.NewReader(input), "both"); err == nil {
+			t.Errorf("countEndpoints(%q) expected an error", input)
+		}
+	}
+}
+
+func TestSummarizeTalkers(t *testing.T) {
+	counts := map[string]int{
+		"10.0.1.5":     30,
+		"10.0.1.200":   20,
+		"10.0.2.1":     5,
+		"192.0.2.1":    70,
+		"2001:db8::1":  10,
+		"2001:db8::ff": 10,
+	}
+	tests := []struct {
+		name    string
+		minHits int
+		want    string
+	}{
+		{name: "All blocks", minHits: 1, want: "192.0.2.0/24 70,10.0.1.0/24 50,2001:db8::/64 20,10.0.2.0/24 5"},
+		{name: "Above the threshold", minHits: 20, want: "192.0.2.0/24 70,10.0.1.0/24 50,2001:db8::/64 20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, talker := range summarizeTalkers(counts, 24, 64, tt.minHits) {
+				got = append(got, fmt.Sprintf("%s %d", talker.CIDR, talker.Hits))
+			}
+			if joined := strings.Join(got, ","); joined != tt.want {
+				t.Errorf("summarizeTalkers() = %q, want %q", joined, tt.want)
+			}
+		})
+	}
+}
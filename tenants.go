@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// tenantsConfig is the file given to "serve -tenants". Each tenant has its
+// own set, loaded from files or a store, and optionally an API key:
+//
+//	{
+//	  "tenants": [
+//	    {"name": "payments", "key": "s3cret", "files": ["payments.txt"]},
+//	    {"name": "web", "store": "consul://127.0.0.1:8500/cidr/web"}
+//	  ]
+//	}
+type tenantsConfig struct {
+	Tenants []tenantConfig `json:"tenants"`
+}
+
+// tenantConfig describes one tenant of a tenantsConfig.
+type tenantConfig struct {
+	Name  string   `json:"name"`
+	Key   string   `json:"key,omitempty"`
+	Files []string `json:"files,omitempty"`
+	Store string   `json:"store,omitempty"`
+}
+
+// tenant is a named set served alongside others, with its own source.
+type tenant struct {
+	config tenantConfig
+	server *server
+	store  cidrStore
+}
+
+// reload replaces the tenant's set with the current content of its source.
+func (t *tenant) reload(ctx context.Context) error {
+	if t.store != nil {
+		cidrs, err := t.store.Load(ctx)
+		if err != nil {
+			return err
+		}
+		t.server.setCIDRs(cidrs)
+		return nil
+	}
+	var cidrs []*net.IPNet
+	for _, filename := range t.config.Files {
+		fileCIDRs, err := readCIDRFile(filename)
+		if err != nil {
+			return err
+		}
+		cidrs = append(cidrs, fileCIDRs...)
+	}
+	t.server.setCIDRs(cidrs)
+	return nil
+}
+
+// authorized reports whether r carries the tenant's key, as a bearer token
+// or in an X-API-Key header. Tenants without a key accept every request.
+func (t *tenant) authorized(r *http.Request) bool {
+	if t.config.Key == "" {
+		return true
+	}
+	key := requestAPIKey(r)
+	return subtle.ConstantTimeCompare([]byte(key), []byte(t.config.Key)) == 1
+}
+
+// requestAPIKey returns the API key sent with r, if any.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
+
+// tenantRouter serves the sets of several tenants. A tenant's endpoints are
+// available under /tenants/NAME/, and under the top-level paths for
+// requests whose API key belongs to it. POST /tenants/NAME/reload reloads
+// the tenant's set from its source without affecting the others.
+type tenantRouter struct {
+	tenants map[string]*tenant
+	byKey   map[string]*tenant
+}
+
+// newTenantRouter validates the configs and returns a router for them.
+// The tenants' sets are empty until reloaded.
+func newTenantRouter(configs []tenantConfig, openStore func(rawURL string) (cidrStore, error)) (*tenantRouter, error) {
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no tenants defined")
+	}
+	router := &tenantRouter{tenants: map[string]*tenant{}, byKey: map[string]*tenant{}}
+	for _, config := range configs {
+		if config.Name == "" || strings.Contains(config.Name, "/") {
+			return nil, fmt.Errorf("invalid tenant name: %q", config.Name)
+		}
+		if router.tenants[config.Name] != nil {
+			return nil, fmt.Errorf("tenant %s defined twice", config.Name)
+		}
+		if (len(config.Files) > 0) == (config.Store != "") {
+			return nil, fmt.Errorf("tenant %s: exactly one of files and store must be given", config.Name)
+		}
+		t := &tenant{config: config, server: newServer(nil)}
+		if config.Store != "" {
+			store, err := openStore(config.Store)
+			if err != nil {
+				return nil, fmt.Errorf("tenant %s: %v", config.Name, err)
+			}
+			t.store = store
+		}
+		if config.Key != "" {
+			if router.byKey[config.Key] != nil {
+				return nil, fmt.Errorf("tenant %s: key already used by tenant %s", config.Name, router.byKey[config.Key].config.Name)
+			}
+			router.byKey[config.Key] = t
+		}
+		router.tenants[config.Name] = t
+	}
+	return router, nil
+}
+
+// loadTenantsConfig reads a tenantsConfig.
+func loadTenantsConfig(filename string) ([]tenantConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %v", err)
+	}
+	var config tenantsConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: error decoding JSON: %v", filename, err)
+	}
+	return config.Tenants, nil
+}
+
+func (router *tenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/tenants/") {
+		t := router.byKey[requestAPIKey(r)]
+		if t == nil {
+			writeError(w, http.StatusUnauthorized, "missing or unknown API key")
+			return
+		}
+		t.server.handler().ServeHTTP(w, r)
+		return
+	}
+
+	name, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/tenants/"), "/")
+	t := router.tenants[name]
+	if t == nil {
+		writeError(w, http.StatusNotFound, "unknown tenant: %s", name)
+		return
+	}
+	if !t.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "missing or wrong API key for tenant %s", name)
+		return
+	}
+	if rest == "reload" {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+			return
+		}
+		if err := t.reload(r.Context()); err != nil {
+			writeError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+		log.Printf("reloaded tenant %s", name)
+		t.server.mu.RLock()
+		defer t.server.mu.RUnlock()
+		writeJSON(w, http.StatusOK, newCIDROutput(t.server.cidrs))
+		return
+	}
+	http.StripPrefix("/tenants/"+name, t.server.handler()).ServeHTTP(w, r)
+}
+
+// watchStores follows the stores of the store-backed tenants, reloading
+// each tenant's set when its key changes.
+func (router *tenantRouter) watchStores(ctx context.Context) {
+	for _, t := range router.tenants {
+		if t.store == nil {
+			continue
+		}
+		t := t
+		go func() {
+			err := t.store.Watch(ctx, func(cidrs []*net.IPNet) {
+				t.server.setCIDRs(cidrs)
+				log.Printf("reloaded tenant %s from %s", t.config.Name, t.config.Store)
+			})
+			log.Printf("tenant %s: store watch stopped: %v", t.config.Name, err)
+		}()
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// memoryStore is a cidrStore kept in memory.
+type memoryStore struct {
+	cidrs []*net.IPNet
+}
+
+func (s *memoryStore) Load(ctx context.Context) ([]*net.IPNet, error) { return s.cidrs, nil }
+
+func (s *memoryStore) Save(ctx context.Context, cidrs []*net.IPNet) error {
+	s.cidrs = cidrs
+	return nil
+}
+
+func (s *memoryStore) Watch(ctx context.Context, onChange func([]*net.IPNet)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestTenantRouter(t *testing.T) {
+	dir := t.TempDir()
+	paymentsFile := filepath.Join(dir, "payments.txt")
+	os.WriteFile(paymentsFile, []byte("10.0.0.0/8\n"), 0o644)
+	stored, _ := parseCIDRList(strings.NewReader("192.168.0.0/16\n"))
+	store := &memoryStore{cidrs: stored}
+
+	router, err := newTenantRouter([]tenantConfig{
+		{Name: "payments", Key: "pay-key", Files: []string{paymentsFile}},
+		{Name: "web", Store: "memory://web"},
+	}, func(rawURL string) (cidrStore, error) { return store, nil })
+	if err != nil {
+		t.Fatalf("newTenantRouter() error = %v", err)
+	}
+	for _, tenant := range router.tenants {
+		if err := tenant.reload(context.Background()); err != nil {
+			t.Fatalf("reload() error = %v", err)
+		}
+	}
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		key        string
+		wantStatus int
+		want       string
+	}{
+		{name: "Tenant path with key", method: http.MethodGet, path: "/tenants/payments/v1/lookup?ip=10.1.2.3", key: "pay-key", wantStatus: http.StatusOK, want: `"match":true`},
+		{name: "Tenant sets are isolated", method: http.MethodGet, path: "/tenants/payments/v1/lookup?ip=192.168.1.1", key: "pay-key", wantStatus: http.StatusOK, want: `"match":false`},
+		{name: "Tenant path without key", method: http.MethodGet, path: "/tenants/payments/v1/cidrs", wantStatus: http.StatusUnauthorized},
+		{name: "Tenant path with wrong key", method: http.MethodGet, path: "/tenants/payments/v1/cidrs", key: "nope", wantStatus: http.StatusUnauthorized},
+		{name: "Tenant without a key", method: http.MethodGet, path: "/tenants/web/v1/cidrs", wantStatus: http.StatusOK, want: `"cidr":"192.168.0.0/16"`},
+		{name: "Unknown tenant", method: http.MethodGet, path: "/tenants/hr/v1/cidrs", wantStatus: http.StatusNotFound},
+		{name: "Key selects the tenant", method: http.MethodGet, path: "/v1/cidrs", key: "pay-key", wantStatus: http.StatusOK, want: `"cidr":"10.0.0.0/8"`},
+		{name: "Top-level path without key", method: http.MethodGet, path: "/v1/cidrs", wantStatus: http.StatusUnauthorized},
+		{name: "Reload needs POST", method: http.MethodGet, path: "/tenants/web/reload", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request error = %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", resp.StatusCode, tt.wantStatus, body)
+			}
+			if !strings.Contains(string(body), tt.want) {
+				t.Errorf("body = %s, want it to contain %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	os.WriteFile(file, []byte("10.0.0.0/8\n"), 0o644)
+	otherFile := filepath.Join(dir, "b.txt")
+	os.WriteFile(otherFile, []byte("172.16.0.0/12\n"), 0o644)
+
+	router, err := newTenantRouter([]tenantConfig{
+		{Name: "a", Key: "a-key", Files: []string{file}},
+		{Name: "b", Key: "b-key", Files: []string{otherFile}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("newTenantRouter() error = %v", err)
+	}
+	for _, tenant := range router.tenants {
+		tenant.reload(context.Background())
+	}
+	os.WriteFile(file, []byte("192.0.2.0/24\n"), 0o644)
+	os.WriteFile(otherFile, []byte("198.51.100.0/24\n"), 0o644)
+
+	req := httptest.NewRequest(http.MethodPost, "/tenants/a/reload", nil)
+	req.Header.Set("X-API-Key", "a-key")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "192.0.2.0/24") {
+		t.Errorf("reload = %d %s, want the new set", rec.Code, rec.Body)
+	}
+	if got := joinCIDRs(router.tenants["b"].server.cidrs); got != "172.16.0.0/12" {
+		t.Errorf("other tenant's set = %q, want it unchanged", got)
+	}
+}
+
+func TestNewTenantRouterErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []tenantConfig
+	}{
+		{name: "No tenants"},
+		{name: "No name", configs: []tenantConfig{{Files: []string{"a.txt"}}}},
+		{name: "Duplicate name", configs: []tenantConfig{{Name: "a", Files: []string{"a.txt"}}, {Name: "a", Files: []string{"b.txt"}}}},
+		{name: "Duplicate key", configs: []tenantConfig{{Name: "a", Key: "k", Files: []string{"a.txt"}}, {Name: "b", Key: "k", Files: []string{"b.txt"}}}},
+		{name: "No source", configs: []tenantConfig{{Name: "a"}}},
+		{name: "Two sources", configs: []tenantConfig{{Name: "a", Files: []string{"a.txt"}, Store: "consul://x/y"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newTenantRouter(tt.configs, nil); err == nil {
+				t.Errorf("newTenantRouter() expected an error")
+			}
+		})
+	}
+}
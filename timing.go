@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+)
+
+// stageTime is the wall time spent in one stage of a run.
+type stageTime struct {
+	Name     string
+	Duration time.Duration
+}
+
+// stageTimer records the wall time of consecutive stages of a run. A nil
+// timer records nothing, so callers need not check whether timing is on.
+type stageTimer struct {
+	stages []stageTime
+	start  time.Time
+	last   time.Time
+	// peakHeap is the most heap memory in use at the end of a stage. The
+	// heap is only sampled there, so a peak within a stage can go unseen.
+	peakHeap uint64
+}
+
+// newStageTimer returns a timer whose first stage starts now.
+func newStageTimer() *stageTimer {
+	now := time.Now()
+	return &stageTimer{start: now, last: now}
+}
+
+// mark ends the current stage, naming it, and starts the next one.
+func (t *stageTimer) mark(name string) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.stages = append(t.stages, stageTime{Name: name, Duration: now.Sub(t.last)})
+	t.last = now
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	if mem.HeapInuse > t.peakHeap {
+		t.peakHeap = mem.HeapInuse
+	}
+}
+
+// report writes the time spent in every stage, the total, the peak heap in
+// use at the end of a stage and the memory allocated over the whole run.
+func (t *stageTimer) report(w io.Writer) {
+	if t == nil {
+		return
+	}
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	fmt.Fprintln(w, "Timing:")
+	for _, stage := range t.stages {
+		fmt.Fprintf(w, "  %-10s %v\n", stage.Name, stage.Duration)
+	}
+	fmt.Fprintf(w, "  %-10s %v\n", "total", t.last.Sub(t.start))
+	fmt.Fprintf(w, "Peak heap in use: %.1f MiB at a stage end (%.1f MiB allocated in total)\n",
+		float64(t.peakHeap)/(1<<20), float64(mem.TotalAlloc)/(1<<20))
+}
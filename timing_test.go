@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStageTimer(t *testing.T) {
+	timer := newStageTimer()
+	timer.mark("read")
+	timer.mark("merge")
+
+	var buf bytes.Buffer
+	timer.report(&buf)
+	got := buf.String()
+	for _, want := range []string{"Timing:\n", "  read ", "  merge ", "  total ", "Peak heap in use: "} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report() output is missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestNilStageTimer(t *testing.T) {
+	var timer *stageTimer
+	timer.mark("read")
+
+	var buf bytes.Buffer
+	timer.report(&buf)
+	if buf.Len() != 0 {
+		t.Errorf("report() on a nil timer wrote %q", buf.String())
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// cidrNode is a block in the containment hierarchy built by buildCIDRTree.
+type cidrNode struct {
+	CIDR *net.IPNet
+	// Tags are the tags of the input entries for the block, set by
+	// buildTaggedCIDRTree.
+	Tags     []string
+	Children []*cidrNode
+}
+
+// buildCIDRTree arranges blocks into a forest where each block is a child of
+// the smallest other block containing it. Duplicates are dropped.
+func buildCIDRTree(cidrs []*net.IPNet) []*cidrNode {
+	sorted := deduplicateCIDRs(cidrs)
+	sortCIDRs(sorted)
+
+	// IPv4 and IPv6 blocks may interleave in the sort order, so every family
+	// keeps its own stack of open ancestors.
+	roots := []*cidrNode{}
+	stacks := map[int][]*cidrNode{}
+	for _, cidr := range sorted {
+		node := &cidrNode{CIDR: cidr}
+		_, bits := cidr.Mask.Size()
+		stack := stacks[bits]
+		for len(stack) > 0 && !cidrContains(stack[len(stack)-1].CIDR, cidr) {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, node)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, node)
+		}
+		stacks[bits] = append(stack, node)
+	}
+	return roots
+}
+
+// buildTaggedCIDRTree is buildCIDRTree over the blocks of entries, giving
+// every node the tags of the entries for its block, in order of appearance.
+func buildTaggedCIDRTree(entries []inputEntry) []*cidrNode {
+	var cidrs []*net.IPNet
+	tags := map[string][]string{}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		cidrs = append(cidrs, entry.CIDR)
+		block := entry.CIDR.String()
+		for _, tag := range entry.Tags {
+			if !seen[block+"\x00"+tag] {
+				seen[block+"\x00"+tag] = true
+				tags[block] = append(tags[block], tag)
+			}
+		}
+	}
+	roots := buildCIDRTree(cidrs)
+	var tag func(node *cidrNode)
+	tag = func(node *cidrNode) {
+		node.Tags = tags[node.CIDR.String()]
+		for _, child := range node.Children {
+			tag(child)
+		}
+	}
+	for _, root := range roots {
+		tag(root)
+	}
+	return roots
+}
+
+// treeEntry is a line of tree output: either a node or an uncovered gap
+// inside a node's parent.
+type treeEntry struct {
+	CIDR *net.IPNet
+	Node *cidrNode
+}
+
+// treeEntries returns the children of node interleaved with the gaps they
+// leave uncovered, sorted by address.
+func treeEntries(node *cidrNode) []treeEntry {
+	var children []*net.IPNet
+	for _, child := range node.Children {
+		children = append(children, child.CIDR)
+	}
+	gaps := subtractCIDRs([]*net.IPNet{node.CIDR}, children)
+
+	entries := []treeEntry{}
+	i, j := 0, 0
+	for i < len(node.Children) || j < len(gaps) {
+		if j == len(gaps) || (i < len(node.Children) && ipToInt(node.Children[i].CIDR.IP).Cmp(ipToInt(gaps[j].IP)) < 0) {
+			entries = append(entries, treeEntry{CIDR: node.Children[i].CIDR, Node: node.Children[i]})
+			i++
+		} else {
+			entries = append(entries, treeEntry{CIDR: gaps[j]})
+			j++
+		}
+	}
+	return entries
+}
+
+// renderTree writes the forest as an indented ASCII tree, marking the
+// address ranges inside a parent that no child covers.
+func renderTree(w io.Writer, roots []*cidrNode) {
+	var render func(node *cidrNode, prefix string)
+	render = func(node *cidrNode, prefix string) {
+		if len(node.Children) == 0 {
+			return
+		}
+		entries := treeEntries(node)
+		for i, entry := range entries {
+			branch, indent := "├── ", "│   "
+			if i == len(entries)-1 {
+				branch, indent = "└── ", "    "
+			}
+			if entry.Node == nil {
+				fmt.Fprintf(w, "%s%s%s (gap)\n", prefix, branch, entry.CIDR)
+				continue
+			}
+			fmt.Fprintf(w, "%s%s%s\n", prefix, branch, entry.CIDR)
+			render(entry.Node, prefix+indent)
+		}
+	}
+	for _, root := range roots {
+		fmt.Fprintln(w, root.CIDR)
+		render(root, "")
+	}
+}
+
+// renderDOT writes the forest as a Graphviz digraph with an edge from each
+// block to the blocks it directly contains. Nodes are labelled with their
+// size and tags.
+func renderDOT(w io.Writer, roots []*cidrNode) {
+	fmt.Fprintln(w, "digraph cidrs {")
+	fmt.Fprintln(w, "  node [shape=box];")
+	var render func(node *cidrNode)
+	render = func(node *cidrNode) {
+		label := fmt.Sprintf("%s\\n%s addresses", node.CIDR, cidrSize(node.CIDR))
+		if len(node.Tags) > 0 {
+			tags := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(strings.Join(node.Tags, ", "))
+			label += "\\ntags: " + tags
+		}
+		fmt.Fprintf(w, "  %q [label=\"%s\"];\n", node.CIDR.String(), label)
+		for _, child := range node.Children {
+			fmt.Fprintf(w, "  %q -> %q;\n", node.CIDR.String(), child.CIDR.String())
+		}
+		for _, child := range node.Children {
+			render(child)
+		}
+	}
+	for _, root := range roots {
+		render(root)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// runTree implements the "tree" command.
+func runTree(args []string) error {
+	fs := flag.NewFlagSet("tree", flag.ContinueOnError)
+	format := fs.String("output-format", "text", "output format: text or dot")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: tree [--output-format=text|dot] <file>")
+	}
+	entries, err := readCIDRFileEntries(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	roots := buildTaggedCIDRTree(entries)
+	switch *format {
+	case "text":
+		renderTree(os.Stdout, roots)
+	case "dot":
+		renderDOT(os.Stdout, roots)
+	default:
+		return fmt.Errorf("unknown output format: %s", *format)
+	}
+	return nil
+}
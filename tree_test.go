@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildCIDRTree(t *testing.T) {
+	cidrs, _ := parseCIDRList(strings.NewReader("10.0.1.0/24\n10.0.0.0/8\n192.168.0.0/16\n10.0.0.0/16\n10.0.0.0/8\n"))
+
+	roots := buildCIDRTree(cidrs)
+	if len(roots) != 2 {
+		t.Fatalf("buildCIDRTree() returned %d roots, want 2", len(roots))
+	}
+	if roots[0].CIDR.String() != "10.0.0.0/8" || len(roots[0].Children) != 1 {
+		t.Fatalf("buildCIDRTree() first root = %v with %d children", roots[0].CIDR, len(roots[0].Children))
+	}
+	child := roots[0].Children[0]
+	if child.CIDR.String() != "10.0.0.0/16" || len(child.Children) != 1 || child.Children[0].CIDR.String() != "10.0.1.0/24" {
+		t.Errorf("buildCIDRTree() nested children incorrect: %v", child.CIDR)
+	}
+}
+
+func TestBuildCIDRTreeMixedFamilies(t *testing.T) {
+	cidrs, _ := parseCIDRList(strings.NewReader("10.0.0.0/8\na00::/16\n10.1.0.0/16\n"))
+
+	var roots []string
+	for _, root := range buildCIDRTree(cidrs) {
+		var children []string
+		for _, child := range root.Children {
+			children = append(children, child.CIDR.String())
+		}
+		roots = append(roots, root.CIDR.String()+"["+strings.Join(children, ",")+"]")
+	}
+	if got, want := strings.Join(roots, " "), "10.0.0.0/8[10.1.0.0/16] a00::/16[]"; got != want {
+		t.Errorf("buildCIDRTree() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderTree(t *testing.T) {
+	cidrs, _ := parseCIDRList(strings.NewReader("10.0.0.0/22\n10.0.1.0/24\n10.0.2.0/23\n"))
+
+	var out strings.Builder
+	renderTree(&out, buildCIDRTree(cidrs))
+	want := "10.0.0.0/22\n" +
+		"├── 10.0.0.0/24 (gap)\n" +
+		"├── 10.0.1.0/24\n" +
+		"└── 10.0.2.0/23\n"
+	if out.String() != want {
+		t.Errorf("renderTree() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestRenderDOT(t *testing.T) {
+	cidrs, _ := parseCIDRList(strings.NewReader("10.0.0.0/23\n10.0.1.0/24\n"))
+
+	var out strings.Builder
+	renderDOT(&out, buildCIDRTree(cidrs))
+	want := "digraph cidrs {\n" +
+		"  node [shape=box];\n" +
+		"  \"10.0.0.0/23\" [label=\"10.0.0.0/23\\n512 addresses\"];\n" +
+		"  \"10.0.0.0/23\" -> \"10.0.1.0/24\";\n" +
+		"  \"10.0.1.0/24\" [label=\"10.0.1.0/24\\n256 addresses\"];\n" +
+		"}\n"
+	if out.String() != want {
+		t.Errorf("renderDOT() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestRenderDOTTags(t *testing.T) {
+	entries, err := scanCIDRJSON(strings.NewReader(`[
+  {"cidr": "10.0.0.0/23", "tags": ["prod", "eu"]},
+  {"cidr": "10.0.1.0/24", "tags": ["db \"primary\""]},
+  {"cidr": "10.0.0.0/23", "tags": ["eu"]}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	renderDOT(&out, buildTaggedCIDRTree(entries))
+	for _, want := range []string{
+		`"10.0.0.0/23" [label="10.0.0.0/23\n512 addresses\ntags: prod, eu"];`,
+		`"10.0.1.0/24" [label="10.0.1.0/24\n256 addresses\ntags: db \"primary\""];`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("renderDOT() =\n%s\nmissing %s", out.String(), want)
+		}
+	}
+}
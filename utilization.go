@@ -0,0 +1,218 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// parentUsage is the utilization of one parent allocation.
+type parentUsage struct {
+	Parent *net.IPNet
+	Used   *big.Int
+	Total  *big.Int
+	// LargestFree is the largest unallocated block in the parent, or nil
+	// when the parent is fully allocated.
+	LargestFree *net.IPNet
+	// Exhaustion is the projected time the parent runs out, when a
+	// projection was made and usage is growing.
+	Exhaustion *time.Time
+}
+
+// percent returns the share of the parent in use, in percent.
+func (u parentUsage) percent() float64 {
+	used, _ := new(big.Float).SetInt(u.Used).Float64()
+	total, _ := new(big.Float).SetInt(u.Total).Float64()
+	return used / total * 100
+}
+
+// usedAddresses returns the number of addresses of parent covered by
+// children.
+func usedAddresses(parent *net.IPNet, children []*net.IPNet) *big.Int {
+	used := new(big.Int)
+	for _, cidr := range intersectCIDRs([]*net.IPNet{parent}, children) {
+		used.Add(used, cidrSize(cidr))
+	}
+	return used
+}
+
+// computeUtilization reports, for every parent, how much of it children
+// use and the largest block still free.
+func computeUtilization(parents, children []*net.IPNet) []parentUsage {
+	children = collapseCIDRs(children)
+	usage := []parentUsage{}
+	for _, parent := range collapseCIDRs(parents) {
+		u := parentUsage{Parent: parent, Used: usedAddresses(parent, children), Total: cidrSize(parent)}
+		for _, free := range subtractCIDRs([]*net.IPNet{parent}, children) {
+			if u.LargestFree == nil || cidrSize(free).Cmp(cidrSize(u.LargestFree)) > 0 {
+				u.LargestFree = free
+			}
+		}
+		usage = append(usage, u)
+	}
+	return usage
+}
+
+// usagePoint is the number of addresses in use at a point in time.
+type usagePoint struct {
+	Time time.Time
+	Used *big.Int
+}
+
+// projectExhaustion fits a straight line through points by least squares
+// and returns when it reaches total. It returns false when there are fewer
+// than two distinct times or usage is not growing.
+func projectExhaustion(total *big.Int, points []usagePoint) (time.Time, bool) {
+	if len(points) < 2 {
+		return time.Time{}, false
+	}
+	origin := points[0].Time
+	var sumX, sumY, sumXX, sumXY float64
+	for _, p := range points {
+		x := p.Time.Sub(origin).Hours()
+		y, _ := new(big.Float).SetInt(p.Used).Float64()
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+	n := float64(len(points))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return time.Time{}, false
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	if slope <= 0 {
+		return time.Time{}, false
+	}
+	intercept := (sumY - slope*sumX) / n
+	limit, _ := new(big.Float).SetInt(total).Float64()
+	hours := (limit - intercept) / slope
+	if hours > float64(1<<62)/float64(time.Hour) {
+		return time.Time{}, false
+	}
+	return origin.Add(time.Duration(hours * float64(time.Hour))), true
+}
+
+// snapshot is an allocation file as it was at a point in time.
+type snapshot struct {
+	Time time.Time
+	File string
+}
+
+// snapshotFlag collects repeated -snapshot DATE=FILE flags.
+type snapshotFlag []snapshot
+
+func (f *snapshotFlag) String() string {
+	var values []string
+	for _, s := range *f {
+		values = append(values, s.Time.Format("2006-01-02")+"="+s.File)
+	}
+	return strings.Join(values, ",")
+}
+
+func (f *snapshotFlag) Set(value string) error {
+	date, file, ok := strings.Cut(value, "=")
+	if !ok || file == "" {
+		return fmt.Errorf("expected DATE=FILE, got %q", value)
+	}
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot date %q, expected YYYY-MM-DD", date)
+	}
+	*f = append(*f, snapshot{Time: t, File: file})
+	return nil
+}
+
+// addExhaustion projects the exhaustion of every parent in usage from the
+// snapshots plus the current allocations at now.
+func addExhaustion(usage []parentUsage, snapshots []snapshot, now time.Time) error {
+	sorted := append([]snapshot(nil), snapshots...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	history := make([][]*net.IPNet, len(sorted))
+	for i, s := range sorted {
+		cidrs, err := readCIDRFile(s.File)
+		if err != nil {
+			return err
+		}
+		history[i] = collapseCIDRs(cidrs)
+	}
+
+	for i := range usage {
+		var points []usagePoint
+		for j, s := range sorted {
+			points = append(points, usagePoint{Time: s.Time, Used: usedAddresses(usage[i].Parent, history[j])})
+		}
+		points = append(points, usagePoint{Time: now, Used: usage[i].Used})
+		if at, ok := projectExhaustion(usage[i].Total, points); ok {
+			usage[i].Exhaustion = &at
+		}
+	}
+	return nil
+}
+
+// renderUtilization writes usage as a table. The exhaustion column is only
+// written when projected is set.
+func renderUtilization(w io.Writer, usage []parentUsage, projected bool) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	header := "PARENT\tUSED\tSIZE\tUTILIZATION\tLARGEST FREE"
+	if projected {
+		header += "\tEXHAUSTION"
+	}
+	fmt.Fprintln(tw, header)
+	for _, u := range usage {
+		largest := "-"
+		if u.LargestFree != nil {
+			largest = u.LargestFree.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.1f%%\t%s", u.Parent, u.Used, u.Total, u.percent(), largest)
+		if projected {
+			exhaustion := "never"
+			if u.Exhaustion != nil {
+				exhaustion = u.Exhaustion.Format("2006-01-02")
+			}
+			fmt.Fprintf(tw, "\t%s", exhaustion)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// runUtilization implements the "utilization" command.
+func runUtilization(args []string) error {
+	fs := flag.NewFlagSet("utilization", flag.ContinueOnError)
+	parentFile := fs.String("parents", "", "file of parent allocations")
+	var snapshots snapshotFlag
+	fs.Var(&snapshots, "snapshot", "earlier allocations as DATE=FILE, repeatable, to project exhaustion")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *parentFile == "" || fs.NArg() != 1 {
+		return fmt.Errorf("usage: utilization -parents <file> [-snapshot DATE=FILE]... <allocations>")
+	}
+
+	parents, err := readCIDRFile(*parentFile)
+	if err != nil {
+		return err
+	}
+	children, err := readCIDRFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	usage := computeUtilization(parents, children)
+	if len(snapshots) > 0 {
+		if err := addExhaustion(usage, snapshots, time.Now()); err != nil {
+			return err
+		}
+	}
+	return renderUtilization(os.Stdout, usage, len(snapshots) > 0)
+}
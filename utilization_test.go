@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComputeUtilization(t *testing.T) {
+	parents, _ := parseCIDRList(strings.NewReader("10.0.0.0/22\n10.1.0.0/24\n10.2.0.0/30\n"))
+	children, _ := parseCIDRList(strings.NewReader("10.0.0.0/24\n10.0.2.0/25\n10.2.0.0/30\n192.168.0.0/24\n"))
+
+	usage := computeUtilization(parents, children)
+	tests := []struct {
+		used    int64
+		percent float64
+		largest string
+	}{
+		{used: 384, percent: 37.5, largest: "10.0.1.0/24"},
+		{used: 0, percent: 0, largest: "10.1.0.0/24"},
+		{used: 4, percent: 100, largest: ""},
+	}
+	if len(usage) != len(tests) {
+		t.Fatalf("computeUtilization() returned %d parents, want %d", len(usage), len(tests))
+	}
+	for i, tt := range tests {
+		u := usage[i]
+		largest := ""
+		if u.LargestFree != nil {
+			largest = u.LargestFree.String()
+		}
+		if u.Used.Int64() != tt.used || u.percent() != tt.percent || largest != tt.largest {
+			t.Errorf("usage of %s = %s used, %.1f%%, largest free %q, want %d, %.1f%%, %q",
+				u.Parent, u.Used, u.percent(), largest, tt.used, tt.percent, tt.largest)
+		}
+	}
+}
+
+func TestProjectExhaustion(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, n) }
+
+	tests := []struct {
+		name   string
+		points []usagePoint
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "Linear growth",
+			points: []usagePoint{{day(0), big.NewInt(100)}, {day(10), big.NewInt(200)}, {day(20), big.NewInt(300)}},
+			want:   "2026-04-11",
+			wantOK: true,
+		},
+		{
+			name:   "Flat usage",
+			points: []usagePoint{{day(0), big.NewInt(100)}, {day(10), big.NewInt(100)}},
+		},
+		{
+			name:   "Shrinking usage",
+			points: []usagePoint{{day(0), big.NewInt(200)}, {day(10), big.NewInt(100)}},
+		},
+		{
+			name:   "Single point",
+			points: []usagePoint{{day(0), big.NewInt(100)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := projectExhaustion(big.NewInt(1100), tt.points)
+			if ok != tt.wantOK {
+				t.Errorf("projectExhaustion() ok = %v, want %v", ok, tt.wantOK)
+				return
+			}
+			if ok && got.Format("2006-01-02") != tt.want {
+				t.Errorf("projectExhaustion() = %s, want %s", got.Format("2006-01-02"), tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotFlag(t *testing.T) {
+	var f snapshotFlag
+	if err := f.Set("2026-01-01=jan.txt"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if f.String() != "2026-01-01=jan.txt" {
+		t.Errorf("String() = %q", f.String())
+	}
+	for _, value := range []string{"jan.txt", "January=jan.txt", "2026-01-01="} {
+		if err := f.Set(value); err == nil {
+			t.Errorf("Set(%q) expected an error", value)
+		}
+	}
+}
+
+func TestRenderUtilization(t *testing.T) {
+	parents, _ := parseCIDRList(strings.NewReader("10.0.0.0/24\n"))
+	children, _ := parseCIDRList(strings.NewReader("10.0.0.0/25\n"))
+	usage := computeUtilization(parents, children)
+
+	var buf bytes.Buffer
+	if err := renderUtilization(&buf, usage, true); err != nil {
+		t.Fatalf("renderUtilization() error = %v", err)
+	}
+	want := "PARENT       USED  SIZE  UTILIZATION  LARGEST FREE   EXHAUSTION\n" +
+		"10.0.0.0/24  128   256   50.0%        10.0.0.128/25  never\n"
+	if buf.String() != want {
+		t.Errorf("renderUtilization() = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// webhookQueueSize bounds the changes waiting to be delivered; further
+// changes are dropped with a log message while the queue is full.
+const webhookQueueSize = 64
+
+// setChange is the JSON body POSTed to webhooks when a set changes. Added
+// and Removed are the address space gained and lost, so renumbering the
+// same addresses into different blocks is not a change.
+type setChange struct {
+	Event   string     `json:"event"`
+	Set     string     `json:"set"`
+	Time    time.Time  `json:"time"`
+	Blocks  int        `json:"blocks"`
+	Added   []cidrInfo `json:"added"`
+	Removed []cidrInfo `json:"removed"`
+}
+
+// newSetChange describes the change of the named set from previous to
+// cidrs. It returns false when both cover the same addresses.
+func newSetChange(set string, previous, cidrs []*net.IPNet, now time.Time) (setChange, bool) {
+	added, removed := compareCIDRSets(cidrs, previous)
+	if len(added) == 0 && len(removed) == 0 {
+		return setChange{}, false
+	}
+	change := setChange{
+		Event:   "set.changed",
+		Set:     set,
+		Time:    now.UTC(),
+		Blocks:  len(collapseCIDRs(cidrs)),
+		Added:   []cidrInfo{},
+		Removed: []cidrInfo{},
+	}
+	for _, cidr := range added {
+		change.Added = append(change.Added, newCIDRInfo(cidr))
+	}
+	for _, cidr := range removed {
+		change.Removed = append(change.Removed, newCIDRInfo(cidr))
+	}
+	return change, true
+}
+
+// webhookURLs collects repeated -webhook flags.
+type webhookURLs []string
+
+func (f *webhookURLs) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *webhookURLs) Set(value string) error {
+	if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+		return fmt.Errorf("expected an http:// or https:// URL, got %q", value)
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// webhookConfig is set by the flags registered with addWebhookFlags.
+type webhookConfig struct {
+	URLs webhookURLs
+	// Secret, when set, signs every body with HMAC-SHA256 in an
+	// X-Signature-256 header of the form "sha256=HEX".
+	Secret string
+}
+
+// addWebhookFlags registers the flags configuring change webhooks on fs.
+func addWebhookFlags(fs *flag.FlagSet) *webhookConfig {
+	config := &webhookConfig{}
+	fs.Var(&config.URLs, "webhook", "URL to POST set changes to (repeatable)")
+	fs.StringVar(&config.Secret, "webhook-secret", "", "secret signing webhook bodies in an X-Signature-256 header")
+	return config
+}
+
+// notifier returns the webhooks of the configuration sending through
+// client, or nil when no URL is configured.
+func (c *webhookConfig) notifier(client *http.Client) *webhooks {
+	if len(c.URLs) == 0 {
+		return nil
+	}
+	h := &webhooks{urls: c.URLs, secret: c.Secret, client: client, queue: make(chan setChange, webhookQueueSize)}
+	go h.run()
+	return h
+}
+
+// webhooks delivers set changes to a list of URLs, in the order the
+// changes happened. A nil *webhooks delivers nothing.
+type webhooks struct {
+	urls   []string
+	secret string
+	client *http.Client
+	queue  chan setChange
+}
+
+// notify queues the change of the named set from previous to cidrs, if any.
+func (h *webhooks) notify(set string, previous, cidrs []*net.IPNet) {
+	if h == nil {
+		return
+	}
+	change, changed := newSetChange(set, previous, cidrs, time.Now())
+	if !changed {
+		return
+	}
+	select {
+	case h.queue <- change:
+	default:
+		log.Printf("webhook queue full, dropping change of %s", set)
+	}
+}
+
+// run delivers queued changes.
+func (h *webhooks) run() {
+	for change := range h.queue {
+		h.deliver(context.Background(), change)
+	}
+}
+
+// deliver POSTs change to every URL, logging failures. Retries are left to
+// the HTTP client.
+func (h *webhooks) deliver(ctx context.Context, change setChange) {
+	body, err := json.Marshal(change)
+	if err != nil {
+		log.Printf("error encoding webhook body: %v", err)
+		return
+	}
+	for _, url := range h.urls {
+		if err := h.post(ctx, url, body); err != nil {
+			log.Printf("webhook %s: %v", url, err)
+		}
+	}
+}
+
+func (h *webhooks) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.secret != "" {
+		req.Header.Set("X-Signature-256", signWebhook(h.secret, body))
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// signWebhook returns the X-Signature-256 header value for body.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSetChange(t *testing.T) {
+	tests := []struct {
+		name        string
+		previous    string
+		cidrs       string
+		wantChanged bool
+		wantAdded   string
+		wantRemoved string
+	}{
+		{
+			name:        "Added and removed space",
+			previous:    "10.0.0.0/24\n192.168.0.0/24\n",
+			cidrs:       "10.0.0.0/23\n",
+			wantChanged: true,
+			wantAdded:   "10.0.1.0/24",
+			wantRemoved: "192.168.0.0/24",
+		},
+		{
+			name:        "From an empty set",
+			cidrs:       "10.0.0.0/8\n",
+			wantChanged: true,
+			wantAdded:   "10.0.0.0/8",
+		},
+		{
+			name:     "Same space in other blocks",
+			previous: "10.0.0.0/25\n10.0.0.128/25\n",
+			cidrs:    "10.0.0.0/24\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous, _ := parseCIDRList(strings.NewReader(tt.previous))
+			cidrs, _ := parseCIDRList(strings.NewReader(tt.cidrs))
+			change, changed := newSetChange("allow", previous, cidrs, time.Unix(0, 0))
+			if changed != tt.wantChanged {
+				t.Fatalf("newSetChange() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if got := joinCIDRInfos(change.Added); got != tt.wantAdded {
+				t.Errorf("Added = %q, want %q", got, tt.wantAdded)
+			}
+			if got := joinCIDRInfos(change.Removed); got != tt.wantRemoved {
+				t.Errorf("Removed = %q, want %q", got, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func joinCIDRInfos(infos []cidrInfo) string {
+	var cidrs []string
+	for _, info := range infos {
+		cidrs = append(cidrs, info.CIDR)
+	}
+	return strings.Join(cidrs, ",")
+}
+
+func TestWebhooks(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer ts.Close()
+
+	config := &webhookConfig{URLs: webhookURLs{ts.URL}, Secret: "s3cret"}
+	s := newServer(nil)
+	hooks := config.notifier(ts.Client())
+	s.onChange = func(previous, cidrs []*net.IPNet) {
+		hooks.notify("allow", previous, cidrs)
+	}
+	cidrs, _ := parseCIDRList(strings.NewReader("10.0.0.0/8\n"))
+	s.setCIDRs(cidrs)
+
+	var r *http.Request
+	select {
+	case r = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+	body := <-bodies
+	if r.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", r.Method)
+	}
+	if got, want := r.Header.Get("X-Signature-256"), signWebhook("s3cret", body); got != want {
+		t.Errorf("X-Signature-256 = %q, want %q", got, want)
+	}
+	var change setChange
+	if err := json.Unmarshal(body, &change); err != nil {
+		t.Fatalf("error decoding body: %v", err)
+	}
+	if change.Event != "set.changed" || change.Set != "allow" || change.Blocks != 1 || joinCIDRInfos(change.Added) != "10.0.0.0/8" {
+		t.Errorf("body = %s, want 10.0.0.0/8 added to allow", body)
+	}
+
+	// Setting the same space again is not a change.
+	s.setCIDRs(cidrs)
+	select {
+	case <-received:
+		t.Error("webhook called without a change")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebhookURLsSet(t *testing.T) {
+	var urls webhookURLs
+	if err := urls.Set("https://example.com/hook"); err != nil {
+		t.Errorf("Set() error = %v", err)
+	}
+	if err := urls.Set("example.com/hook"); err == nil {
+		t.Errorf("Set() expected an error for a URL without scheme")
+	}
+}
+
+func TestServeRejectsWebhookWithFiles(t *testing.T) {
+	err := runServe([]string{"-webhook", "http://127.0.0.1:1/hook", "allow.txt"})
+	if err == nil || !strings.Contains(err.Error(), "never changes") {
+		t.Errorf("runServe() error = %v, want -webhook rejected for files", err)
+	}
+}
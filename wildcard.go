@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// formatWildcard renders an IPv4 block in the wildcard notation accepted by
+// parseWildcard. A block whose prefix is not a multiple of 8 has no single
+// wildcard form, so it is rendered as the list of octet-aligned patterns
+// covering it, e.g. 10.0.0.0/23 gives 10.0.0.* and 10.0.1.*.
+func formatWildcard(cidr *net.IPNet) ([]string, error) {
+	ip := cidr.IP.To4()
+	ones, bits := cidr.Mask.Size()
+	if ip == nil || bits != 32 {
+		return nil, fmt.Errorf("wildcard notation only supports IPv4: %s", cidr)
+	}
+	ip = ip.Mask(cidr.Mask)
+
+	// Round the prefix up to the next octet boundary and enumerate the
+	// values of the partially fixed octet.
+	aligned := (ones + 7) / 8
+	count := 1 << uint(aligned*8-ones)
+	patterns := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		octets := make([]string, 4)
+		for j := range octets {
+			switch {
+			case j < aligned-1 || (j == aligned-1 && count == 1):
+				octets[j] = fmt.Sprint(ip[j])
+			case j == aligned-1:
+				octets[j] = fmt.Sprint(int(ip[j]) + i)
+			default:
+				octets[j] = "*"
+			}
+		}
+		patterns = append(patterns, strings.Join(octets, "."))
+	}
+	return patterns, nil
+}
+
+// runWildcard implements the "wildcard" command. Each argument is a block
+// or a file of blocks.
+func runWildcard(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: wildcard <cidr|file>...")
+	}
+	for _, arg := range args {
+		cidrs, err := parseEntry(arg)
+		if err != nil {
+			if cidrs, err = readCIDRFile(arg); err != nil {
+				return err
+			}
+		}
+		for _, cidr := range cidrs {
+			patterns, err := formatWildcard(cidr)
+			if err != nil {
+				return err
+			}
+			for _, pattern := range patterns {
+				fmt.Println(pattern)
+			}
+		}
+	}
+	return nil
+}
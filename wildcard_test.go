@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatWildcard(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Two wildcard octets", input: "192.168.0.0/16", want: "192.168.*.*"},
+		{name: "Host", input: "10.1.2.3/32", want: "10.1.2.3"},
+		{name: "Whole space", input: "0.0.0.0/0", want: "*.*.*.*"},
+		{name: "Non-aligned prefix", input: "10.0.0.0/23", want: "10.0.0.*,10.0.1.*"},
+		{name: "Non-aligned inside an octet", input: "172.16.0.0/14", want: "172.16.*.*,172.17.*.*,172.18.*.*,172.19.*.*"},
+		{name: "Small block", input: "10.0.0.4/30", want: "10.0.0.4,10.0.0.5,10.0.0.6,10.0.0.7"},
+		{name: "IPv6", input: "2001:db8::/32", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cidr, _ := parseCIDR(tt.input)
+			got, err := formatWildcard(cidr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("formatWildcard() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && strings.Join(got, ",") != tt.want {
+				t.Errorf("formatWildcard() = %q, want %q", strings.Join(got, ","), tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatWildcardRoundTrip(t *testing.T) {
+	cidr, _ := parseCIDR("10.20.0.0/16")
+	patterns, _ := formatWildcard(cidr)
+	got, err := parseWildcard(patterns[0])
+	if err != nil || got[0].String() != cidr.String() {
+		t.Errorf("parseWildcard(formatWildcard()) = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+)
+
+// xlsxFiles holds the static parts of a two-sheet workbook.
+var xlsxFiles = map[string]string{
+	"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/worksheets/sheet2.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`,
+	"_rels/.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`,
+	"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets>
+<sheet name="CIDRs" sheetId="1" r:id="rId1"/>
+<sheet name="Summary" sheetId="2" r:id="rId2"/>
+</sheets>
+</workbook>`,
+	"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"/>
+</Relationships>`,
+}
+
+// xlsxSheet renders rows as worksheet XML. Values that are *big.Int and fit
+// in a spreadsheet number are written as numeric cells, everything else as
+// inline strings.
+func xlsxSheet(rows [][]interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sb.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for _, row := range rows {
+		sb.WriteString("<row>")
+		for _, value := range row {
+			if n, ok := value.(*big.Int); ok && n.BitLen() <= 53 {
+				fmt.Fprintf(&sb, `<c><v>%s</v></c>`, n)
+				continue
+			}
+			sb.WriteString(`<c t="inlineStr"><is><t>`)
+			xml.EscapeText(&sb, []byte(fmt.Sprint(value)))
+			sb.WriteString(`</t></is></c>`)
+		}
+		sb.WriteString("</row>")
+	}
+	sb.WriteString("</sheetData></worksheet>")
+	return sb.String()
+}
+
+// blockTags returns the distinct tags of the entries overlapping block, in
+// order of appearance.
+func blockTags(block *net.IPNet, entries []inputEntry) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		if !cidrsOverlap(block, entry.CIDR) {
+			continue
+		}
+		for _, tag := range entry.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// writeXLSX writes a workbook with one row per block on the "CIDRs" sheet
+// and totals on the "Summary" sheet. The tags of a block are those of the
+// input entries that ended up in it.
+func writeXLSX(w io.Writer, cidrs []*net.IPNet, entries []inputEntry) error {
+	output := newCIDROutput(cidrs)
+
+	rows := [][]interface{}{{"Network", "Prefix", "Netmask", "Range", "Count", "Tags"}}
+	total := new(big.Int)
+	ipv4 := 0
+	for _, info := range output.CIDRs {
+		_, ipnet, _ := net.ParseCIDR(info.CIDR)
+		ones, _ := ipnet.Mask.Size()
+		if ipnet.IP.To4() != nil {
+			ipv4++
+		}
+		total.Add(total, info.Count)
+		rows = append(rows, []interface{}{
+			ipnet.IP.String(),
+			big.NewInt(int64(ones)),
+			net.IP(ipnet.Mask).String(),
+			info.First + " - " + info.Last,
+			info.Count,
+			strings.Join(blockTags(ipnet, entries), ", "),
+		})
+	}
+	summary := [][]interface{}{
+		{"Metric", "Value"},
+		{"Blocks", big.NewInt(int64(len(output.CIDRs)))},
+		{"IPv4 blocks", big.NewInt(int64(ipv4))},
+		{"IPv6 blocks", big.NewInt(int64(len(output.CIDRs) - ipv4))},
+		{"Total addresses", total},
+	}
+
+	files := map[string]string{
+		"xl/worksheets/sheet1.xml": xlsxSheet(rows),
+		"xl/worksheets/sheet2.xml": xlsxSheet(summary),
+	}
+	for name, content := range xlsxFiles {
+		files[name] = content
+	}
+
+	zw := zip.NewWriter(w)
+	for _, name := range []string{
+		"[Content_Types].xml",
+		"_rels/.rels",
+		"xl/workbook.xml",
+		"xl/_rels/workbook.xml.rels",
+		"xl/worksheets/sheet1.xml",
+		"xl/worksheets/sheet2.xml",
+	} {
+		f, err := zw.Create(name)
+		if err != nil {
+			return fmt.Errorf("error writing workbook: %v", err)
+		}
+		if _, err := io.WriteString(f, files[name]); err != nil {
+			return fmt.Errorf("error writing workbook: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("error writing workbook: %v", err)
+	}
+	return nil
+}
+
+// saveToXLSX saves CIDRs to an XLSX workbook, tagged from entries.
+func saveToXLSX(filename string, cidrs []*net.IPNet, entries []inputEntry) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating file: %v", err)
+	}
+	defer file.Close()
+	return writeXLSX(file, cidrs, entries)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestWriteXLSX(t *testing.T) {
+	_, net1, _ := net.ParseCIDR("192.168.1.0/24")
+	_, net2, _ := net.ParseCIDR("2001:db8::/32")
+
+	entries := []inputEntry{
+		{CIDR: net1, Tags: []string{"office", "prod"}},
+		{CIDR: net1, Tags: []string{"prod"}},
+		{CIDR: net2},
+	}
+
+	var buf bytes.Buffer
+	if err := writeXLSX(&buf, []*net.IPNet{net1, net2}, entries); err != nil {
+		t.Fatalf("writeXLSX() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("writeXLSX() produced an invalid zip: %v", err)
+	}
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(data)
+	}
+
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{name: "Network row", file: "xl/worksheets/sheet1.xml", want: "<t>192.168.1.0</t>"},
+		{name: "Netmask", file: "xl/worksheets/sheet1.xml", want: "<t>255.255.255.0</t>"},
+		{name: "Range", file: "xl/worksheets/sheet1.xml", want: "<t>192.168.1.0 - 192.168.1.255</t>"},
+		{name: "Numeric count", file: "xl/worksheets/sheet1.xml", want: "<v>256</v>"},
+		{name: "Tags header", file: "xl/worksheets/sheet1.xml", want: "<t>Count</t></is></c><c t=\"inlineStr\"><is><t>Tags</t>"},
+		{name: "Tags", file: "xl/worksheets/sheet1.xml", want: "<v>256</v></c><c t=\"inlineStr\"><is><t>office, prod</t>"},
+		{name: "Large count as text", file: "xl/worksheets/sheet1.xml", want: "<t>79228162514264337593543950336</t>"},
+		{name: "Summary totals", file: "xl/worksheets/sheet2.xml", want: "<t>IPv6 blocks</t></is></c><c><v>1</v>"},
+		{name: "Workbook sheets", file: "xl/workbook.xml", want: `<sheet name="Summary"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(contents[tt.file], tt.want) {
+				t.Errorf("%s does not contain %q", tt.file, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+)
+
+// dnsClasses are the class fields that may appear in a zone file record.
+var dnsClasses = map[string]bool{"IN": true, "CH": true, "HS": true, "CS": true}
+
+// isZoneFile reports whether filename follows a BIND zone file naming
+// scheme: a .zone extension or a db. prefix, as in db.example.com.
+func isZoneFile(filename string) bool {
+	base := filepath.Base(filename)
+	return strings.EqualFold(filepath.Ext(base), ".zone") || strings.HasPrefix(base, "db.")
+}
+
+// stripZoneComment removes a ';' comment from a zone file line, leaving
+// semicolons inside quoted strings alone.
+func stripZoneComment(line string) string {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// scanZoneRecords calls record with the line and fields of every record of
+// a BIND zone file, with multi-line records in parentheses joined. A
+// record starting with blank space belongs to the previous owner, which is
+// passed as an empty first field. Directives such as $ORIGIN and $TTL are
+// passed like records.
+func scanZoneRecords(r io.Reader, record func(line int, fields []string) error) error {
+	scanner := bufio.NewScanner(r)
+	lineNum, start, depth := 0, 0, 0
+	var fields []string
+	for scanner.Scan() {
+		lineNum++
+		text := stripZoneComment(scanner.Text())
+		if depth == 0 {
+			start = lineNum
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			fields = nil
+			if text[0] == ' ' || text[0] == '\t' {
+				fields = []string{""}
+			}
+		}
+		depth += strings.Count(text, "(") - strings.Count(text, ")")
+		text = strings.NewReplacer("(", " ", ")", " ").Replace(text)
+		fields = append(fields, strings.Fields(text)...)
+		if depth < 0 {
+			return fmt.Errorf("line %d: unbalanced parentheses", lineNum)
+		}
+		if depth == 0 {
+			if err := record(start, fields); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading input: %v", err)
+	}
+	if depth > 0 {
+		return fmt.Errorf("line %d: unclosed parenthesis", start)
+	}
+	return nil
+}
+
+// zoneRecordType returns the type and data of a record given its fields
+// after the owner, skipping the optional TTL and class in either order.
+func zoneRecordType(fields []string) (string, []string) {
+	for i, field := range fields {
+		if dnsClasses[strings.ToUpper(field)] || (field[0] >= '0' && field[0] <= '9') {
+			continue
+		}
+		return strings.ToUpper(field), fields[i+1:]
+	}
+	return "", nil
+}
+
+// scanZoneFile reads the addresses of the A and AAAA records of a BIND
+// zone file as host routes, /32 for IPv4 and /128 for IPv6. Other records
+// and directives are skipped; $INCLUDE is not supported.
+func scanZoneFile(r io.Reader) ([]inputEntry, error) {
+	var entries []inputEntry
+	err := scanZoneRecords(r, func(line int, fields []string) error {
+		if len(fields) == 0 {
+			return nil
+		}
+		if strings.HasPrefix(fields[0], "$") {
+			if strings.EqualFold(fields[0], "$INCLUDE") {
+				return fmt.Errorf("line %d: $INCLUDE is not supported", line)
+			}
+			return nil
+		}
+		recordType, data := zoneRecordType(fields[1:])
+		if recordType != "A" && recordType != "AAAA" {
+			return nil
+		}
+		if len(data) == 0 {
+			return fmt.Errorf("line %d: %s record without address", line, recordType)
+		}
+		ip := net.ParseIP(data[0])
+		if ip == nil || (ip.To4() != nil) != (recordType == "A") {
+			return fmt.Errorf("line %d: invalid %s record address: %s", line, recordType, data[0])
+		}
+		bits := 128
+		if recordType == "A" {
+			ip, bits = ip.To4(), 32
+		}
+		entries = append(entries, inputEntry{CIDR: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, Line: line})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// zoneACLEntries reads a zone file as ACL entries for all traffic to its
+// host routes, summarized into as few blocks as possible.
+func zoneACLEntries(r io.Reader) ([]aclEntry, error) {
+	records, err := scanZoneFile(r)
+	if err != nil {
+		return nil, err
+	}
+	var cidrs []*net.IPNet
+	for _, record := range records {
+		cidrs = append(cidrs, record.CIDR)
+	}
+	var entries []aclEntry
+	for _, cidr := range collapseCIDRs(cidrs) {
+		entries = append(entries, aclEntry{CIDR: cidr, Services: []portSpec{{Protocol: "all"}}})
+	}
+	return entries, nil
+}
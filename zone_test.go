@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const zoneSample = `$ORIGIN example.com.
+$TTL 3600
+@	IN	SOA	ns1 hostmaster (
+		2024050101 ; serial
+		7200       ; refresh
+		3600 1209600 3600 )
+	IN	NS	ns1
+	IN	A	192.0.2.1
+ns1	IN	A	192.0.2.2
+www	300	IN	A	192.0.2.3
+www	IN	300	AAAA	2001:db8::3
+api	A	192.0.2.4 ; no class or TTL
+txt	IN	TXT	"v=spf1; A 198.51.100.1"
+mail	IN	MX	10 mx.example.com.
+`
+
+func TestScanZoneFile(t *testing.T) {
+	entries, err := scanZoneFile(strings.NewReader(zoneSample))
+	if err != nil {
+		t.Fatalf("scanZoneFile() error = %v", err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.CIDR.String())
+	}
+	want := "192.0.2.1/32,192.0.2.2/32,192.0.2.3/32,2001:db8::3/128,192.0.2.4/32"
+	if strings.Join(got, ",") != want {
+		t.Errorf("scanZoneFile() = %q, want %q", strings.Join(got, ","), want)
+	}
+	if entries[0].Line != 8 || entries[4].Line != 12 {
+		t.Errorf("lines = %d, %d, want 8 and 12", entries[0].Line, entries[4].Line)
+	}
+}
+
+func TestScanZoneFileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "Invalid address", input: "www IN A 192.0.2.300\n"},
+		{name: "Wrong family", input: "www IN AAAA 192.0.2.3\n"},
+		{name: "Missing address", input: "www IN A\n"},
+		{name: "Unclosed parenthesis", input: "@ IN SOA ns1 hostmaster (\n 1 2 3\n"},
+		{name: "Unbalanced parentheses", input: "@ IN SOA ns1 hostmaster )\n"},
+		{name: "Include", input: "$INCLUDE other.zone\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := scanZoneFile(strings.NewReader(tt.input)); err == nil {
+				t.Errorf("scanZoneFile() expected an error")
+			}
+		})
+	}
+}
+
+func TestReadZoneFile(t *testing.T) {
+	for _, name := range []string{"example.com.zone", "db.example.com"} {
+		filename := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(filename, []byte(zoneSample), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cidrs, err := readCIDRFile(filename)
+		if err != nil {
+			t.Fatalf("readCIDRFile(%s) error = %v", name, err)
+		}
+		if got := joinCIDRs(collapseCIDRs(cidrs)); got != "2001:db8::3/128,192.0.2.1/32,192.0.2.2/31,192.0.2.4/32" {
+			t.Errorf("readCIDRFile(%s) summarized = %q", name, got)
+		}
+	}
+}
+
+func TestZoneACLEntries(t *testing.T) {
+	entries, err := zoneACLEntries(strings.NewReader(zoneSample))
+	if err != nil {
+		t.Fatalf("zoneACLEntries() error = %v", err)
+	}
+	var cidrs []string
+	for _, entry := range entries {
+		if len(entry.Services) != 1 || entry.Services[0].Protocol != "all" {
+			t.Errorf("zoneACLEntries() %s services = %v, want all", entry.CIDR, entry.Services)
+		}
+		cidrs = append(cidrs, entry.CIDR.String())
+	}
+	if got := strings.Join(cidrs, ","); got != "2001:db8::3/128,192.0.2.1/32,192.0.2.2/31,192.0.2.4/32" {
+		t.Errorf("zoneACLEntries() = %q", got)
+	}
+}